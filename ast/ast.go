@@ -0,0 +1,178 @@
+// Package ast declares the types used to represent the abstract syntax tree of
+// structured control flow, as recovered by control flow structuring algorithms
+// and consumed by back ends.
+//
+// Leaves of the tree are basic blocks of the original control flow graph (see
+// Block), and statements introduced by structuring (e.g. break statements and
+// assignments to selector variables). Conditions are represented in the textual
+// notation of package cond (e.g. `%c1 && x != 2`).
+package ast
+
+import (
+	"github.com/mewmew/pi/cfg"
+)
+
+// Node is a node of the abstract syntax tree.
+//
+// The concrete type of a node is one of the following.
+//
+//    *ast.Block
+//    *ast.Seq
+//    *ast.If
+//    *ast.IfElse
+//    *ast.While
+//    *ast.DoWhile
+//    *ast.For
+//    *ast.Endless
+//    *ast.Switch
+//    *ast.Case
+//    *ast.Break
+//    *ast.Continue
+//    *ast.Return
+//    *ast.Goto
+//    *ast.Label
+//    *ast.Assign
+type Node interface {
+	// isNode ensures that only abstract syntax tree nodes can be assigned to the
+	// ast.Node interface.
+	isNode()
+}
+
+// Block is a basic block of the original control flow graph.
+type Block struct {
+	// Control flow graph node of the basic block.
+	Node *cfg.Node
+}
+
+// Seq is a sequence of nodes, executed in order.
+type Seq struct {
+	// Nodes of the sequence.
+	Nodes []Node
+}
+
+// If is a 2-way conditional without else-branch.
+type If struct {
+	// Branching condition.
+	Cond string
+	// Target branch taken if the condition holds.
+	Then Node
+}
+
+// IfElse is a 2-way conditional with else-branch.
+type IfElse struct {
+	// Branching condition.
+	Cond string
+	// Target branch taken if the condition holds.
+	Then Node
+	// Target branch taken if the condition does not hold.
+	Else Node
+}
+
+// While is a pre-test loop.
+type While struct {
+	// Loop condition, evaluated before each iteration.
+	Cond string
+	// Loop body.
+	Body Node
+}
+
+// DoWhile is a post-test loop.
+type DoWhile struct {
+	// Loop body.
+	Body Node
+	// Loop condition, evaluated after each iteration.
+	Cond string
+}
+
+// For is a pre-test loop with init and post statements.
+type For struct {
+	// Init statement, executed before the loop; or nil if not present.
+	Init Node
+	// Loop condition, evaluated before each iteration.
+	Cond string
+	// Post statement, executed after each iteration; or nil if not present.
+	Post Node
+	// Loop body.
+	Body Node
+}
+
+// Endless is an endless loop; exited through break and return statements.
+type Endless struct {
+	// Loop body.
+	Body Node
+}
+
+// Switch is an n-way conditional, which compares a variable against the
+// constant values of its cases.
+type Switch struct {
+	// Variable compared against the case values.
+	Var string
+	// Cases of the switch statement.
+	Cases []*Case
+	// Default case taken if no case value matches; or nil if not present.
+	Default Node
+}
+
+// Case is a case of a switch statement.
+type Case struct {
+	// Case values.
+	Values []string
+	// Body of the case, executed if the variable of the switch statement
+	// matches any of the case values.
+	Body Node
+}
+
+// Break is a break statement, which exits the innermost enclosing loop.
+type Break struct{}
+
+// Continue is a continue statement, which continues with the next iteration of
+// the innermost enclosing loop.
+type Continue struct{}
+
+// Return is a return statement.
+type Return struct {
+	// Return value; or empty if not present.
+	Value string
+}
+
+// Goto is a goto statement, which transfers control to the statement of the
+// given label.
+type Goto struct {
+	// Target label name.
+	Label string
+}
+
+// Label is a label, which marks the position of the succeeding statement as
+// target of goto statements.
+type Label struct {
+	// Label name.
+	Name string
+}
+
+// Assign is an assignment of a constant value to a variable, introduced by
+// structuring (e.g. the selector variables of restructured loops).
+type Assign struct {
+	// Variable name.
+	Var string
+	// Assigned value.
+	Value int
+}
+
+// isNode ensures that only abstract syntax tree nodes can be assigned to the
+// ast.Node interface.
+func (*Block) isNode()    {}
+func (*Seq) isNode()      {}
+func (*If) isNode()       {}
+func (*IfElse) isNode()   {}
+func (*While) isNode()    {}
+func (*DoWhile) isNode()  {}
+func (*For) isNode()      {}
+func (*Endless) isNode()  {}
+func (*Switch) isNode()   {}
+func (*Case) isNode()     {}
+func (*Break) isNode()    {}
+func (*Continue) isNode() {}
+func (*Return) isNode()   {}
+func (*Goto) isNode()     {}
+func (*Label) isNode()    {}
+func (*Assign) isNode()   {}
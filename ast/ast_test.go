@@ -0,0 +1,199 @@
+package ast
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mewmew/pi/cfg"
+)
+
+// block returns a basic block of a control flow graph node with the given name.
+func block(name string) *Block {
+	n := &cfg.Node{}
+	n.SetDOTID(name)
+	return &Block{Node: n}
+}
+
+// sample returns a sample abstract syntax tree, containing every kind of node.
+func sample() Node {
+	return &Seq{Nodes: []Node{
+		block("A"),
+		&If{Cond: "%a", Then: block("B")},
+		&IfElse{Cond: "%b", Then: block("C"), Else: &Return{Value: "0"}},
+		&While{Cond: "%c", Body: &Seq{Nodes: []Node{block("D"), &Continue{}}}},
+		&DoWhile{Body: block("E"), Cond: "%d"},
+		&For{Init: &Assign{Var: "%i", Value: 0}, Cond: "%i != 3", Post: &Assign{Var: "%i", Value: 3}, Body: block("F")},
+		&Endless{Body: &Seq{Nodes: []Node{block("G"), &Break{}}}},
+		&Switch{
+			Var:     "x",
+			Cases:   []*Case{{Values: []string{"1", "2"}, Body: block("H")}},
+			Default: &Goto{Label: "L"},
+		},
+		&Label{Name: "L"},
+		&Return{},
+	}}
+}
+
+func TestFprint(t *testing.T) {
+	want := strings.TrimSpace(`
+Seq
+	Block A
+	If (%a)
+		Block B
+	IfElse (%b)
+		Then
+			Block C
+		Else
+			Return 0
+	While (%c)
+		Seq
+			Block D
+			Continue
+	DoWhile (%d)
+		Block E
+	For (%i != 3)
+		Init
+			Assign %i = 0
+		Post
+			Assign %i = 3
+		Body
+			Block F
+	Endless
+		Seq
+			Block G
+			Break
+	Switch (x)
+		Case 1, 2
+			Block H
+		Default
+			Goto L
+	Label L
+	Return`)
+	if got := Sprint(sample()); got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	want := strings.TrimSpace(`
+A
+if (%a) {
+	B
+}
+if (%b) {
+	C
+} else {
+	return 0
+}
+while (%c) {
+	D
+	continue
+}
+do {
+	E
+} while (%d)
+for (%i = 0; %i != 3; %i = 3) {
+	F
+}
+for {
+	G
+	break
+}
+switch (x) {
+case 1, 2:
+	H
+default:
+	goto L
+}
+L:
+return`)
+	if got := Format(sample()); got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	// Blocks in execution order.
+	var got []string
+	Inspect(sample(), func(n Node) bool {
+		if b, ok := n.(*Block); ok {
+			got = append(got, b.Node.DOTID())
+		}
+		return true
+	})
+	want := []string{"A", "B", "C", "D", "E", "F", "G", "H"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blocks mismatch; expected %v, got %v", want, got)
+	}
+	// Skip the children of loops.
+	got = nil
+	Inspect(sample(), func(n Node) bool {
+		switch n := n.(type) {
+		case *While, *DoWhile, *For, *Endless:
+			return false
+		case *Block:
+			got = append(got, n.Node.DOTID())
+		}
+		return true
+	})
+	want = []string{"A", "B", "C", "H"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("blocks mismatch; expected %v, got %v", want, got)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	in := sample()
+	orig := Sprint(in)
+	// Replace break statements by return statements.
+	out := Rewrite(in, func(n Node) Node {
+		if _, ok := n.(*Break); ok {
+			return &Return{}
+		}
+		return n
+	})
+	want := strings.Replace(orig, "\t\t\tBreak", "\t\t\tReturn", 1)
+	if got := Sprint(out); got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+	if got := Sprint(in); got != orig {
+		t.Errorf("source tree modified; expected `%s`, got `%s`", orig, got)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	b := block("B")
+	in := &IfElse{Cond: "%a", Then: b, Else: &Seq{Nodes: []Node{block("C"), b}}}
+	out := Replace(in, b, &Break{})
+	want := "IfElse (%a)\n\tThen\n\t\tBreak\n\tElse\n\t\tSeq\n\t\t\tBlock C\n\t\t\tBreak"
+	if got := Sprint(out); got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+	if in.Then != b {
+		t.Errorf("source tree modified")
+	}
+}
+
+func TestNewSeq(t *testing.T) {
+	golden := []struct {
+		in   []Node
+		want string
+	}{
+		{in: []Node{block("A")}, want: "Block A"},
+		{in: []Node{nil, block("A"), &Seq{}}, want: "Block A"},
+		{in: []Node{block("A"), &Seq{Nodes: []Node{block("B"), &Seq{Nodes: []Node{block("C")}}}}}, want: "Seq\n\tBlock A\n\tBlock B\n\tBlock C"},
+		{in: nil, want: "Seq"},
+	}
+	for _, gold := range golden {
+		if got := Sprint(NewSeq(gold.in...)); got != gold.want {
+			t.Errorf("%v; output mismatch; expected `%s`, got `%s`", gold.in, gold.want, got)
+		}
+	}
+	// Flatten nested sequences of the tree.
+	in := &While{Cond: "%c", Body: &Seq{Nodes: []Node{&Seq{Nodes: []Node{block("A")}}, &Seq{}}}}
+	want := "While (%c)\n\tBlock A"
+	if got := Sprint(Flatten(in)); got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+}
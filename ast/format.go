@@ -0,0 +1,119 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Format returns a pseudo-code representation of the given node, using one line
+// per statement and tabs for indentation; e.g.
+//
+//    B1
+//    if (%c1) {
+//       B2
+//    } else {
+//       break
+//    }
+func Format(n Node) string {
+	buf := &strings.Builder{}
+	// Writes to strings.Builder never fail.
+	_ = Fformat(buf, n)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// Fformat writes a pseudo-code representation of the given node to w (see
+// Format).
+func Fformat(w io.Writer, n Node) error {
+	f := &formatter{printer: printer{w: w}}
+	f.format(n, 0)
+	return f.err
+}
+
+// formatter is a pseudo-code printer of abstract syntax trees.
+type formatter struct {
+	printer
+}
+
+// format writes a pseudo-code representation of the given node to the output
+// at the specified level of indentation.
+func (f *formatter) format(n Node, indent int) {
+	switch n := n.(type) {
+	case *Block:
+		name := "<nil>"
+		if n.Node != nil {
+			name = n.Node.DOTID()
+		}
+		f.printf(indent, "%s", name)
+	case *Seq:
+		for _, nn := range n.Nodes {
+			f.format(nn, indent)
+		}
+	case *If:
+		f.printf(indent, "if (%s) {", n.Cond)
+		f.format(n.Then, indent+1)
+		f.printf(indent, "}")
+	case *IfElse:
+		f.printf(indent, "if (%s) {", n.Cond)
+		f.format(n.Then, indent+1)
+		f.printf(indent, "} else {")
+		f.format(n.Else, indent+1)
+		f.printf(indent, "}")
+	case *While:
+		f.printf(indent, "while (%s) {", n.Cond)
+		f.format(n.Body, indent+1)
+		f.printf(indent, "}")
+	case *DoWhile:
+		f.printf(indent, "do {")
+		f.format(n.Body, indent+1)
+		f.printf(indent, "} while (%s)", n.Cond)
+	case *For:
+		f.printf(indent, "for (%s; %s; %s) {", simpleStmt(n.Init), n.Cond, simpleStmt(n.Post))
+		f.format(n.Body, indent+1)
+		f.printf(indent, "}")
+	case *Endless:
+		f.printf(indent, "for {")
+		f.format(n.Body, indent+1)
+		f.printf(indent, "}")
+	case *Switch:
+		f.printf(indent, "switch (%s) {", n.Var)
+		for _, c := range n.Cases {
+			f.format(c, indent)
+		}
+		if n.Default != nil {
+			f.printf(indent, "default:")
+			f.format(n.Default, indent+1)
+		}
+		f.printf(indent, "}")
+	case *Case:
+		f.printf(indent, "case %s:", strings.Join(n.Values, ", "))
+		f.format(n.Body, indent+1)
+	case *Break:
+		f.printf(indent, "break")
+	case *Continue:
+		f.printf(indent, "continue")
+	case *Return:
+		if len(n.Value) > 0 {
+			f.printf(indent, "return %s", n.Value)
+			break
+		}
+		f.printf(indent, "return")
+	case *Goto:
+		f.printf(indent, "goto %s", n.Label)
+	case *Label:
+		f.printf(indent, "%s:", n.Name)
+	case *Assign:
+		f.printf(indent, "%s = %d", n.Var, n.Value)
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+}
+
+// simpleStmt returns a single line pseudo-code representation of the given init
+// or post statement of a for loop; or an empty string if not present.
+func simpleStmt(n Node) string {
+	if n == nil {
+		return ""
+	}
+	return strings.Replace(Format(n), "\n", "; ", -1)
+}
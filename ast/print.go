@@ -0,0 +1,126 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fprint writes a debug representation of the abstract syntax tree of the given
+// node to w, using one line per node and tabs for indentation; e.g.
+//
+//    Seq
+//       Block B1
+//       IfElse (%c1)
+//          Then
+//             Block B2
+//          Else
+//             Break
+func Fprint(w io.Writer, n Node) error {
+	p := &printer{w: w}
+	p.print(n, 0)
+	return p.err
+}
+
+// Sprint returns a debug representation of the abstract syntax tree of the
+// given node (see Fprint).
+func Sprint(n Node) string {
+	buf := &strings.Builder{}
+	// Writes to strings.Builder never fail.
+	_ = Fprint(buf, n)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// printer is a debug printer of abstract syntax trees.
+type printer struct {
+	// Output writer.
+	w io.Writer
+	// First error encountered while writing output.
+	err error
+}
+
+// printf writes the formatted line to the output at the specified level of
+// indentation.
+func (p *printer) printf(indent int, format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	tabs := strings.Repeat("\t", indent)
+	_, p.err = fmt.Fprintf(p.w, tabs+format+"\n", args...)
+}
+
+// field writes the named child node to the output at the specified level of
+// indentation, if not nil.
+func (p *printer) field(name string, n Node, indent int) {
+	if n == nil {
+		return
+	}
+	p.printf(indent, "%s", name)
+	p.print(n, indent+1)
+}
+
+// print writes the given node to the output at the specified level of
+// indentation.
+func (p *printer) print(n Node, indent int) {
+	switch n := n.(type) {
+	case *Block:
+		name := "<nil>"
+		if n.Node != nil {
+			name = n.Node.DOTID()
+		}
+		p.printf(indent, "Block %s", name)
+	case *Seq:
+		p.printf(indent, "Seq")
+		for _, nn := range n.Nodes {
+			p.print(nn, indent+1)
+		}
+	case *If:
+		p.printf(indent, "If (%s)", n.Cond)
+		p.print(n.Then, indent+1)
+	case *IfElse:
+		p.printf(indent, "IfElse (%s)", n.Cond)
+		p.field("Then", n.Then, indent+1)
+		p.field("Else", n.Else, indent+1)
+	case *While:
+		p.printf(indent, "While (%s)", n.Cond)
+		p.print(n.Body, indent+1)
+	case *DoWhile:
+		p.printf(indent, "DoWhile (%s)", n.Cond)
+		p.print(n.Body, indent+1)
+	case *For:
+		p.printf(indent, "For (%s)", n.Cond)
+		p.field("Init", n.Init, indent+1)
+		p.field("Post", n.Post, indent+1)
+		p.field("Body", n.Body, indent+1)
+	case *Endless:
+		p.printf(indent, "Endless")
+		p.print(n.Body, indent+1)
+	case *Switch:
+		p.printf(indent, "Switch (%s)", n.Var)
+		for _, c := range n.Cases {
+			p.print(c, indent+1)
+		}
+		p.field("Default", n.Default, indent+1)
+	case *Case:
+		p.printf(indent, "Case %s", strings.Join(n.Values, ", "))
+		p.print(n.Body, indent+1)
+	case *Break:
+		p.printf(indent, "Break")
+	case *Continue:
+		p.printf(indent, "Continue")
+	case *Return:
+		if len(n.Value) > 0 {
+			p.printf(indent, "Return %s", n.Value)
+			break
+		}
+		p.printf(indent, "Return")
+	case *Goto:
+		p.printf(indent, "Goto %s", n.Label)
+	case *Label:
+		p.printf(indent, "Label %s", n.Name)
+	case *Assign:
+		p.printf(indent, "Assign %s = %d", n.Var, n.Value)
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+}
@@ -0,0 +1,116 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// Rewrite returns the abstract syntax tree of the given node rewritten by f. The
+// tree is traversed bottom-up; f is invoked on each node after its children
+// have been rewritten, and the node returned by f takes the place of the node
+// in the rewritten tree. Nodes with children are copied rather than modified in
+// place; thus the given tree is left unmodified.
+func Rewrite(n Node, f func(Node) Node) Node {
+	return f(mapChildren(n, func(child Node) Node {
+		return Rewrite(child, f)
+	}))
+}
+
+// Replace returns the abstract syntax tree of the given node, with each
+// occurrence of the old node replaced by the new node. The given tree is left
+// unmodified.
+func Replace(n, old, new Node) Node {
+	if n == old {
+		return new
+	}
+	return mapChildren(n, func(child Node) Node {
+		return Replace(child, old, new)
+	})
+}
+
+// Flatten returns the abstract syntax tree of the given node, with nested
+// sequences flattened and empty sequences removed (see NewSeq). The given tree
+// is left unmodified.
+func Flatten(n Node) Node {
+	return Rewrite(n, func(n Node) Node {
+		if seq, ok := n.(*Seq); ok {
+			return NewSeq(seq.Nodes...)
+		}
+		return n
+	})
+}
+
+// NewSeq returns a sequence of the given nodes, flattening nested sequences and
+// omitting nil nodes. The node itself is returned for single node sequences.
+func NewSeq(nodes ...Node) Node {
+	var ns []Node
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case nil:
+			// omit nil nodes.
+		case *Seq:
+			m := NewSeq(n.Nodes...)
+			if seq, ok := m.(*Seq); ok {
+				ns = append(ns, seq.Nodes...)
+			} else {
+				ns = append(ns, m)
+			}
+		default:
+			ns = append(ns, n)
+		}
+	}
+	if len(ns) == 1 {
+		return ns[0]
+	}
+	return &Seq{Nodes: ns}
+}
+
+// mapChildren returns a copy of the given node, with each non-nil child node
+// replaced by the result of f. Leaf nodes are returned as is.
+func mapChildren(n Node, f func(Node) Node) Node {
+	child := func(n Node) Node {
+		if n == nil {
+			return nil
+		}
+		return f(n)
+	}
+	switch n := n.(type) {
+	case *Block, *Break, *Continue, *Return, *Goto, *Label, *Assign:
+		// leaf nodes.
+		return n
+	case *Seq:
+		m := &Seq{Nodes: make([]Node, 0, len(n.Nodes))}
+		for _, nn := range n.Nodes {
+			m.Nodes = append(m.Nodes, child(nn))
+		}
+		return m
+	case *If:
+		return &If{Cond: n.Cond, Then: child(n.Then)}
+	case *IfElse:
+		return &IfElse{Cond: n.Cond, Then: child(n.Then), Else: child(n.Else)}
+	case *While:
+		return &While{Cond: n.Cond, Body: child(n.Body)}
+	case *DoWhile:
+		return &DoWhile{Body: child(n.Body), Cond: n.Cond}
+	case *For:
+		return &For{Init: child(n.Init), Cond: n.Cond, Post: child(n.Post), Body: child(n.Body)}
+	case *Endless:
+		return &Endless{Body: child(n.Body)}
+	case *Switch:
+		m := &Switch{Var: n.Var, Default: child(n.Default)}
+		for _, c := range n.Cases {
+			cc := child(c)
+			c, ok := cc.(*Case)
+			if !ok {
+				panic(fmt.Errorf("invalid case of switch statement; expected *ast.Case, got %T", cc))
+			}
+			m.Cases = append(m.Cases, c)
+		}
+		return m
+	case *Case:
+		values := make([]string, len(n.Values))
+		copy(values, n.Values)
+		return &Case{Values: values, Body: child(n.Body)}
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+}
@@ -0,0 +1,87 @@
+package ast
+
+import (
+	"fmt"
+)
+
+// A Visitor's Visit method is invoked for each node encountered by Walk. If the
+// result visitor w is not nil, Walk visits each of the children of node with
+// the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses the abstract syntax tree in depth-first order. It starts by
+// calling v.Visit(n); n must not be nil. If the visitor w returned by v.Visit(n)
+// is not nil, Walk is invoked recursively with visitor w for each of the
+// non-nil children of n, followed by a call of w.Visit(nil).
+func Walk(v Visitor, n Node) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+	for _, child := range Children(n) {
+		Walk(v, child)
+	}
+	v.Visit(nil)
+}
+
+// inspector is a visitor which invokes the given function on each node.
+type inspector func(Node) bool
+
+// Visit invokes the inspector function on the given node; implements
+// ast.Visitor.
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the abstract syntax tree in depth-first order. It starts by
+// calling f(n); n must not be nil. If f returns true, Inspect invokes f
+// recursively for each of the non-nil children of n, followed by a call of
+// f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	Walk(inspector(f), n)
+}
+
+// Children returns the non-nil child nodes of the given node, in execution
+// order.
+func Children(n Node) []Node {
+	var children []Node
+	add := func(ns ...Node) {
+		for _, n := range ns {
+			if n != nil {
+				children = append(children, n)
+			}
+		}
+	}
+	switch n := n.(type) {
+	case *Block, *Break, *Continue, *Return, *Goto, *Label, *Assign:
+		// leaf nodes.
+	case *Seq:
+		add(n.Nodes...)
+	case *If:
+		add(n.Then)
+	case *IfElse:
+		add(n.Then, n.Else)
+	case *While:
+		add(n.Body)
+	case *DoWhile:
+		add(n.Body)
+	case *For:
+		add(n.Init, n.Body, n.Post)
+	case *Endless:
+		add(n.Body)
+	case *Switch:
+		for _, c := range n.Cases {
+			add(c)
+		}
+		add(n.Default)
+	case *Case:
+		add(n.Body)
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+	return children
+}
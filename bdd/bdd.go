@@ -0,0 +1,211 @@
+// Package bdd implements reduced ordered binary decision diagrams of branching
+// conditions.
+//
+// Binary decision diagrams provide canonical representations of Boolean
+// formulas; two formulas are equivalent if and only if they are represented by
+// the same node of a diagram. This is used to decide whether the branching
+// conditions of control flow graph edges are equivalent, complementary, implied
+// or disjoint, regardless of how they are written.
+//
+// The atomic conditions of formulas are their Boolean variables and
+// comparisons, as given by cond.Atom. Atomic conditions are ordered by first
+// use.
+package bdd
+
+import (
+	"fmt"
+
+	"github.com/mewmew/pi/cond"
+)
+
+// Node is a node of a binary decision diagram, representing a Boolean formula.
+type Node int32
+
+// Terminal nodes.
+const (
+	// False is the terminal node of the formula which never holds.
+	False Node = 0
+	// True is the terminal node of the formula which always holds.
+	True Node = 1
+)
+
+// Manager manages the nodes of binary decision diagrams over a shared set of
+// atomic conditions. Nodes are only valid within the manager in which they were
+// created.
+type Manager struct {
+	// Nodes of the diagrams, indexed by Node.
+	nodes []node
+	// unique maps from node contents to node, ensuring that every node is
+	// unique.
+	unique map[node]Node
+	// ites maps from if-then-else operands to the result of the operation.
+	ites map[[3]Node]Node
+	// Names of atomic conditions, indexed by variable.
+	names []string
+	// vars maps from atomic condition name to variable.
+	vars map[string]int32
+}
+
+// node is a decision node; i.e. if v then hi else lo.
+type node struct {
+	// Variable of the decision node; or terminalVar if terminal node.
+	v int32
+	// Low and high children.
+	lo, hi Node
+}
+
+// terminalVar is the variable of terminal nodes, ordered after every variable.
+const terminalVar = 1<<31 - 1
+
+// NewManager returns a new manager of binary decision diagrams.
+func NewManager() *Manager {
+	return &Manager{
+		nodes: []node{
+			False: {v: terminalVar},
+			True:  {v: terminalVar},
+		},
+		unique: make(map[node]Node),
+		ites:   make(map[[3]Node]Node),
+		vars:   make(map[string]int32),
+	}
+}
+
+// Var returns the node of the atomic condition with the given name.
+func (m *Manager) Var(name string) Node {
+	v, ok := m.vars[name]
+	if !ok {
+		v = int32(len(m.names))
+		m.vars[name] = v
+		m.names = append(m.names, name)
+	}
+	return m.mk(v, False, True)
+}
+
+// Not returns the negation of the given formula.
+func (m *Manager) Not(f Node) Node {
+	return m.ite(f, False, True)
+}
+
+// And returns the conjunction of the given formulas.
+func (m *Manager) And(f, g Node) Node {
+	return m.ite(f, g, False)
+}
+
+// Or returns the disjunction of the given formulas.
+func (m *Manager) Or(f, g Node) Node {
+	return m.ite(f, True, g)
+}
+
+// Expr returns the node of the given condition.
+func (m *Manager) Expr(x cond.Expr) Node {
+	switch x := x.(type) {
+	case cond.Const:
+		if x {
+			return True
+		}
+		return False
+	case *cond.Var, *cond.Compare:
+		atom, neg := cond.Atom(x)
+		f := m.Var(atom.String())
+		if neg {
+			return m.Not(f)
+		}
+		return f
+	case *cond.Not:
+		return m.Not(m.Expr(x.X))
+	case *cond.And:
+		f := True
+		for _, y := range x.Xs {
+			f = m.And(f, m.Expr(y))
+		}
+		return f
+	case *cond.Or:
+		f := False
+		for _, y := range x.Xs {
+			f = m.Or(f, m.Expr(y))
+		}
+		return f
+	}
+	panic(fmt.Errorf("support for condition %T not yet implemented", x))
+}
+
+// Equivalent reports whether the given conditions are equivalent; i.e. whether
+// x holds if and only if y holds.
+func (m *Manager) Equivalent(x, y cond.Expr) bool {
+	return m.Expr(x) == m.Expr(y)
+}
+
+// Complement reports whether the given conditions are complements of each
+// other; i.e. whether x holds if and only if y does not hold.
+func (m *Manager) Complement(x, y cond.Expr) bool {
+	return m.Expr(x) == m.Not(m.Expr(y))
+}
+
+// Implies reports whether x implies y; i.e. whether y holds whenever x holds.
+func (m *Manager) Implies(x, y cond.Expr) bool {
+	return m.And(m.Expr(x), m.Not(m.Expr(y))) == False
+}
+
+// Disjoint reports whether the given conditions are disjoint; i.e. whether x
+// and y never hold at the same time.
+func (m *Manager) Disjoint(x, y cond.Expr) bool {
+	return m.And(m.Expr(x), m.Expr(y)) == False
+}
+
+// mk returns the unique decision node with the given variable and children.
+func (m *Manager) mk(v int32, lo, hi Node) Node {
+	if lo == hi {
+		return lo
+	}
+	n := node{v: v, lo: lo, hi: hi}
+	if f, ok := m.unique[n]; ok {
+		return f
+	}
+	f := Node(len(m.nodes))
+	m.nodes = append(m.nodes, n)
+	m.unique[n] = f
+	return f
+}
+
+// ite returns the node of the formula if f then g else h.
+func (m *Manager) ite(f, g, h Node) Node {
+	// Terminal cases.
+	switch {
+	case f == True:
+		return g
+	case f == False:
+		return h
+	case g == h:
+		return g
+	case g == True && h == False:
+		return f
+	}
+	key := [3]Node{f, g, h}
+	if r, ok := m.ites[key]; ok {
+		return r
+	}
+	// Split on the top variable of the operands.
+	v := m.nodes[f].v
+	if w := m.nodes[g].v; w < v {
+		v = w
+	}
+	if w := m.nodes[h].v; w < v {
+		v = w
+	}
+	f0, f1 := m.cofactors(f, v)
+	g0, g1 := m.cofactors(g, v)
+	h0, h1 := m.cofactors(h, v)
+	r := m.mk(v, m.ite(f0, g0, h0), m.ite(f1, g1, h1))
+	m.ites[key] = r
+	return r
+}
+
+// cofactors returns the negative and positive cofactors of f with regards to
+// the variable v.
+func (m *Manager) cofactors(f Node, v int32) (lo, hi Node) {
+	n := m.nodes[f]
+	if n.v != v {
+		return f, f
+	}
+	return n.lo, n.hi
+}
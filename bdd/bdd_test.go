@@ -0,0 +1,51 @@
+package bdd
+
+import (
+	"testing"
+
+	"github.com/mewmew/pi/cond"
+)
+
+func TestManager(t *testing.T) {
+	golden := []struct {
+		x, y                                      string
+		equivalent, complement, implies, disjoint bool
+	}{
+		{x: "%a", y: "%a", equivalent: true, implies: true},
+		{x: "%a", y: "!%a", complement: true, disjoint: true},
+		{x: "%a || (!%a && %b)", y: "%b || %a", equivalent: true, implies: true},
+		{x: "!(%a && %b)", y: "%b && %a", complement: true, disjoint: true},
+		{x: "x != 1 && x != 2", y: "x == 2 || x == 1", complement: true, disjoint: true},
+		{x: "x >= y", y: "!(x < y)", equivalent: true, implies: true},
+		{x: "%a && %b", y: "%a", implies: true},
+		{x: "%a && %b", y: "!%a && %c", disjoint: true},
+		{x: "%a || %b", y: "%a && %b"},
+		{x: "true", y: "%a || !%a", equivalent: true, implies: true},
+		{x: "false", y: "%a", implies: true, disjoint: true},
+	}
+	for _, gold := range golden {
+		x, err := cond.Parse(gold.x)
+		if err != nil {
+			t.Errorf("%q: unable to parse condition; %v", gold.x, err)
+			continue
+		}
+		y, err := cond.Parse(gold.y)
+		if err != nil {
+			t.Errorf("%q: unable to parse condition; %v", gold.y, err)
+			continue
+		}
+		m := NewManager()
+		if got := m.Equivalent(x, y); got != gold.equivalent {
+			t.Errorf("%q, %q: equivalence mismatch; expected %v, got %v", gold.x, gold.y, gold.equivalent, got)
+		}
+		if got := m.Complement(x, y); got != gold.complement {
+			t.Errorf("%q, %q: complement mismatch; expected %v, got %v", gold.x, gold.y, gold.complement, got)
+		}
+		if got := m.Implies(x, y); got != gold.implies {
+			t.Errorf("%q, %q: implication mismatch; expected %v, got %v", gold.x, gold.y, gold.implies, got)
+		}
+		if got := m.Disjoint(x, y); got != gold.disjoint {
+			t.Errorf("%q, %q: disjointness mismatch; expected %v, got %v", gold.x, gold.y, gold.disjoint, got)
+		}
+	}
+}
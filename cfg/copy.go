@@ -1,23 +1,69 @@
 package cfg
 
+import "github.com/pkg/errors"
+
 // Copy copies nodes and edges as directed edges from the source to the
-// destination without first clearing the destination. Copy will panic if a node
-// ID in the source graph matches a node ID in the destination.
-func Copy(dst, src *Graph) {
+// destination without first clearing the destination. An error is returned if
+// a node ID or node name in the source graph matches that of a node in the
+// destination.
+//
+// Nodes and edges are copied along with their DOT attributes, depth first
+// search order and control flow primitive annotations; thus the nodes and edges
+// of the source graph are left unmodified by changes to the destination.
+func Copy(dst, src *Graph) error {
 	dst.id = src.id
+	// copies maps from source node to the copy of the node.
+	copies := make(map[*Node]*Node)
+	var ns []*Node
 	nodes := src.Nodes()
 	for nodes.Next() {
-		n := nodes.Node()
-		dst.AddNode(n)
+		n := node(nodes.Node())
+		c := &Node{}
+		*c = *n
+		c.Attrs = copyAttrs(n.Attrs)
+		copies[n] = c
+		ns = append(ns, n)
 	}
-	nodes.Reset()
-	for nodes.Next() {
-		u := nodes.Node()
+	// Control flow primitive annotations refer to the copies of nodes.
+	remap := func(n *Node) *Node {
+		if c, ok := copies[n]; ok {
+			return c
+		}
+		return n
+	}
+	for _, n := range ns {
+		c := copies[n]
+		c.LoopHead = remap(n.LoopHead)
+		c.Latch = remap(n.Latch)
+		c.LoopFollow = remap(n.LoopFollow)
+		c.IfFollow = remap(n.IfFollow)
+		c.SwitchHead = remap(n.SwitchHead)
+		c.SwitchFollow = remap(n.SwitchFollow)
+		if err := dst.AddNode(c); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	for _, u := range ns {
 		from := src.From(u.ID())
 		for from.Next() {
-			v := from.Node()
-			dst.SetEdge(src.Edge(u.ID(), v.ID()))
+			v := node(from.Node())
+			e := edge(src.Edge(u.ID(), v.ID()))
+			c := edge(dst.NewEdge(copies[u], copies[v]))
+			c.Kind = e.Kind
+			c.Values = append([]string(nil), e.Values...)
+			c.DFSKind = e.DFSKind
+			c.Attrs = copyAttrs(e.Attrs)
+			dst.SetEdge(c)
 		}
 	}
-	dst.initNodes()
+	return dst.initNodes()
+}
+
+// copyAttrs returns a copy of the given DOT attributes.
+func copyAttrs(attrs Attrs) Attrs {
+	c := make(Attrs, len(attrs))
+	for key, val := range attrs {
+		c[key] = val
+	}
+	return c
 }
@@ -1,11 +1,11 @@
 package cfg
 
 import (
-	"fmt"
 	"io"
 	"io/ioutil"
 
 	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/encoding/dot"
 )
 
@@ -33,18 +33,24 @@ func ParseFile(path string) (*Graph, error) {
 // reading from b.
 func ParseBytes(b []byte) (*Graph, error) {
 	g := NewGraph()
-	if err := dot.Unmarshal(b, g); err != nil {
+	dg := &dotGraph{Graph: g}
+	if err := dot.Unmarshal(b, dg); err != nil {
 		return nil, errors.WithStack(err)
 	}
+	if dg.err != nil {
+		return nil, errors.WithStack(dg.err)
+	}
 	// Initialize mapping between node names and graph nodes.
-	g.initNodes()
+	if err := g.initNodes(); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	nodes := g.Nodes()
 	for nodes.Next() {
 		n := nodes.Node()
 		nn := node(n)
 		if nn.entry {
 			if g.entry != nil && nn != g.entry {
-				panic(fmt.Errorf("entry node already set in graph; prev entry node %#v, new entry node %#v", g.entry, nn))
+				return nil, errors.Wrapf(ErrDuplicateEntry, "prev entry node %q, new entry node %q", node(g.entry).DOTID(), nn.DOTID())
 			}
 			g.entry = nn
 		}
@@ -52,13 +58,30 @@ func ParseBytes(b []byte) (*Graph, error) {
 	if g.entry == nil {
 		n, ok := g.NodeWithName(`"0"`)
 		if !ok {
-			panic(`unable to locate entry note or node with name "0"`)
+			return nil, errors.Wrap(ErrNoEntry, `unable to locate entry node or node with name "0"`)
+		}
+		if err := g.SetEntry(n); err != nil {
+			return nil, errors.WithStack(err)
 		}
-		g.SetEntry(n)
 	}
 	return g, nil
 }
 
+// dotGraph is a control flow graph being decoded from Graphviz DOT format,
+// which records the first error encountered while adding nodes.
+type dotGraph struct {
+	*Graph
+	// First error encountered while adding nodes.
+	err error
+}
+
+// AddNode adds a node to the graph; implements graph.NodeAdder.
+func (g *dotGraph) AddNode(n graph.Node) {
+	if err := g.Graph.AddNode(n); err != nil && g.err == nil {
+		g.err = err
+	}
+}
+
 // ParseString parses the given Graphviz DOT file into a control flow graph,
 // reading from s.
 func ParseString(s string) (*Graph, error) {
@@ -0,0 +1,242 @@
+package cfg
+
+import (
+	"sort"
+
+	"github.com/mewkiz/pkg/natsort"
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Depth first search ] ==================================================
+
+//go:generate stringer -type DFSEdgeKind -linecomment
+
+// DFSEdgeKind specifies the depth first search classification of a control
+// flow graph edge.
+type DFSEdgeKind uint
+
+// Depth first search edge kinds.
+const (
+	// Edge not yet classified.
+	DFSEdgeKindNone DFSEdgeKind = iota // none
+	// Edge of the depth first search spanning tree.
+	DFSEdgeKindTree // tree
+	// Edge from a node to a non-child descendant of the node in the spanning
+	// tree.
+	DFSEdgeKindForward // forward
+	// Edge from a node to an ancestor of the node in the spanning tree
+	// (including self-loops); also known as retreating edge.
+	DFSEdgeKindBack // back
+	// Edge between nodes of which neither is an ancestor of the other in the
+	// spanning tree.
+	DFSEdgeKindCross // cross
+)
+
+// DFSTree is a depth first search spanning tree of a control flow graph.
+type DFSTree struct {
+	// Root nodes of the spanning forest; the entry node followed by the nodes
+	// not reachable from previous root nodes, sorted by DOT ID.
+	Roots []*Node
+	// Nodes of the control flow graph.
+	nodes []*Node
+	// index maps from node to index in nodes.
+	index map[*Node]int32
+	// Index of the parent node of each node in the spanning tree; or -1 if root
+	// node.
+	parent []int32
+	// Indices of the successors of each node, sorted by DOT ID; the successors
+	// of nodes[i] are succs[start[i]:start[i+1]].
+	succs []int32
+	start []int32
+	// Edge kind of each successor in succs.
+	kinds []DFSEdgeKind
+	// Edges of the control flow graph, in visit order.
+	edges []*Edge
+}
+
+// InitDFSOrder initializes the pre- and post depth first search visit order of
+// each node, and classifies each edge as tree, forward, back or cross edge (see
+// Edge.DFSKind). The number of back edges to each node (see Node.NBackEdges)
+// and whether the node is the source of a back edge (see Node.IsLatch) are
+// initialized as well.
+//
+// The successors of each node are visited in order of DOT ID, starting from the
+// entry node. Nodes not reachable from the entry node are visited thereafter.
+// The depth first search spanning tree is returned.
+//
+// The search is iterative, using an explicit stack; thus control flow graphs
+// with long chains of nodes do not result in deep recursion.
+func InitDFSOrder(g *Graph) *DFSTree {
+	t := newDFSTree(g)
+	for _, n := range t.nodes {
+		n.NBackEdges = 0
+		n.IsLatch = false
+	}
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make([]uint8, len(t.nodes))
+	// Stack of active nodes, and the index of the next successor to visit.
+	type frame struct {
+		i, next int32
+	}
+	stack := make([]frame, 0, 64)
+	first := 0
+	last := len(t.nodes) - 1
+	push := func(i int32) {
+		t.nodes[i].Pre = first
+		first++
+		state[i] = active
+		stack = append(stack, frame{i: i, next: t.start[i]})
+	}
+	walk := func(root int32) {
+		t.Roots = append(t.Roots, t.nodes[root])
+		push(root)
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			i := top.i
+			n := t.nodes[i]
+			if top.next == t.start[i+1] {
+				// post-order
+				state[i] = done
+				n.RevPost = last
+				last--
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			k := top.next
+			top.next++
+			j := t.succs[k]
+			succ := t.nodes[j]
+			e := edge(g.Edge(n.ID(), succ.ID()))
+			t.edges = append(t.edges, e)
+			switch {
+			case state[j] == unvisited:
+				e.DFSKind = DFSEdgeKindTree
+				t.parent[j] = i
+				push(j)
+			case state[j] == active:
+				e.DFSKind = DFSEdgeKindBack
+				succ.NBackEdges++
+				n.IsLatch = true
+			case n.Pre < succ.Pre:
+				e.DFSKind = DFSEdgeKindForward
+			default:
+				e.DFSKind = DFSEdgeKindCross
+			}
+			t.kinds[k] = e.DFSKind
+		}
+	}
+	if g.entry != nil {
+		walk(t.index[node(g.entry)])
+	}
+	// Ensure that all nodes have been visited.
+	var rest []graph.Node
+	for i, n := range t.nodes {
+		if state[i] == unvisited {
+			rest = append(rest, n)
+		}
+	}
+	for _, n := range sortByDOTID(rest) {
+		if i := t.index[node(n)]; state[i] == unvisited {
+			walk(i)
+		}
+	}
+	return t
+}
+
+// newDFSTree returns a new depth first search spanning tree of the given
+// control flow graph, with the successors of each node sorted by DOT ID, and no
+// edges visited.
+func newDFSTree(g *Graph) *DFSTree {
+	nodes := nodesOf(graph.NodesOf(g.Nodes()))
+	t := &DFSTree{
+		nodes:  nodes,
+		index:  make(map[*Node]int32, len(nodes)),
+		parent: make([]int32, len(nodes)),
+		start:  make([]int32, len(nodes)+1),
+	}
+	for i, n := range nodes {
+		t.index[n] = int32(i)
+		t.parent[i] = -1
+	}
+	for i, n := range nodes {
+		t.start[i] = int32(len(t.succs))
+		succs := g.From(n.ID())
+		for succs.Next() {
+			t.succs = append(t.succs, t.index[node(succs.Node())])
+		}
+		t.sortByDOTID(t.succs[t.start[i]:])
+	}
+	t.start[len(nodes)] = int32(len(t.succs))
+	t.kinds = make([]DFSEdgeKind, len(t.succs))
+	t.edges = make([]*Edge, 0, len(t.succs))
+	return t
+}
+
+// sortByDOTID sorts the given node indices by the DOT ID of the nodes.
+func (t *DFSTree) sortByDOTID(is []int32) {
+	less := func(i, j int) bool {
+		return natsort.Less(t.nodes[is[i]].name, t.nodes[is[j]].name)
+	}
+	if len(is) > 12 {
+		sort.Slice(is, less)
+		return
+	}
+	// Insertion sort; nodes typically have few successors.
+	for i := 1; i < len(is); i++ {
+		for j := i; j > 0 && less(j, j-1); j-- {
+			is[j], is[j-1] = is[j-1], is[j]
+		}
+	}
+}
+
+// Parent returns the parent node of the given node in the spanning tree; or nil
+// if root node.
+func (t *DFSTree) Parent(n *Node) *Node {
+	i, ok := t.index[n]
+	if !ok || t.parent[i] == -1 {
+		return nil
+	}
+	return t.nodes[t.parent[i]]
+}
+
+// Children returns the child nodes of the given node in the spanning tree, in
+// visit order.
+func (t *DFSTree) Children(n *Node) []*Node {
+	i, ok := t.index[n]
+	if !ok {
+		return nil
+	}
+	var children []*Node
+	for k := t.start[i]; k < t.start[i+1]; k++ {
+		if t.kinds[k] == DFSEdgeKindTree {
+			children = append(children, t.nodes[t.succs[k]])
+		}
+	}
+	return children
+}
+
+// IsAncestor reports whether the node a is an ancestor of the node b in the
+// spanning tree. A node is an ancestor of itself.
+func (t *DFSTree) IsAncestor(a, b *Node) bool {
+	for n := b; n != nil; n = t.Parent(n) {
+		if n == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Edges returns the edges of the given kind, in visit order.
+func (t *DFSTree) Edges(kind DFSEdgeKind) []*Edge {
+	var es []*Edge
+	for _, e := range t.edges {
+		if e.DFSKind == kind {
+			es = append(es, e)
+		}
+	}
+	return es
+}
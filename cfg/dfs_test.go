@@ -0,0 +1,181 @@
+package cfg
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestDFSTree(t *testing.T) {
+	golden := []struct {
+		path string
+		// Root nodes of the spanning forest.
+		roots []string
+		// Edges of each kind.
+		want map[DFSEdgeKind][]string
+		// Parent of each non-root node.
+		parents map[string]string
+		// Number of back edges to each node with back edges.
+		nbackEdges map[string]int
+		// Latch nodes.
+		latches []string
+	}{
+		{
+			path:  "testdata/sample.dot",
+			roots: []string{"B1"},
+			want: map[DFSEdgeKind][]string{
+				DFSEdgeKindTree:    {"B1 -> B2", "B2 -> B3", "B3 -> B5", "B5 -> B6", "B6 -> B7", "B7 -> B8", "B8 -> B9", "B9 -> B10", "B10 -> B11", "B6 -> B12", "B12 -> B13", "B13 -> B14", "B14 -> B15", "B2 -> B4"},
+				DFSEdgeKindForward: {"B8 -> B10", "B7 -> B9", "B1 -> B5"},
+				DFSEdgeKindBack:    {"B14 -> B13", "B15 -> B6"},
+				DFSEdgeKindCross:   {"B4 -> B5"},
+			},
+			parents: map[string]string{
+				"B2":  "B1",
+				"B3":  "B2",
+				"B4":  "B2",
+				"B5":  "B3",
+				"B6":  "B5",
+				"B7":  "B6",
+				"B8":  "B7",
+				"B9":  "B8",
+				"B10": "B9",
+				"B11": "B10",
+				"B12": "B6",
+				"B13": "B12",
+				"B14": "B13",
+				"B15": "B14",
+			},
+			nbackEdges: map[string]int{"B6": 1, "B13": 1},
+			latches:    []string{"B14", "B15"},
+		},
+		{
+			path:  "testdata/irreducible.dot",
+			roots: []string{"A"},
+			want: map[DFSEdgeKind][]string{
+				DFSEdgeKindTree:    {"A -> B", "B -> C", "C -> D"},
+				DFSEdgeKindForward: {"A -> C"},
+				DFSEdgeKindBack:    {"C -> B"},
+			},
+			parents: map[string]string{
+				"B": "A",
+				"C": "B",
+				"D": "C",
+			},
+			nbackEdges: map[string]int{"B": 1},
+			latches:    []string{"C"},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		tree := InitDFSOrder(in)
+		// Check edge classification.
+		for _, kind := range []DFSEdgeKind{DFSEdgeKindTree, DFSEdgeKindForward, DFSEdgeKindBack, DFSEdgeKindCross} {
+			var got []string
+			for _, e := range tree.Edges(kind) {
+				got = append(got, edgeNames([]*Edge{e}))
+			}
+			if want := gold.want[kind]; !reflect.DeepEqual(got, want) {
+				t.Errorf("%q; %v edges mismatch; expected %v, got %v", gold.path, kind, want, got)
+			}
+		}
+		// Check spanning tree.
+		if got := names(tree.Roots); !reflect.DeepEqual(got, gold.roots) {
+			t.Errorf("%q; root nodes mismatch; expected %v, got %v", gold.path, gold.roots, got)
+		}
+		parents := make(map[string]string)
+		nbackEdges := make(map[string]int)
+		var latches []string
+		for _, n := range nodesOf(sortByDOTID(graph.NodesOf(in.Nodes()))) {
+			if p := tree.Parent(n); p != nil {
+				parents[n.name] = p.name
+				if !containsNode(tree.Children(p), n) {
+					t.Errorf("%q; node %q missing from children of parent %q", gold.path, n.name, p.name)
+				}
+			}
+			if n.NBackEdges > 0 {
+				nbackEdges[n.name] = n.NBackEdges
+			}
+			if n.IsLatch {
+				latches = append(latches, n.name)
+			}
+		}
+		if !reflect.DeepEqual(parents, gold.parents) {
+			t.Errorf("%q; parents mismatch; expected %v, got %v", gold.path, gold.parents, parents)
+		}
+		if !reflect.DeepEqual(nbackEdges, gold.nbackEdges) {
+			t.Errorf("%q; number of back edges mismatch; expected %v, got %v", gold.path, gold.nbackEdges, nbackEdges)
+		}
+		if !reflect.DeepEqual(latches, gold.latches) {
+			t.Errorf("%q; latch nodes mismatch; expected %v, got %v", gold.path, gold.latches, latches)
+		}
+	}
+}
+
+func BenchmarkInitDFSOrderChain(b *testing.B) {
+	g := chainGraph(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InitDFSOrder(g)
+	}
+}
+
+func BenchmarkInitDFSOrderStateMachine(b *testing.B) {
+	g := stateMachineGraph(100000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		InitDFSOrder(g)
+	}
+}
+
+// chainGraph returns a control flow graph of n nodes in a chain, with a back
+// edge from the last node to the entry node.
+func chainGraph(n int) *Graph {
+	g := NewGraph()
+	nodes := benchNodes(g, n)
+	for i := 0; i < n-1; i++ {
+		edgeWithKind(g, nodes[i], nodes[i+1], EdgeKindUncond, "")
+	}
+	edgeWithKind(g, nodes[n-1], nodes[0], EdgeKindUncond, "")
+	return g
+}
+
+// stateMachineGraph returns a control flow graph of n nodes resembling a state
+// machine; i.e. a chain of 2-way conditionals, where each node branches to the
+// next node and to a node further down the chain, and every eighth node loops
+// back to an earlier node.
+func stateMachineGraph(n int) *Graph {
+	g := NewGraph()
+	nodes := benchNodes(g, n)
+	for i := 0; i < n-1; i++ {
+		edgeWithKind(g, nodes[i], nodes[i+1], EdgeKindTrue, "")
+		switch {
+		case i%8 == 7:
+			edgeWithKind(g, nodes[i], nodes[i-7], EdgeKindFalse, "")
+		case i+3 < n:
+			edgeWithKind(g, nodes[i], nodes[i+3], EdgeKindFalse, "")
+		}
+	}
+	return g
+}
+
+// benchNodes adds n nodes to the given control flow graph, the first of which
+// is the entry node.
+func benchNodes(g *Graph, n int) []*Node {
+	nodes := make([]*Node, n)
+	for i := range nodes {
+		nodes[i] = nodeWithName(g, fmt.Sprintf("B%d", i))
+	}
+	if err := g.SetEntry(nodes[0]); err != nil {
+		panic(err)
+	}
+	return nodes
+}
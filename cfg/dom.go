@@ -0,0 +1,307 @@
+package cfg
+
+import (
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Dominator tree ] ======================================================
+
+// DomTree is a dominator tree or post-dominator tree of a control flow graph.
+type DomTree struct {
+	// Root node of the tree; the entry node of dominator trees, and the exit
+	// node of post-dominator trees.
+	root *Node
+	// idom maps from node to immediate dominator; nil for the root node and
+	// for nodes unreachable from the root node.
+	idom map[*Node]*Node
+	// children maps from node to the nodes it immediately dominates, sorted by
+	// DOT ID.
+	children map[*Node][]*Node
+	// frontier maps from node to its dominance frontier, sorted by DOT ID.
+	frontier map[*Node][]*Node
+}
+
+// NewDomTree returns the dominator tree of the given control flow graph, rooted
+// at the entry node.
+//
+// The dominator tree is computed using the iterative algorithm of Cooper,
+// Harvey and Kennedy [1].
+//
+// [1]: https://www.cs.rice.edu/~keith/EMBED/dom.pdf
+func NewDomTree(g *Graph) *DomTree {
+	nodes := sortByDOTID(graph.NodesOf(g.Nodes()))
+	succs := func(n *Node) []*Node {
+		return nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID()))))
+	}
+	preds := func(n *Node) []*Node {
+		return nodesOf(sortByDOTID(graph.NodesOf(g.To(n.ID()))))
+	}
+	var root *Node
+	if g.entry != nil {
+		root = node(g.entry)
+	}
+	return newDomTree(nodesOf(nodes), root, succs, preds)
+}
+
+// NewPostDomTree returns the post-dominator tree of the given control flow
+// graph, rooted at the exit node.
+//
+// For control flow graphs with more than one exit node (i.e. nodes without
+// successors, such as basic blocks terminated by ret or unreachable), the root
+// is a virtual exit node not present in the graph, which post-dominates every
+// exit node. Nodes from which no exit node is reachable (e.g. endless loops)
+// are connected to the virtual exit node.
+func NewPostDomTree(g *Graph) *DomTree {
+	nodes := nodesOf(sortByDOTID(graph.NodesOf(g.Nodes())))
+	succs := func(n *Node) []*Node {
+		return nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID()))))
+	}
+	preds := func(n *Node) []*Node {
+		return nodesOf(sortByDOTID(graph.NodesOf(g.To(n.ID()))))
+	}
+	var exits []*Node
+	for _, n := range nodes {
+		if g.From(n.ID()).Len() == 0 {
+			exits = append(exits, n)
+		}
+	}
+	// Nodes reaching an exit node.
+	reaching := make(map[*Node]bool)
+	markReaching := func(n *Node) {
+		stack := []*Node{n}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if reaching[n] {
+				continue
+			}
+			reaching[n] = true
+			stack = append(stack, preds(n)...)
+		}
+	}
+	for _, exit := range exits {
+		markReaching(exit)
+	}
+	// Connect nodes not reaching an exit node to the virtual exit node; picking
+	// the last node in reverse post-order of each such region.
+	if len(reaching) < len(nodes) && g.entry != nil {
+		order := revPostOrder(node(g.entry), succs)
+		for i := len(order) - 1; i >= 0; i-- {
+			if n := order[i]; !reaching[n] {
+				exits = append(exits, n)
+				markReaching(n)
+			}
+		}
+	}
+	if len(exits) == 1 {
+		return newDomTree(nodes, exits[0], preds, succs)
+	}
+	// Synthesize virtual exit node.
+	exit := g.NewNodeWithName("virtual_exit")
+	isExit := make(map[*Node]bool)
+	for _, n := range exits {
+		isExit[n] = true
+	}
+	rsuccs := func(n *Node) []*Node {
+		if n == exit {
+			return exits
+		}
+		return preds(n)
+	}
+	rpreds := func(n *Node) []*Node {
+		ss := succs(n)
+		if isExit[n] {
+			ss = append(ss, exit)
+		}
+		return ss
+	}
+	return newDomTree(append(nodes, exit), exit, rsuccs, rpreds)
+}
+
+// newDomTree returns the dominator tree of the graph with the given nodes,
+// rooted at root, where edges are specified by the succs and preds functions.
+func newDomTree(nodes []*Node, root *Node, succs, preds func(n *Node) []*Node) *DomTree {
+	dt := &DomTree{
+		root:     root,
+		idom:     make(map[*Node]*Node),
+		children: make(map[*Node][]*Node),
+		frontier: make(map[*Node][]*Node),
+	}
+	if root == nil {
+		return dt
+	}
+	// Compute reverse post-order numbering.
+	order := revPostOrder(root, succs)
+	index := make(map[*Node]int)
+	for i, n := range order {
+		index[n] = i
+	}
+	intersect := func(a, b *Node) *Node {
+		for a != b {
+			for index[a] > index[b] {
+				a = dt.idom[a]
+			}
+			for index[b] > index[a] {
+				b = dt.idom[b]
+			}
+		}
+		return a
+	}
+	// Compute immediate dominators.
+	dt.idom[root] = root
+	for changed := true; changed; {
+		changed = false
+		for _, n := range order[1:] {
+			var newIdom *Node
+			for _, pred := range preds(n) {
+				if _, ok := index[pred]; !ok || dt.idom[pred] == nil {
+					continue
+				}
+				if newIdom == nil {
+					newIdom = pred
+					continue
+				}
+				newIdom = intersect(pred, newIdom)
+			}
+			if dt.idom[n] != newIdom {
+				dt.idom[n] = newIdom
+				changed = true
+			}
+		}
+	}
+	delete(dt.idom, root)
+	// Compute dominator tree children.
+	for _, n := range nodes {
+		if d, ok := dt.idom[n]; ok {
+			dt.children[d] = append(dt.children[d], n)
+		}
+	}
+	// Compute dominance frontiers.
+	for _, n := range order {
+		var ps []*Node
+		for _, pred := range preds(n) {
+			if _, ok := index[pred]; ok {
+				ps = append(ps, pred)
+			}
+		}
+		if len(ps) < 2 {
+			continue
+		}
+		for _, pred := range ps {
+			for runner := pred; runner != nil && runner != dt.idom[n]; runner = dt.idom[runner] {
+				if !containsNode(dt.frontier[runner], n) {
+					dt.frontier[runner] = append(dt.frontier[runner], n)
+				}
+			}
+		}
+	}
+	for n, df := range dt.frontier {
+		dt.frontier[n] = nodesOf(sortByDOTID(graphNodesOf(df)))
+	}
+	return dt
+}
+
+// Root returns the root node of the dominator tree; i.e. the entry node of
+// dominator trees, and the (possibly virtual) exit node of post-dominator
+// trees.
+func (dt *DomTree) Root() *Node {
+	return dt.root
+}
+
+// Idom returns the immediate dominator of n, or nil if n is the root node or
+// unreachable from the root node.
+func (dt *DomTree) Idom(n *Node) *Node {
+	return dt.idom[n]
+}
+
+// Dominates reports whether a dominates b. Every node dominates itself.
+func (dt *DomTree) Dominates(a, b *Node) bool {
+	if b != dt.root && dt.idom[b] == nil {
+		// b unreachable from root.
+		return false
+	}
+	for ; b != nil; b = dt.idom[b] {
+		if a == b {
+			return true
+		}
+	}
+	return false
+}
+
+// StrictlyDominates reports whether a dominates b and a is not equal to b.
+func (dt *DomTree) StrictlyDominates(a, b *Node) bool {
+	return a != b && dt.Dominates(a, b)
+}
+
+// Children returns the nodes immediately dominated by n, sorted by DOT ID.
+func (dt *DomTree) Children(n *Node) []*Node {
+	return dt.children[n]
+}
+
+// Frontier returns the dominance frontier of n, sorted by DOT ID; i.e. the
+// nodes b such that n dominates a predecessor of b but does not strictly
+// dominate b.
+func (dt *DomTree) Frontier(n *Node) []*Node {
+	return dt.frontier[n]
+}
+
+// ### [ Helper functions ] ####################################################
+
+// revPostOrder returns the nodes reachable from root in reverse post-order, as
+// visited by a depth first search following the given successor function.
+func revPostOrder(root *Node, succs func(n *Node) []*Node) []*Node {
+	visited := map[*Node]bool{root: true}
+	var post []*Node
+	type frame struct {
+		n     *Node
+		succs []*Node
+	}
+	stack := []*frame{{n: root, succs: succs(root)}}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if len(top.succs) == 0 {
+			post = append(post, top.n)
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		succ := top.succs[0]
+		top.succs = top.succs[1:]
+		if !visited[succ] {
+			visited[succ] = true
+			stack = append(stack, &frame{n: succ, succs: succs(succ)})
+		}
+	}
+	order := make([]*Node, len(post))
+	for i, n := range post {
+		order[len(post)-1-i] = n
+	}
+	return order
+}
+
+// nodesOf returns the given graph nodes as control flow graph nodes.
+func nodesOf(ns []graph.Node) []*Node {
+	nodes := make([]*Node, len(ns))
+	for i, n := range ns {
+		nodes[i] = node(n)
+	}
+	return nodes
+}
+
+// graphNodesOf returns the given control flow graph nodes as graph nodes.
+func graphNodesOf(ns []*Node) []graph.Node {
+	nodes := make([]graph.Node, len(ns))
+	for i, n := range ns {
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// containsNode reports whether the given list of nodes contains n.
+func containsNode(ns []*Node, n *Node) bool {
+	for _, nn := range ns {
+		if nn == n {
+			return true
+		}
+	}
+	return false
+}
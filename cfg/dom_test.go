@@ -0,0 +1,188 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDomTree(t *testing.T) {
+	golden := []struct {
+		path string
+		// Immediate dominator of each node.
+		idom map[string]string
+		// Dominance frontier of each node with a non-empty frontier.
+		frontier map[string][]string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			idom: map[string]string{
+				"B1":  "",
+				"B2":  "B1",
+				"B3":  "B2",
+				"B4":  "B2",
+				"B5":  "B1",
+				"B6":  "B5",
+				"B7":  "B6",
+				"B8":  "B7",
+				"B9":  "B7",
+				"B10": "B7",
+				"B11": "B10",
+				"B12": "B6",
+				"B13": "B12",
+				"B14": "B13",
+				"B15": "B14",
+			},
+			frontier: map[string][]string{
+				"B2":  {"B5"},
+				"B3":  {"B5"},
+				"B4":  {"B5"},
+				"B6":  {"B6"},
+				"B8":  {"B9", "B10"},
+				"B9":  {"B10"},
+				"B12": {"B6"},
+				"B13": {"B6", "B13"},
+				"B14": {"B6", "B13"},
+				"B15": {"B6"},
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		dt := NewDomTree(in)
+		checkDomTree(t, in, dt, gold.path, gold.idom, gold.frontier)
+	}
+}
+
+func TestPostDomTree(t *testing.T) {
+	golden := []struct {
+		path string
+		// Immediate post-dominator of each node.
+		ipdom map[string]string
+		// Post-dominance frontier of each node with a non-empty frontier.
+		frontier map[string][]string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			ipdom: map[string]string{
+				"B1":  "B5",
+				"B2":  "B5",
+				"B3":  "B5",
+				"B4":  "B5",
+				"B5":  "B6",
+				"B6":  "B7",
+				"B7":  "B10",
+				"B8":  "B10",
+				"B9":  "B10",
+				"B10": "B11",
+				"B11": "",
+				"B12": "B13",
+				"B13": "B14",
+				"B14": "B15",
+				"B15": "B6",
+			},
+			frontier: map[string][]string{
+				"B2":  {"B1"},
+				"B3":  {"B2"},
+				"B4":  {"B2"},
+				"B6":  {"B6"},
+				"B8":  {"B7"},
+				"B9":  {"B7", "B8"},
+				"B12": {"B6"},
+				"B13": {"B6", "B14"},
+				"B14": {"B6", "B14"},
+				"B15": {"B6"},
+			},
+		},
+		{
+			// Graph with 2-way and n-way conditionals.
+			path: "testdata/kinds.dot",
+			ipdom: map[string]string{
+				"A": "F",
+				"B": "D",
+				"C": "F",
+				"D": "F",
+				"E": "F",
+				"F": "",
+			},
+			frontier: map[string][]string{
+				"B": {"A"},
+				"C": {"A"},
+				"D": {"A", "C"},
+				"E": {"C"},
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		dt := NewPostDomTree(in)
+		checkDomTree(t, in, dt, gold.path, gold.ipdom, gold.frontier)
+	}
+}
+
+func TestPostDomTreeVirtualExit(t *testing.T) {
+	in, err := ParseString("digraph G { A [label=entry]; A -> B; A -> C; }")
+	if err != nil {
+		t.Fatalf("unable to parse graph; %v", err)
+	}
+	dt := NewPostDomTree(in)
+	exit := dt.Root()
+	if exit.DOTID() != "virtual_exit" {
+		t.Fatalf("root mismatch; expected virtual exit node, got %q", exit.DOTID())
+	}
+	for _, name := range []string{"A", "B", "C"} {
+		n := in.nodeWithName(name)
+		if !dt.Dominates(exit, n) {
+			t.Errorf("%q not post-dominated by virtual exit node", name)
+		}
+	}
+	if got, want := dt.Idom(in.nodeWithName("A")), exit; got != want {
+		t.Errorf("immediate post-dominator mismatch of %q; expected %q, got %q", "A", want.DOTID(), got.DOTID())
+	}
+}
+
+// checkDomTree checks the immediate dominators and dominance frontiers of the
+// given dominator tree against the expected results.
+func checkDomTree(t *testing.T, g *Graph, dt *DomTree, path string, idom map[string]string, frontier map[string][]string) {
+	gotIdom := make(map[string]string)
+	gotFrontier := make(map[string][]string)
+	for name, n := range g.nodes {
+		if d := dt.Idom(n); d != nil {
+			gotIdom[name] = d.DOTID()
+			if !dt.Dominates(d, n) || !dt.StrictlyDominates(d, n) {
+				t.Errorf("%q; %q does not dominate %q", path, d.DOTID(), name)
+			}
+			if !containsNode(dt.Children(d), n) {
+				t.Errorf("%q; %q not a child of %q", path, name, d.DOTID())
+			}
+		} else {
+			gotIdom[name] = ""
+		}
+		for _, df := range dt.Frontier(n) {
+			gotFrontier[name] = append(gotFrontier[name], df.DOTID())
+		}
+	}
+	if !reflect.DeepEqual(gotIdom, idom) {
+		t.Errorf("%q; immediate dominator mismatch; expected `%v`, got `%v`", path, idom, gotIdom)
+	}
+	if !reflect.DeepEqual(gotFrontier, frontier) {
+		t.Errorf("%q; dominance frontier mismatch; expected `%v`, got `%v`", path, frontier, gotFrontier)
+	}
+}
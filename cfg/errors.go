@@ -0,0 +1,23 @@
+package cfg
+
+import "github.com/pkg/errors"
+
+// Errors returned by the control flow graph API. Use errors.Cause to compare a
+// returned error against these values.
+var (
+	// ErrNoEntry is returned if the entry node of a control flow graph cannot be
+	// located.
+	ErrNoEntry = errors.New("unable to locate entry node")
+	// ErrDuplicateEntry is returned if more than one entry node is present in a
+	// control flow graph.
+	ErrDuplicateEntry = errors.New("entry node already present")
+	// ErrDuplicateNode is returned if a node with the same name or ID is already
+	// present in a control flow graph.
+	ErrDuplicateNode = errors.New("node already present")
+	// ErrMissingNodeName is returned if a node of a control flow graph lacks a
+	// name.
+	ErrMissingNodeName = errors.New("missing node name")
+	// ErrUnsupportedTerminator is returned if a basic block is terminated by a
+	// terminator not supported by NewGraphFromFunc.
+	ErrUnsupportedTerminator = errors.New("unsupported terminator")
+)
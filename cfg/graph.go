@@ -11,8 +11,11 @@ import (
 
 	"github.com/graphism/simple"
 	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
 	"github.com/llir/llvm/ir/value"
 	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/pi/cond"
 	"github.com/pkg/errors"
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/encoding"
@@ -51,47 +54,195 @@ func NewGraph() *Graph {
 
 // NewGraphFromFunc returns a new control flow graph based on the given
 // function.
-func NewGraphFromFunc(f *ir.Func) *Graph {
+func NewGraphFromFunc(f *ir.Func) (*Graph, error) {
 	g := NewGraph()
 	// Force generate local IDs.
 	_ = f.String()
+	// Add one node per basic block.
 	for i, block := range f.Blocks {
-		from := nodeWithName(g, localIdent(block))
+		n := g.NewNodeWithName(localIdent(block))
+		if err := g.AddNode(n); err != nil {
+			return nil, errors.Wrapf(err, "unable to add node of basic block in function %q", f.Name())
+		}
 		if i == 0 {
 			// Store entry node.
-			g.SetEntry(from)
+			if err := g.SetEntry(n); err != nil {
+				return nil, errors.WithStack(err)
+			}
 		}
+	}
+	// Add edges based on the terminator of each basic block.
+	for _, block := range f.Blocks {
+		from := nodeWithName(g, localIdent(block))
 		switch term := block.Term.(type) {
 		case *ir.TermRet:
 			// nothing to do.
 		case *ir.TermBr:
 			to := nodeWithName(g, localIdent(term.Target))
-			edgeWithLabel(g, from, to, "")
+			edgeWithKind(g, from, to, EdgeKindUncond, "")
 		case *ir.TermCondBr:
 			t := nodeWithName(g, localIdent(term.TargetTrue))
 			f := nodeWithName(g, localIdent(term.TargetFalse))
-			trueCond := localIdent(term.Cond)
-			falseCond := fmt.Sprintf("!%v", trueCond)
-			edgeWithLabel(g, from, t, trueCond)
-			edgeWithLabel(g, from, f, falseCond)
+			if t == f {
+				// Both branches target the same basic block.
+				edgeWithKind(g, from, t, EdgeKindUncond, "")
+				break
+			}
+			trueCond := branchCond(block, term)
+			falseCond := cond.Negate(trueCond)
+			if _, ok := trueCond.(*cond.Compare); ok && len(block.Insts) == 1 {
+				// The basic block only evaluates the comparison of its branching
+				// condition.
+				from.Attrs["guard"] = "true"
+			}
+			edgeWithKind(g, from, t, EdgeKindTrue, trueCond.String())
+			edgeWithKind(g, from, f, EdgeKindFalse, falseCond.String())
 		case *ir.TermSwitch:
-			var defaultConds []string
+			x := localIdent(term.X)
+			defaultTarget := localIdent(term.TargetDefault)
+			// Group case values by target, as multiple cases may share the same
+			// target basic block.
+			var targets []string
+			values := make(map[string][]string)
+			defaultCond := &cond.And{}
 			for _, c := range term.Cases {
-				to := nodeWithName(g, localIdent(c.Target))
-				caseCond := fmt.Sprintf("%v == %v", localIdent(term.X), localIdent(c.X))
-				edgeWithLabel(g, from, to, caseCond)
-				defaultConds = append(defaultConds, fmt.Sprintf("%v != %v", localIdent(term.X), localIdent(c.X)))
+				target := localIdent(c.Target)
+				if target == defaultTarget {
+					// Cases targeting the default basic block are subsumed by the
+					// default edge.
+					continue
+				}
+				if _, ok := values[target]; !ok {
+					targets = append(targets, target)
+				}
+				values[target] = append(values[target], localIdent(c.X))
+				defaultCond.Xs = append(defaultCond.Xs, &cond.Compare{Op: cond.CmpNe, X: x, Y: localIdent(c.X)})
+			}
+			for _, target := range targets {
+				to := nodeWithName(g, target)
+				caseCond := &cond.Or{}
+				for _, v := range values[target] {
+					caseCond.Xs = append(caseCond.Xs, &cond.Compare{Op: cond.CmpEq, X: x, Y: v})
+				}
+				e := edgeWithKind(g, from, to, EdgeKindCase, caseCond.String())
+				e.Values = values[target]
+			}
+			to := nodeWithName(g, defaultTarget)
+			var label string
+			if len(defaultCond.Xs) > 0 {
+				label = defaultCond.String()
+			}
+			edgeWithKind(g, from, to, EdgeKindDefault, label)
+		case *ir.TermIndirectBr:
+			// Each valid target is a possible destination of the indirect branch.
+			addr := localIdent(term.Addr)
+			for _, target := range term.ValidTargets {
+				to := nodeWithName(g, localIdent(target))
+				c := &cond.Compare{Op: cond.CmpEq, X: addr, Y: localIdent(target)}
+				edgeWithKind(g, from, to, EdgeKindIndirect, c.String())
+			}
+		case *ir.TermInvoke:
+			normal := nodeWithName(g, localIdent(term.NormalRetTarget))
+			exception := nodeWithName(g, localIdent(term.ExceptionRetTarget))
+			if normal == exception {
+				// Both return points target the same basic block.
+				edgeWithKind(g, from, normal, EdgeKindUncond, "")
+				break
+			}
+			// The return point is conditioned on whether the invokee unwinds
+			// (e.g. "A.unwind" for the invoke terminator of basic block A).
+			unwindCond := &cond.Var{Name: from.name + ".unwind"}
+			normalCond := cond.Negate(unwindCond)
+			edgeWithKind(g, from, normal, EdgeKindNormal, normalCond.String())
+			edgeWithKind(g, from, exception, EdgeKindUnwind, unwindCond.String())
+		case *ir.TermResume:
+			// nothing to do; resumes propagation of the exception to the caller.
+		case *ir.TermCatchSwitch:
+			// Each handler is conditioned on the handler selected by the
+			// catchswitch (e.g. "A.handler == B" for handler B of the catchswitch
+			// terminator of basic block A).
+			for _, handler := range term.Handlers {
+				to := nodeWithName(g, localIdent(handler))
+				c := &cond.Compare{Op: cond.CmpEq, X: from.name + ".handler", Y: to.name}
+				edgeWithKind(g, from, to, EdgeKindHandler, c.String())
+			}
+			// Unwind to basic block, if not unwinding to the caller.
+			if target, ok := term.UnwindTarget.(*ir.Block); ok {
+				to := nodeWithName(g, localIdent(target))
+				c := &cond.Var{Name: from.name + ".unwind"}
+				edgeWithKind(g, from, to, EdgeKindUnwind, c.String())
+			}
+		case *ir.TermCatchRet:
+			to := nodeWithName(g, localIdent(term.To))
+			edgeWithKind(g, from, to, EdgeKindUncond, "")
+		case *ir.TermCleanupRet:
+			// Unwind to basic block, if not unwinding to the caller.
+			if target, ok := term.UnwindTarget.(*ir.Block); ok {
+				to := nodeWithName(g, localIdent(target))
+				edgeWithKind(g, from, to, EdgeKindUnwind, "")
 			}
-			to := nodeWithName(g, localIdent(term.TargetDefault))
-			defaultCond := strings.Join(defaultConds, " && ")
-			edgeWithLabel(g, from, to, defaultCond)
 		case *ir.TermUnreachable:
 			// nothing to do.
+		// TODO: add support for callbr terminators once llir/llvm is updated to
+		// LLVM 9.0.
 		default:
-			panic(fmt.Errorf("support for terminator %T not yet implemented", term))
+			return nil, errors.Wrapf(ErrUnsupportedTerminator, "support for terminator %T of basic block %q in function %q not yet implemented", term, from.DOTID(), f.Name())
+		}
+	}
+	return g, nil
+}
+
+// branchCond returns the branching condition of the given conditional branch
+// terminator of the basic block. Equality comparisons of the basic block are
+// resolved into the compared values (see ResolvedICmp), so that chains of
+// conditional branches comparing a common variable may be recovered as switch
+// statements.
+func branchCond(block *ir.Block, term *ir.TermCondBr) cond.Expr {
+	icmp, ok := ResolvedICmp(block)
+	if !ok {
+		return &cond.Var{Name: localIdent(term.Cond)}
+	}
+	op := cond.CmpEq
+	if icmp.Pred == enum.IPredNE {
+		op = cond.CmpNe
+	}
+	return &cond.Compare{Op: op, X: localIdent(icmp.X), Y: localIdent(icmp.Y)}
+}
+
+// ResolvedICmp returns the comparison of the conditional branch terminator of
+// the given basic block, if resolved into the compared values by the branching
+// condition of the basic block in control flow graphs of functions (see
+// NewGraphFromFunc); i.e. an equality comparison of an integer constant defined
+// in the basic block (e.g. "x == 1" in place of "c" for the branching condition
+// `%c = icmp eq i32 %x, 1`). Comparisons of two variables are not resolved, as
+// only integer constants may be used as case values of switch statements. The
+// boolean return value indicates success.
+func ResolvedICmp(block *ir.Block) (*ir.InstICmp, bool) {
+	term, ok := block.Term.(*ir.TermCondBr)
+	if !ok {
+		return nil, false
+	}
+	icmp, ok := term.Cond.(*ir.InstICmp)
+	if !ok || !definedIn(block, icmp) {
+		return nil, false
+	}
+	if icmp.Pred != enum.IPredEQ && icmp.Pred != enum.IPredNE {
+		return nil, false
+	}
+	if _, ok := icmp.Y.(*constant.Int); !ok {
+		return nil, false
+	}
+	return icmp, true
+}
+
+// definedIn reports whether the given instruction is part of the basic block.
+func definedIn(block *ir.Block, inst ir.Instruction) bool {
+	for _, i := range block.Insts {
+		if i == inst {
+			return true
 		}
 	}
-	return g
+	return false
 }
 
 // nodeWithName returns the node of the given name. A new node is created if not
@@ -101,22 +252,28 @@ func nodeWithName(g *Graph, name string) *Node {
 		return n
 	}
 	n := g.NewNodeWithName(name)
-	g.AddNode(n)
+	if err := g.AddNode(n); err != nil {
+		// unreachable; node name not yet present.
+		panic(err)
+	}
 	return n
 }
 
-// edgeWithLabel adds a directed edge between the specified nodes and assignes
-// it the given label.
-func edgeWithLabel(g *Graph, from, to *Node, label string) *Edge {
+// edgeWithKind adds a directed edge of the given kind between the specified
+// nodes and assignes it the given label.
+func edgeWithKind(g *Graph, from, to *Node, kind EdgeKind, label string) *Edge {
 	e := edge(g.NewEdge(from, to))
+	e.Kind = kind
 	if len(label) > 0 {
 		e.Attrs["label"] = label
-		switch {
-		case strings.HasPrefix(label, "%"):
-			e.Attrs["color"] = "darkgreen"
-		case strings.HasPrefix(label, "!"):
-			e.Attrs["color"] = "red"
-		}
+	}
+	switch kind {
+	case EdgeKindTrue:
+		e.Attrs["color"] = "darkgreen"
+	case EdgeKindFalse:
+		e.Attrs["color"] = "red"
+	case EdgeKindUnwind:
+		e.Attrs["style"] = "dashed"
 	}
 	g.SetEdge(e)
 	return e
@@ -137,13 +294,14 @@ func (g *Graph) Entry() graph.Node {
 }
 
 // SetEntry sets the entry node of the control flow graph.
-func (g *Graph) SetEntry(n graph.Node) {
+func (g *Graph) SetEntry(n graph.Node) error {
 	nn := node(n)
-	if g.entry != nil {
-		panic(fmt.Errorf("cannot set %q as entry node; entry node %q already present", nn.DOTID(), node(g.entry).DOTID()))
+	if g.entry != nil && nn != g.entry {
+		return errors.Wrapf(ErrDuplicateEntry, "cannot set %q as entry node; entry node %q", nn.DOTID(), node(g.entry).DOTID())
 	}
 	nn.entry = true
 	g.entry = nn
+	return nil
 }
 
 // NewNodeWithName returns a new node with the given name.
@@ -166,70 +324,49 @@ func (g *Graph) NodeWithName(name string) (*Node, bool) {
 
 // TrueTarget returns the target node of the true branch from n.
 func (g *Graph) TrueTarget(n *Node) *Node {
-	succs := graph.NodesOf(g.From(n.ID()))
-	if len(succs) != 2 {
-		panic(fmt.Errorf("invalid number of successors; expected 2, got %d", len(succs)))
-	}
-	succ1 := node(succs[0])
-	succ2 := node(succs[1])
-	e1 := edge(g.Edge(n.ID(), succ1.ID()))
-	e2 := edge(g.Edge(n.ID(), succ2.ID()))
-	e1Label := e1.Attrs["label"]
-	e2Label := e2.Attrs["label"]
-	switch {
-	case strings.HasPrefix(e1Label, "%") && strings.HasPrefix(e2Label, "!"):
-		return succ1
-	case strings.HasPrefix(e1Label, "!") && strings.HasPrefix(e2Label, "%"):
-		return succ2
-	default:
-		// TODO: Figure out how to track edges of true- and false-branches in
-		// between merges. For now, simply return the first successor (this will
-		// lead to incorrect results, but at least lets us progress).
-		warn.Printf(`unable to locate true branch of edges (%q -> %q) and (%q -> %q) based on edge label; expected "true" and "false", got %q and %q`, n.DOTID(), succ1.DOTID(), n.DOTID(), succ2.DOTID(), e1Label, e2Label)
-		return succ1
-	}
+	return g.branchTarget(n, EdgeKindTrue)
 }
 
 // FalseTarget returns the target node of the false branch from n.
 func (g *Graph) FalseTarget(n *Node) *Node {
+	return g.branchTarget(n, EdgeKindFalse)
+}
+
+// branchTarget returns the target node of the branch of the given edge kind
+// from the 2-way node n.
+func (g *Graph) branchTarget(n *Node, kind EdgeKind) *Node {
 	succs := graph.NodesOf(g.From(n.ID()))
 	if len(succs) != 2 {
 		panic(fmt.Errorf("invalid number of successors; expected 2, got %d", len(succs)))
 	}
+	for _, succ := range succs {
+		e := edge(g.Edge(n.ID(), succ.ID()))
+		if e.Kind == kind {
+			return node(succ)
+		}
+	}
 	succ1 := node(succs[0])
 	succ2 := node(succs[1])
 	e1 := edge(g.Edge(n.ID(), succ1.ID()))
 	e2 := edge(g.Edge(n.ID(), succ2.ID()))
-	e1Label := e1.Attrs["label"]
-	e2Label := e2.Attrs["label"]
-	switch {
-	case strings.HasPrefix(e1Label, "%") && strings.HasPrefix(e2Label, "!"):
-		return succ2
-	case strings.HasPrefix(e1Label, "!") && strings.HasPrefix(e2Label, "%"):
-		return succ1
-	default:
-		// TODO: Figure out how to track edges of true- and false-branches in
-		// between merges. For now, simply return the first successor (this will
-		// lead to incorrect results, but at least lets us progress).
-		warn.Printf(`unable to locate false branch of edges (%q -> %q) and (%q -> %q) based on edge label; expected "true" and "false", got %q and %q`, n.DOTID(), succ1.DOTID(), n.DOTID(), succ2.DOTID(), e1Label, e2Label)
-		return succ1
-	}
+	panic(fmt.Errorf("unable to locate %v branch of edges (%q -> %q) and (%q -> %q); expected edge kinds %q and %q, got %q and %q", kind, n.DOTID(), succ1.DOTID(), n.DOTID(), succ2.DOTID(), EdgeKindTrue, EdgeKindFalse, e1.Kind, e2.Kind))
 }
 
 // initNodes initializes the mapping between node names and graph nodes.
-func (g *Graph) initNodes() {
+func (g *Graph) initNodes() error {
 	nodes := g.Nodes()
 	for nodes.Next() {
 		n := nodes.Node()
 		nn := node(n)
 		if len(nn.name) == 0 {
-			panic(fmt.Errorf("invalid node; missing node name in %#v", nn))
+			return errors.Wrapf(ErrMissingNodeName, "invalid node with ID %d", nn.ID())
 		}
 		if prev, ok := g.nodes[nn.name]; ok && nn != prev {
-			panic(fmt.Errorf("node name %q already present in graph; prev node %#v, new node %#v", nn.name, prev, nn))
+			return errors.Wrapf(ErrDuplicateNode, "node name %q already present in graph; prev node ID %d, new node ID %d", nn.name, prev.ID(), nn.ID())
 		}
 		g.nodes[nn.name] = nn
 	}
+	return nil
 }
 
 // --- [ dot.Graph ] -----------------------------------------------------------
@@ -258,22 +395,28 @@ func (g *Graph) NewNode() graph.Node {
 
 // AddNode adds a node to the graph.
 //
-// If the added node ID matches an existing node ID, AddNode will panic.
-func (g *Graph) AddNode(n graph.Node) {
+// An error is returned if the added node ID or node name matches that of an
+// existing node, or if the added node is an entry node and the graph already
+// has an entry node.
+func (g *Graph) AddNode(n graph.Node) error {
 	nn := node(n)
+	if g.Node(nn.ID()) != nil {
+		return errors.Wrapf(ErrDuplicateNode, "node ID %d already present in graph", nn.ID())
+	}
+	if nn.entry && g.entry != nil && nn != g.entry {
+		return errors.Wrapf(ErrDuplicateEntry, "cannot add %q as entry node; entry node %q", nn.DOTID(), node(g.entry).DOTID())
+	}
+	if prev, ok := g.nodes[nn.name]; ok && len(nn.name) > 0 && nn != prev {
+		return errors.Wrapf(ErrDuplicateNode, "node name %q already present in graph; prev node ID %d, new node ID %d", nn.name, prev.ID(), nn.ID())
+	}
 	g.DirectedGraph.AddNode(nn)
 	if nn.entry {
-		if g.entry != nil && nn != g.entry {
-			panic(fmt.Errorf("entry node already set in graph; prev entry node %#v, new entry node %#v", g.entry, nn))
-		}
 		g.entry = nn
 	}
 	if len(nn.name) > 0 {
-		if prev, ok := g.nodes[nn.name]; ok && nn != prev {
-			panic(fmt.Errorf("node name %q already present in graph; prev node %#v, new node %#v", nn.name, prev, nn))
-		}
 		g.nodes[nn.name] = nn
 	}
+	return nil
 }
 
 // --- [ graph.NodeRemover ] ---------------------------------------------------
@@ -301,8 +444,8 @@ func (g *Graph) NewEdge(from, to graph.Node) graph.Edge {
 
 // SetEdge adds an edge from one node to another.
 //
-// If the graph supports node addition the nodes will be added if they do not
-// exist, otherwise SetEdge will panic.
+// The nodes will be added if they do not exist. SetEdge will panic if a node
+// cannot be added (see AddNode).
 func (g *Graph) SetEdge(e graph.Edge) {
 	ee, ok := e.(*Edge)
 	if !ok {
@@ -311,10 +454,14 @@ func (g *Graph) SetEdge(e graph.Edge) {
 	// Add nodes if not yet present in graph.
 	from, to := ee.From(), ee.To()
 	if g.Node(from.ID()) == nil {
-		g.AddNode(from)
+		if err := g.AddNode(from); err != nil {
+			panic(fmt.Errorf("unable to add source node of edge; %+v", err))
+		}
 	}
 	if g.Node(to.ID()) == nil {
-		g.AddNode(to)
+		if err := g.AddNode(to); err != nil {
+			panic(fmt.Errorf("unable to add destination node of edge; %+v", err))
+		}
 	}
 	// Add edge.
 	g.DirectedGraph.SetEdge(ee)
@@ -340,9 +487,10 @@ type Node struct {
 	// TODO: Figure out if we can move this information somewhere else; e.g.
 	// local variables in loopStruct.
 
-	// Number of back edges to the node.
+	// Number of back edges to the node; initialized by InitDFSOrder.
 	NBackEdges int
-	// IsLatch specifies whether the node is a latch node.
+	// IsLatch specifies whether the node is a latch node; i.e. the source node
+	// of a back edge. Initialized by InitDFSOrder.
 	IsLatch bool
 	// Type of the loop.
 	LoopType LoopType
@@ -363,6 +511,9 @@ type Node struct {
 //go:generate stringer -type LoopType -linecomment
 
 // LoopType specifies the type of a loop.
+//
+// Pre-test loops correspond to while loops, and post-test loops to do-while
+// loops.
 type LoopType uint
 
 // Loop types.
@@ -422,7 +573,58 @@ func (n *Node) Attributes() []encoding.Attribute {
 		}
 		n.Attrs["label"] = "entry"
 	}
-	return n.Attrs.Attributes()
+	attrs := make(Attrs)
+	for key, val := range n.Attrs {
+		attrs[key] = val
+	}
+	// Control flow primitive annotations, as recorded by structuring.
+	if n.LoopType != LoopTypeNone {
+		attrs["loop_type"] = strconv.Quote(n.LoopType.String())
+	}
+	annotations := []struct {
+		key string
+		n   *Node
+	}{
+		{key: "loop_head", n: n.LoopHead},
+		{key: "latch", n: n.Latch},
+		{key: "loop_follow", n: n.LoopFollow},
+		{key: "if_follow", n: n.IfFollow},
+		{key: "switch_head", n: n.SwitchHead},
+		{key: "switch_follow", n: n.SwitchFollow},
+	}
+	for _, ann := range annotations {
+		if ann.n != nil {
+			attrs[ann.key] = strconv.Quote(ann.n.name)
+		}
+	}
+	return attrs.Attributes()
+}
+
+// Assign returns the variable name and value of the assignment of the node, as
+// recorded by the DOT attribute "assign" of nodes added by MakeReducible (e.g.
+// `assign="%B2.sel = 1"`). The boolean return value indicates success.
+func (n *Node) Assign() (v string, value int, ok bool) {
+	s, ok := n.Attrs["assign"]
+	if !ok {
+		return "", 0, false
+	}
+	parts := strings.Split(unquote(s), " = ")
+	if len(parts) != 2 {
+		return "", 0, false
+	}
+	value, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return parts[0], value, true
+}
+
+// Guard reports whether the node only evaluates the comparison of its branching
+// condition, as recorded by the DOT attribute "guard" of nodes added by
+// NewGraphFromFunc (e.g. `guard=true` for a basic block consisting solely of
+// `%c = icmp eq i32 %x, 1`).
+func (n *Node) Guard() bool {
+	return unquote(n.Attrs["guard"]) == "true"
 }
 
 // --- [ encoding.AttributeSetter ] -------------------------------------------
@@ -431,7 +633,7 @@ func (n *Node) Attributes() []encoding.Attribute {
 func (n *Node) SetAttribute(attr encoding.Attribute) error {
 	if attr.Key == "label" && attr.Value == "entry" {
 		if prev, ok := n.Attrs["label"]; ok && prev != "entry" {
-			panic(fmt.Errorf(`invalid DOT label of entry node; expected "entry", got %q`, prev))
+			return errors.Errorf(`invalid DOT label of entry node; expected "entry", got %q`, prev)
 		}
 		n.entry = true
 	} else {
@@ -445,22 +647,104 @@ func (n *Node) SetAttribute(attr encoding.Attribute) error {
 // Edge is an edge in a control flow graph.
 type Edge struct {
 	graph.Edge
+	// Kind of the edge.
+	Kind EdgeKind
+	// Case values of switch case edges.
+	Values []string
+	// Depth first search classification of the edge; initialized by
+	// InitDFSOrder.
+	DFSKind DFSEdgeKind
 	// DOT attributes.
 	Attrs
 }
 
+//go:generate stringer -type EdgeKind -linecomment
+
+// EdgeKind specifies the kind of a control flow graph edge.
+type EdgeKind uint
+
+// Edge kinds.
+const (
+	EdgeKindNone     EdgeKind = iota // none
+	EdgeKindUncond                   // unconditional
+	EdgeKindTrue                     // true
+	EdgeKindFalse                    // false
+	EdgeKindCase                     // case
+	EdgeKindDefault                  // default
+	EdgeKindIndirect                 // indirect
+	EdgeKindNormal                   // normal
+	EdgeKindUnwind                   // unwind
+	EdgeKindHandler                  // handler
+)
+
+// MarshalText encodes the edge kind into UTF-8-encoded text and returns the
+// result; implements encoding.TextMarshaler.
+func (kind EdgeKind) MarshalText() ([]byte, error) {
+	return []byte(kind.String()), nil
+}
+
+// UnmarshalText decodes the edge kind from the UTF-8 encoded text; implements
+// encoding.TextUnmarshaler.
+func (kind *EdgeKind) UnmarshalText(b []byte) error {
+	s := string(b)
+	switch s {
+	case "none":
+		*kind = EdgeKindNone
+	case "unconditional":
+		*kind = EdgeKindUncond
+	case "true":
+		*kind = EdgeKindTrue
+	case "false":
+		*kind = EdgeKindFalse
+	case "case":
+		*kind = EdgeKindCase
+	case "default":
+		*kind = EdgeKindDefault
+	case "indirect":
+		*kind = EdgeKindIndirect
+	case "normal":
+		*kind = EdgeKindNormal
+	case "unwind":
+		*kind = EdgeKindUnwind
+	case "handler":
+		*kind = EdgeKindHandler
+	default:
+		return errors.Errorf("support for unmarshalling edge kind %q not yet implemented", s)
+	}
+	return nil
+}
+
 // --- [ encoding.Attributer ] -------------------------------------------------
 
 // Attributes returns the DOT attributes of the edge.
 func (e *Edge) Attributes() []encoding.Attribute {
-	return e.Attrs.Attributes()
+	attrs := make(Attrs)
+	for key, val := range e.Attrs {
+		attrs[key] = val
+	}
+	if e.Kind != EdgeKindNone {
+		attrs["kind"] = e.Kind.String()
+	}
+	if len(e.Values) > 0 {
+		attrs["values"] = strconv.Quote(strings.Join(e.Values, ","))
+	}
+	return attrs.Attributes()
 }
 
 // --- [ encoding.AttributeSetter ] -------------------------------------------
 
 // SetAttribute sets the DOT attribute of the edge.
 func (e *Edge) SetAttribute(attr encoding.Attribute) error {
-	e.Attrs[attr.Key] = attr.Value
+	switch attr.Key {
+	case "kind":
+		if err := e.Kind.UnmarshalText([]byte(unquote(attr.Value))); err != nil {
+			return errors.WithStack(err)
+		}
+	case "values":
+		e.Values = strings.Split(unquote(attr.Value), ",")
+	default:
+		e.Attrs[attr.Key] = attr.Value
+	}
 	return nil
 }
 
@@ -484,8 +768,8 @@ func (a Attrs) Attributes() []encoding.Attribute {
 			Key:   key,
 			Value: a[key],
 		}
-		// Quote label string if containing spaces.
-		if key == "label" {
+		// Quote label, assignment and dispatch strings if containing spaces.
+		if key == "label" || key == "assign" || key == "dispatch" {
 			s := attr.Value
 			if strings.ContainsAny(s, " %!") && !strings.HasPrefix(s, `"`) {
 				attr.Value = strconv.Quote(s)
@@ -496,6 +780,14 @@ func (a Attrs) Attributes() []encoding.Attribute {
 	return attrs
 }
 
+// unquote returns the unquoted version of s, if quoted.
+func unquote(s string) string {
+	if t, err := strconv.Unquote(s); err == nil {
+		return t
+	}
+	return s
+}
+
 // node asserts that the given node is a control flow graph node.
 func node(n graph.Node) *Node {
 	if n, ok := n.(*Node); ok {
@@ -7,6 +7,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/pkg/errors"
 	"gonum.org/v1/gonum/graph"
 )
 
@@ -15,6 +20,7 @@ func TestRoundTrip(t *testing.T) {
 		path string
 	}{
 		{path: "testdata/a.dot"},
+		{path: "testdata/kinds.dot"},
 	}
 	for _, gold := range golden {
 		buf, err := ioutil.ReadFile(gold.path)
@@ -41,6 +47,7 @@ func TestCopy(t *testing.T) {
 		path string
 	}{
 		{path: "testdata/a.dot"},
+		{path: "testdata/kinds.dot"},
 	}
 	for _, gold := range golden {
 		buf, err := ioutil.ReadFile(gold.path)
@@ -55,7 +62,10 @@ func TestCopy(t *testing.T) {
 			continue
 		}
 		dst := NewGraph()
-		Copy(dst, src)
+		if err := Copy(dst, src); err != nil {
+			t.Errorf("%q; unable to copy graph; %v", gold.path, err)
+			continue
+		}
 		got := dst.String()
 		if got != want {
 			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
@@ -64,6 +74,110 @@ func TestCopy(t *testing.T) {
 	}
 }
 
+func TestNewGraphFromFunc(t *testing.T) {
+	golden := []struct {
+		name string
+		f    *ir.Func
+		want []string
+	}{
+		{
+			name: "invoke",
+			f:    invokeFunc(),
+			want: []string{
+				`A -> B [kind=normal label="!A.unwind"]`,
+				`A -> C [kind=unwind label="A.unwind"]`,
+			},
+		},
+		{
+			name: "indirectbr",
+			f:    indirectBrFunc(),
+			want: []string{
+				`A -> B [kind=indirect label="addr == B"]`,
+				`A -> C [kind=indirect label="addr == C"]`,
+			},
+		},
+		{
+			name: "catchswitch",
+			f:    catchSwitchFunc(),
+			want: []string{
+				`A -> B [kind=normal label="!A.unwind"]`,
+				`A -> C [kind=unwind label="A.unwind"]`,
+				`C -> D [kind=handler label="C.handler == D"]`,
+				`C -> E [kind=handler label="C.handler == E"]`,
+				`C -> F [kind=unwind label="C.unwind"]`,
+				`D -> B [kind=unconditional label=""]`,
+				`E -> B [kind=unconditional label=""]`,
+			},
+		},
+		{
+			name: "condbr",
+			f:    condBrFunc(),
+			want: []string{
+				`A -> B [kind=true label="x == 1"]`,
+				`A -> C [kind=false label="x != 1"]`,
+				// Comparisons of two variables are not resolved.
+				`C -> B [kind=false label="!d"]`,
+				`C -> D [kind=true label="d"]`,
+			},
+		},
+		{
+			name: "cleanupret",
+			f:    cleanupRetFunc(),
+			want: []string{
+				`A -> B [kind=normal label="!A.unwind"]`,
+				`A -> C [kind=unwind label="A.unwind"]`,
+				`C -> D [kind=unwind label=""]`,
+			},
+		},
+	}
+	for _, gold := range golden {
+		g, err := NewGraphFromFunc(gold.f)
+		if err != nil {
+			t.Errorf("%q; unable to create control flow graph; %v", gold.name, err)
+			continue
+		}
+		var got []string
+		for _, n := range sortByDOTID(graph.NodesOf(g.Nodes())) {
+			for _, succ := range sortByDOTID(graph.NodesOf(g.From(n.ID()))) {
+				e := edge(g.Edge(n.ID(), succ.ID()))
+				got = append(got, fmt.Sprintf("%s -> %s [kind=%v label=%q]", node(n).name, node(succ).name, e.Kind, e.Attrs["label"]))
+			}
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; output mismatch; expected `%v`, got `%v`", gold.name, gold.want, got)
+		}
+	}
+}
+
+func TestCopyDFS(t *testing.T) {
+	// The depth first search order of copied and merged graphs is independent of
+	// the source graph.
+	src, err := ParseFile("testdata/sample.dot")
+	if err != nil {
+		t.Fatalf("unable to parse file; %v", err)
+	}
+	dst := NewGraph()
+	if err := Copy(dst, src); err != nil {
+		t.Fatalf("unable to copy graph; %v", err)
+	}
+	merged, err := Merge(src, map[string]bool{"B13": true, "B14": true}, "B13.B14")
+	if err != nil {
+		t.Fatalf("unable to merge nodes; %v", err)
+	}
+	InitDFSOrder(dst)
+	InitDFSOrder(merged)
+	for _, n := range nodesOf(graph.NodesOf(src.Nodes())) {
+		if n.Pre != 0 || n.RevPost != 0 || n.NBackEdges != 0 || n.IsLatch {
+			t.Errorf("depth first search order of source node %q modified", n.name)
+		}
+		for _, succ := range graph.NodesOf(src.From(n.ID())) {
+			if e := edge(src.Edge(n.ID(), succ.ID())); e.DFSKind != DFSEdgeKindNone {
+				t.Errorf("depth first search kind of source edge (%q -> %q) modified", n.name, node(succ).name)
+			}
+		}
+	}
+}
+
 func TestMerge(t *testing.T) {
 	golden := []struct {
 		path     string
@@ -83,6 +197,34 @@ func TestMerge(t *testing.T) {
 			nodes:    map[string]bool{"B13": true, "B14": true, "B15": true},
 			id:       "I3",
 		},
+		{
+			path:     "testdata/kinds.dot",
+			wantPath: "testdata/kinds.dot.CE.golden",
+			nodes:    map[string]bool{"C": true, "E": true},
+			id:       "CE",
+		},
+		{
+			// Both branches of A lead to the merged node.
+			path:     "testdata/kinds.dot",
+			wantPath: "testdata/kinds.dot.BC.golden",
+			nodes:    map[string]bool{"B": true, "C": true},
+			id:       "BC",
+		},
+		{
+			// Case edges of A to the merged node are combined.
+			path:     "testdata/switch.dot",
+			wantPath: "testdata/switch.dot.BC.golden",
+			nodes:    map[string]bool{"B": true, "C": true},
+			id:       "BC",
+		},
+		{
+			// Case and default edges of A to the merged node are combined into
+			// a default edge.
+			path:     "testdata/switch.dot",
+			wantPath: "testdata/switch.dot.BD.golden",
+			nodes:    map[string]bool{"B": true, "D": true},
+			id:       "BD",
+		},
 	}
 	for _, gold := range golden {
 		// Parse input.
@@ -99,7 +241,11 @@ func TestMerge(t *testing.T) {
 		}
 		want := strings.TrimSpace(string(buf))
 		// Merge.
-		out := Merge(in, gold.nodes, gold.id)
+		out, err := Merge(in, gold.nodes, gold.id)
+		if err != nil {
+			t.Errorf("%q; unable to merge nodes; %v", gold.path, err)
+			continue
+		}
 		got := out.String()
 		if got != want {
 			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
@@ -200,3 +346,179 @@ func TestSortByRevPost(t *testing.T) {
 		}
 	}
 }
+
+func TestBranchTarget(t *testing.T) {
+	golden := []struct {
+		path      string
+		name      string
+		wantTrue  string
+		wantFalse string
+	}{
+		{path: "testdata/kinds.dot", name: "A", wantTrue: "B", wantFalse: "C"},
+		{path: "testdata/kinds.dot.CE.golden", name: "A", wantTrue: "B", wantFalse: "CE"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		n, ok := in.NodeWithName(gold.name)
+		if !ok {
+			t.Errorf("%q; unable to locate node %q", gold.path, gold.name)
+			continue
+		}
+		// Check results.
+		if got := in.TrueTarget(n).DOTID(); got != gold.wantTrue {
+			t.Errorf("%q; true target mismatch; expected %q, got %q", gold.path, gold.wantTrue, got)
+		}
+		if got := in.FalseTarget(n).DOTID(); got != gold.wantFalse {
+			t.Errorf("%q; false target mismatch; expected %q, got %q", gold.path, gold.wantFalse, got)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	golden := []struct {
+		input string
+		want  error
+	}{
+		{input: "digraph G { A -> B; }", want: ErrNoEntry},
+		{input: "digraph G { A [label=entry]; B [label=entry]; A -> B; }", want: ErrDuplicateEntry},
+	}
+	for _, gold := range golden {
+		_, err := ParseString(gold.input)
+		if got := errors.Cause(err); got != gold.want {
+			t.Errorf("%q; error mismatch; expected %v, got %v", gold.input, gold.want, err)
+			continue
+		}
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	in, err := ParseFile("testdata/kinds.dot")
+	if err != nil {
+		t.Fatalf("unable to parse file; %v", err)
+	}
+	_, err = Merge(in, map[string]bool{"C": true, "E": true}, "D")
+	if got := errors.Cause(err); got != ErrDuplicateNode {
+		t.Errorf("error mismatch; expected %v, got %v", ErrDuplicateNode, err)
+	}
+}
+
+func TestLoopTypeText(t *testing.T) {
+	golden := []struct {
+		typ  LoopType
+		want string
+	}{
+		{typ: LoopTypeNone, want: "none"},
+		{typ: LoopTypePreTest, want: "pre-test_loop"},
+		{typ: LoopTypePostTest, want: "post-test_loop"},
+		{typ: LoopTypeEndless, want: "endless_loop"},
+	}
+	for _, gold := range golden {
+		buf, err := gold.typ.MarshalText()
+		if err != nil {
+			t.Errorf("%v; unable to marshal loop type; %v", gold.typ, err)
+			continue
+		}
+		if got := string(buf); got != gold.want {
+			t.Errorf("%v; output mismatch; expected %q, got %q", gold.typ, gold.want, got)
+			continue
+		}
+		var typ LoopType
+		if err := typ.UnmarshalText(buf); err != nil {
+			t.Errorf("%q; unable to unmarshal loop type; %v", gold.want, err)
+			continue
+		}
+		if typ != gold.typ {
+			t.Errorf("%q; loop type mismatch; expected %v, got %v", gold.want, gold.typ, typ)
+		}
+	}
+}
+
+// invokeFunc returns a function invoking an external function, which either
+// returns normally or unwinds to a landing pad resuming the exception.
+func invokeFunc() *ir.Func {
+	callee := ir.NewFunc("g", types.Void)
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("f", types.Void, x)
+	a := f.NewBlock("A")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	a.NewInvoke(callee, nil, b, c)
+	b.NewRet(nil)
+	c.NewResume(x)
+	return f
+}
+
+// indirectBrFunc returns a function branching indirectly to one of two basic
+// blocks.
+func indirectBrFunc() *ir.Func {
+	addr := ir.NewGlobal("addr", types.I8)
+	f := ir.NewFunc("f", types.Void)
+	a := f.NewBlock("A")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	a.NewIndirectBr(addr, b, c)
+	b.NewRet(nil)
+	c.NewRet(nil)
+	return f
+}
+
+// condBrFunc returns a function of two conditional branches, comparing a
+// variable to a constant and to a variable respectively.
+func condBrFunc() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	f := ir.NewFunc("f", types.Void, x, y)
+	a := f.NewBlock("A")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	d := f.NewBlock("D")
+	a.NewCondBr(a.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 1)), b, c)
+	dd := c.NewICmp(enum.IPredNE, x, y)
+	dd.SetName("d")
+	c.NewCondBr(dd, d, b)
+	b.NewRet(nil)
+	d.NewRet(nil)
+	return f
+}
+
+// catchSwitchFunc returns a function invoking an external function, which
+// unwinds to a catchswitch with two handlers returning to the normal return
+// point, and an unwind target.
+func catchSwitchFunc() *ir.Func {
+	callee := ir.NewFunc("g", types.Void)
+	f := ir.NewFunc("f", types.Void)
+	a := f.NewBlock("A")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	d := f.NewBlock("D")
+	e := f.NewBlock("E")
+	u := f.NewBlock("F")
+	a.NewInvoke(callee, nil, b, c)
+	b.NewRet(nil)
+	cs := c.NewCatchSwitch(constant.None, []*ir.Block{d, e}, u)
+	d.NewCatchRet(d.NewCatchPad(cs), b)
+	e.NewCatchRet(e.NewCatchPad(cs), b)
+	u.NewUnreachable()
+	return f
+}
+
+// cleanupRetFunc returns a function invoking an external function, which
+// unwinds to a cleanup pad unwinding to a basic block.
+func cleanupRetFunc() *ir.Func {
+	callee := ir.NewFunc("g", types.Void)
+	f := ir.NewFunc("f", types.Void)
+	a := f.NewBlock("A")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	d := f.NewBlock("D")
+	a.NewInvoke(callee, nil, b, c)
+	b.NewRet(nil)
+	c.NewCleanupRet(c.NewCleanupPad(constant.None), d)
+	d.NewUnreachable()
+	return f
+}
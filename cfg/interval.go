@@ -0,0 +1,238 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Intervals ] ===========================================================
+
+// Interval is a maximal, single-entry subgraph of a control flow graph, in
+// which the header node is the only entry node and all closed paths contain
+// the header node.
+type Interval struct {
+	// Name of the interval (e.g. "I1"); used as node name of the interval in
+	// the derived graph.
+	Name string
+	// Header node of the interval.
+	Head *Node
+	// Nodes of the interval, in the order they were added; the header node is
+	// first.
+	Nodes []*Node
+}
+
+// Contains reports whether the interval contains the given node.
+func (i *Interval) Contains(n *Node) bool {
+	return containsNode(i.Nodes, n)
+}
+
+// Intervals returns the maximal intervals of the given control flow graph, in
+// order of discovery, as computed by the algorithm of Allen and Cocke [1].
+// Intervals are named "I1", "I2", etc, in order of discovery.
+//
+// Nodes unreachable from the entry node are not part of any interval.
+//
+// [1]: https://doi.org/10.1145/360018.360025
+func Intervals(g *Graph) []*Interval {
+	if g.entry == nil {
+		return nil
+	}
+	succs := func(n *Node) []*Node {
+		return nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID()))))
+	}
+	entry := node(g.entry)
+	// Visit nodes in reverse post-order, to add nodes to intervals in
+	// topological order (disregarding back edges).
+	order := revPostOrder(entry, succs)
+	// Predecessors unreachable from the entry node are disregarded.
+	reachable := make(map[*Node]bool)
+	for _, n := range order {
+		reachable[n] = true
+	}
+	// owner maps from node to the interval containing it.
+	owner := make(map[*Node]*Interval)
+	isHead := map[*Node]bool{entry: true}
+	heads := []*Node{entry}
+	var is []*Interval
+	for i := 0; i < len(heads); i++ {
+		h := heads[i]
+		in := &Interval{
+			Name:  fmt.Sprintf("I%d", len(is)+1),
+			Head:  h,
+			Nodes: []*Node{h},
+		}
+		owner[h] = in
+		// Add nodes whose predecessors are all part of the interval.
+		for changed := true; changed; {
+			changed = false
+			for _, n := range order {
+				if owner[n] != nil || isHead[n] {
+					continue
+				}
+				if !allPredsIn(g, n, in, owner, reachable) {
+					continue
+				}
+				owner[n] = in
+				in.Nodes = append(in.Nodes, n)
+				changed = true
+			}
+		}
+		// Add nodes with predecessors in the interval as interval headers.
+		for _, n := range order {
+			if owner[n] != nil || isHead[n] {
+				continue
+			}
+			for _, pred := range nodesOf(graph.NodesOf(g.To(n.ID()))) {
+				if owner[pred] == in {
+					isHead[n] = true
+					heads = append(heads, n)
+					break
+				}
+			}
+		}
+		is = append(is, in)
+	}
+	return is
+}
+
+// allPredsIn reports whether every reachable predecessor of n is part of the
+// given interval.
+func allPredsIn(g *Graph, n *Node, in *Interval, owner map[*Node]*Interval, reachable map[*Node]bool) bool {
+	preds := g.To(n.ID())
+	for preds.Next() {
+		pred := node(preds.Node())
+		if reachable[pred] && owner[pred] != in {
+			return false
+		}
+	}
+	return true
+}
+
+// --- [ Derived sequence ] ----------------------------------------------------
+
+// Derived is the derived sequence of graphs G1, ..., Gn of a control flow
+// graph, where G1 is the control flow graph itself and each subsequent graph
+// Gi+1 is formed by collapsing each interval of Gi into a single node.
+type Derived struct {
+	// Graphs of the derived sequence; Graphs[0] is the original control flow
+	// graph and the last graph is the limit graph.
+	Graphs []*Graph
+	// Intervals of each graph of the derived sequence; Intervals[i] holds the
+	// intervals of Graphs[i].
+	Intervals [][]*Interval
+	// orig maps from node name of interval nodes in derived graphs to the nodes
+	// of the original control flow graph collapsed into the interval node.
+	orig map[string][]*Node
+}
+
+// DerivedSequence returns the derived sequence of graphs of the given control
+// flow graph. Interval nodes are named "I1", "I2", etc, numbered in order of
+// discovery throughout the derived sequence; where names of nodes in the
+// control flow graph are skipped, to prevent collisions with interval nodes.
+//
+// The derived sequence ends with the limit graph, which is either the trivial
+// graph of a single node (if the control flow graph is reducible), or a graph
+// in which each interval consists of a single node. Nodes unreachable from the
+// entry node are not part of any interval, and are thus not present in derived
+// graphs.
+//
+// An error is returned if the control flow graph has no entry node.
+func DerivedSequence(g *Graph) (*Derived, error) {
+	if g.entry == nil {
+		return nil, errors.WithStack(ErrNoEntry)
+	}
+	d := &Derived{
+		orig: make(map[string][]*Node),
+	}
+	// Names of nodes in the control flow graph.
+	names := make(map[string]bool)
+	for _, n := range nodesOf(graph.NodesOf(g.Nodes())) {
+		names[n.name] = true
+	}
+	nintervals := 0
+	for {
+		is := Intervals(g)
+		// Number of nodes reachable from the entry node.
+		nreachable := 0
+		for _, in := range is {
+			for {
+				nintervals++
+				in.Name = fmt.Sprintf("I%d", nintervals)
+				if !names[in.Name] {
+					break
+				}
+			}
+			nreachable += len(in.Nodes)
+		}
+		d.Graphs = append(d.Graphs, g)
+		d.Intervals = append(d.Intervals, is)
+		if len(is) == nreachable {
+			// Limit graph reached.
+			break
+		}
+		// Collapse intervals to form the derived graph.
+		next := g
+		for _, in := range is {
+			delNodes := make(map[string]bool)
+			var orig []*Node
+			for _, n := range in.Nodes {
+				delNodes[n.name] = true
+				orig = append(orig, d.Orig(n)...)
+			}
+			var err error
+			next, err = Merge(next, delNodes, in.Name)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			d.orig[in.Name] = orig
+		}
+		// Remove unreachable nodes.
+		for _, n := range nodesOf(graph.NodesOf(next.Nodes())) {
+			if !containsInterval(is, n.name) {
+				next.RemoveNode(n)
+			}
+		}
+		g = next
+	}
+	// Intervals of the limit graph have no corresponding derived graph.
+	for _, in := range d.Intervals[len(d.Intervals)-1] {
+		delete(d.orig, in.Name)
+	}
+	return d, nil
+}
+
+// containsInterval reports whether the given intervals contain an interval of
+// the specified name.
+func containsInterval(is []*Interval, name string) bool {
+	for _, in := range is {
+		if in.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Limit returns the limit graph of the derived sequence.
+func (d *Derived) Limit() *Graph {
+	return d.Graphs[len(d.Graphs)-1]
+}
+
+// Reducible reports whether the control flow graph of the derived sequence is
+// reducible; i.e. whether the limit graph is the trivial graph, disregarding
+// nodes unreachable from the entry node.
+func (d *Derived) Reducible() bool {
+	return len(d.Intervals[len(d.Intervals)-1]) <= 1
+}
+
+// Orig returns the nodes of the original control flow graph collapsed into the
+// given node of a graph in the derived sequence, with the original header node
+// of the interval first. Nodes of the original control flow graph are returned
+// as is.
+func (d *Derived) Orig(n *Node) []*Node {
+	if orig, ok := d.orig[n.name]; ok {
+		return orig
+	}
+	return []*Node{n}
+}
@@ -0,0 +1,224 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestIntervals(t *testing.T) {
+	golden := []struct {
+		path string
+		// Nodes of each interval, in order of discovery.
+		want [][]string
+		// Golden output of merging each interval into a single node; empty if
+		// not present.
+		wantPaths []string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			want: [][]string{
+				{"B1", "B2", "B4", "B3", "B5"},
+				{"B6", "B12", "B7", "B8", "B9", "B10", "B11"},
+				{"B13", "B14", "B15"},
+			},
+			wantPaths: []string{
+				"testdata/sample.dot.I1.golden",
+				"",
+				"testdata/sample.dot.I3.golden",
+			},
+		},
+		{
+			path: "testdata/irreducible.dot",
+			want: [][]string{
+				{"A"},
+				{"B"},
+				{"C", "D"},
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		is := Intervals(in)
+		var got [][]string
+		for _, i := range is {
+			var names []string
+			for _, n := range i.Nodes {
+				names = append(names, n.DOTID())
+			}
+			got = append(got, names)
+			if i.Head != i.Nodes[0] {
+				t.Errorf("%q; header mismatch of interval %q; expected %q, got %q", gold.path, i.Name, i.Nodes[0].DOTID(), i.Head.DOTID())
+			}
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; intervals mismatch; expected `%v`, got `%v`", gold.path, gold.want, got)
+			continue
+		}
+		// Merge intervals.
+		for j, wantPath := range gold.wantPaths {
+			if len(wantPath) == 0 {
+				continue
+			}
+			buf, err := ioutil.ReadFile(wantPath)
+			if err != nil {
+				t.Errorf("%q; unable to read file; %v", wantPath, err)
+				continue
+			}
+			want := strings.TrimSpace(string(buf))
+			delNodes := make(map[string]bool)
+			for _, n := range is[j].Nodes {
+				delNodes[n.DOTID()] = true
+			}
+			out, err := Merge(in, delNodes, is[j].Name)
+			if err != nil {
+				t.Errorf("%q; unable to merge interval %q; %v", gold.path, is[j].Name, err)
+				continue
+			}
+			if got := out.String(); got != want {
+				t.Errorf("%q; output mismatch; expected `%s`, got `%s`", wantPath, want, got)
+			}
+		}
+	}
+}
+
+func TestDerivedSequence(t *testing.T) {
+	golden := []struct {
+		path string
+		// Nodes of each graph in the derived sequence, sorted by DOT ID.
+		want [][]string
+		// Original nodes of each interval node.
+		orig map[string][]string
+		// Reducibility of the control flow graph.
+		reducible bool
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			want: [][]string{
+				{"B1", "B2", "B3", "B4", "B5", "B6", "B7", "B8", "B9", "B10", "B11", "B12", "B13", "B14", "B15"},
+				{"I1", "I2", "I3"},
+				{"I4", "I5"},
+				{"I6"},
+			},
+			orig: map[string][]string{
+				"I1": {"B1", "B2", "B4", "B3", "B5"},
+				"I2": {"B6", "B12", "B7", "B8", "B9", "B10", "B11"},
+				"I3": {"B13", "B14", "B15"},
+				"I4": {"B1", "B2", "B4", "B3", "B5"},
+				"I5": {"B6", "B12", "B7", "B8", "B9", "B10", "B11", "B13", "B14", "B15"},
+				"I6": {"B1", "B2", "B4", "B3", "B5", "B6", "B12", "B7", "B8", "B9", "B10", "B11", "B13", "B14", "B15"},
+			},
+			reducible: true,
+		},
+		{
+			path: "testdata/irreducible.dot",
+			want: [][]string{
+				{"A", "B", "C", "D"},
+				{"I1", "I2", "I3"},
+			},
+			orig: map[string][]string{
+				"I1": {"A"},
+				"I2": {"B"},
+				"I3": {"C", "D"},
+			},
+			reducible: false,
+		},
+		{
+			// Node C is unreachable from the entry node.
+			path: "testdata/unreachable.dot",
+			want: [][]string{
+				{"A", "B", "C", "D"},
+				{"I1", "I2"},
+				{"I3"},
+			},
+			orig: map[string][]string{
+				"I1": {"A"},
+				"I2": {"B", "D"},
+				"I3": {"A", "B", "D"},
+			},
+			reducible: true,
+		},
+		{
+			// Interval names skip the names of nodes in the graph.
+			path: "testdata/names.dot",
+			want: [][]string{
+				{"I1", "I2", "I3"},
+				{"I4", "I5"},
+				{"I6"},
+			},
+			orig: map[string][]string{
+				"I4": {"I1"},
+				"I5": {"I2", "I3"},
+				"I6": {"I1", "I2", "I3"},
+			},
+			reducible: true,
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		d, err := DerivedSequence(in)
+		if err != nil {
+			t.Errorf("%q; unable to compute derived sequence; %v", gold.path, err)
+			continue
+		}
+		if len(d.Graphs) != len(d.Intervals) {
+			t.Errorf("%q; length mismatch between graphs (%d) and intervals (%d) of derived sequence", gold.path, len(d.Graphs), len(d.Intervals))
+			continue
+		}
+		var got [][]string
+		gotOrig := make(map[string][]string)
+		for _, g := range d.Graphs {
+			var names []string
+			for _, n := range nodesOf(sortByDOTID(graph.NodesOf(g.Nodes()))) {
+				names = append(names, n.DOTID())
+				if _, ok := d.orig[n.DOTID()]; !ok {
+					continue
+				}
+				for _, o := range d.Orig(n) {
+					gotOrig[n.DOTID()] = append(gotOrig[n.DOTID()], o.DOTID())
+				}
+			}
+			got = append(got, names)
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; derived sequence mismatch; expected `%v`, got `%v`", gold.path, gold.want, got)
+		}
+		if !reflect.DeepEqual(gotOrig, gold.orig) {
+			t.Errorf("%q; original nodes mismatch; expected `%v`, got `%v`", gold.path, gold.orig, gotOrig)
+		}
+		if d.Limit() != d.Graphs[len(d.Graphs)-1] {
+			t.Errorf("%q; limit graph mismatch", gold.path)
+		}
+		if got := d.Reducible(); got != gold.reducible {
+			t.Errorf("%q; reducibility mismatch; expected %v, got %v", gold.path, gold.reducible, got)
+		}
+	}
+}
+
+func TestDerivedSequenceNoEntry(t *testing.T) {
+	if _, err := DerivedSequence(NewGraph()); errors.Cause(err) != ErrNoEntry {
+		t.Errorf("error mismatch; expected %v, got %v", ErrNoEntry, err)
+	}
+}
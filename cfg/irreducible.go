@@ -0,0 +1,275 @@
+package cfg
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/mewkiz/pkg/natsort"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Irreducible loops ] ===================================================
+
+// MakeReducible returns a new control flow graph where each multi-entry cycle of
+// the given control flow graph has been transformed into a single-entry loop,
+// using the semantics-preserving transformation of Yakdan et al. [1].
+//
+// For each strongly connected component with more than one entry node (i.e.
+// nodes targeted by edges from outside of the component, or the entry node of
+// the graph), a dispatch node is added which branches to each entry node based
+// on the value of a new selector variable. Every edge to an entry node, from
+// within or outside of the component, is redirected through a new node which
+// assigns the index of the entry node to the selector variable, and continues
+// to the dispatch node; thus the dispatch node becomes the single entry node of
+// the loop. Nested strongly connected components of the loop body are
+// transformed recursively.
+//
+// The assignments of new nodes are recorded by the DOT attribute "assign" (see
+// Node.Assign), and dispatch nodes record their selector variable in the DOT
+// attribute "dispatch". Assignment nodes continue unconditionally to the
+// dispatch node, and the dispatch node branches to each entry node through a
+// case edge of its selector value, except for the last entry node which is the
+// default target; e.g.
+//
+//    B2.sel1 [assign="%B2.sel = 1"]
+//    B2.dispatch [dispatch="%B2.sel"]
+//    B2.sel1 -> B2.dispatch [kind=unconditional]
+//    B2.dispatch -> B2 [kind=case label="%B2.sel == 1" values="1"]
+//    B2.dispatch -> B3 [kind=default label="%B2.sel != 1"]
+//
+// The nodes and edges of the given graph are left unmodified.
+//
+// [1]: https://www.ndss-symposium.org/ndss2015/ndss-2015-programme/no-more-gotos-decompilation-using-pattern-independent-control-flow-structuring-and-semantics/
+func MakeReducible(src *Graph) (*Graph, error) {
+	g := NewGraph()
+	if err := Copy(g, src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	nodes := make(map[*Node]bool)
+	for _, n := range nodesOf(graph.NodesOf(g.Nodes())) {
+		nodes[n] = true
+	}
+	g.makeReducible(nodes)
+	return g, nil
+}
+
+// makeReducible transforms the multi-entry strongly connected components of the
+// subgraph with the given nodes into single-entry loops.
+func (g *Graph) makeReducible(nodes map[*Node]bool) {
+	for _, scc := range g.sccs(nodes) {
+		if !g.isCyclic(scc) {
+			continue
+		}
+		inSCC := make(map[*Node]bool)
+		for _, n := range scc {
+			inSCC[n] = true
+		}
+		entries := g.sccEntries(scc, inSCC)
+		head := entries[0]
+		if len(entries) > 1 {
+			head = g.dispatch(entries, inSCC)
+		}
+		delete(inSCC, head)
+		g.makeReducible(inSCC)
+	}
+}
+
+// dispatch adds a dispatch node branching to the given entry nodes of a
+// strongly connected component based on the value of a new selector variable,
+// and redirects every edge to the entry nodes through new assignment nodes to
+// the dispatch node. The new nodes are added to the given strongly connected
+// component. The dispatch node is returned.
+func (g *Graph) dispatch(entries []*Node, inSCC map[*Node]bool) *Node {
+	base := entries[0].name
+	v := fmt.Sprintf("%%%s.sel", base)
+	d := g.newNodeWithUniqueName(base + ".dispatch")
+	d.Attrs["dispatch"] = v
+	inSCC[d] = true
+	others := &cond.And{}
+	for i, entry := range entries {
+		value := i + 1
+		isEntry := entry.entry
+		if isEntry {
+			// Enter the loop through the dispatch node from a new entry node.
+			old := entry
+			entry = g.replaceNode(old)
+			entry.entry = false
+			if entry.Attrs["label"] == "entry" {
+				delete(entry.Attrs, "label")
+			}
+			delete(inSCC, old)
+			inSCC[entry] = true
+		}
+		preds := nodesOf(sortByDOTID(graph.NodesOf(g.To(entry.ID()))))
+		if isEntry {
+			preds = append(preds, nil)
+		}
+		for _, pred := range preds {
+			a := g.newNodeWithUniqueName(fmt.Sprintf("%s.sel%d", base, value))
+			a.Attrs["assign"] = fmt.Sprintf("%s = %d", v, value)
+			if pred == nil {
+				a.entry = true
+				g.entry = a
+			} else {
+				g.redirect(pred, entry, a)
+			}
+			if pred == nil || inSCC[pred] {
+				inSCC[a] = true
+			}
+			edgeWithKind(g, a, d, EdgeKindUncond, "")
+		}
+		if i == len(entries)-1 {
+			// The last entry node is the default target of the dispatch node.
+			edgeWithKind(g, d, entry, EdgeKindDefault, cond.Normalize(others).String())
+			break
+		}
+		x := &cond.Compare{Op: cond.CmpEq, X: v, Y: strconv.Itoa(value)}
+		others.Xs = append(others.Xs, cond.Negate(x))
+		e := edgeWithKind(g, d, entry, EdgeKindCase, x.String())
+		e.Values = []string{strconv.Itoa(value)}
+	}
+	return d
+}
+
+// replaceNode replaces the given node with a copy of the node, with the same
+// ID, name, attributes and edges; thus the copy may be modified without
+// affecting graphs sharing the original node. The copy is returned.
+func (g *Graph) replaceNode(n *Node) *Node {
+	m := *n
+	m.Attrs = make(Attrs)
+	for key, val := range n.Attrs {
+		m.Attrs[key] = val
+	}
+	var ins, outs []*Edge
+	for _, pred := range graph.NodesOf(g.To(n.ID())) {
+		ins = append(ins, edge(g.Edge(pred.ID(), n.ID())))
+	}
+	for _, succ := range graph.NodesOf(g.From(n.ID())) {
+		outs = append(outs, edge(g.Edge(n.ID(), succ.ID())))
+	}
+	g.RemoveNode(n)
+	if err := g.AddNode(&m); err != nil {
+		// unreachable; node removed.
+		panic(err)
+	}
+	for _, old := range ins {
+		from := old.From()
+		if from.ID() == n.ID() {
+			from = &m
+		}
+		g.SetEdge(&Edge{Edge: g.DirectedGraph.NewEdge(from, &m), Kind: old.Kind, Values: old.Values, Attrs: old.Attrs})
+	}
+	for _, old := range outs {
+		if old.To().ID() == n.ID() {
+			// self-loop already added.
+			continue
+		}
+		g.SetEdge(&Edge{Edge: g.DirectedGraph.NewEdge(&m, old.To()), Kind: old.Kind, Values: old.Values, Attrs: old.Attrs})
+	}
+	return &m
+}
+
+// newNodeWithUniqueName adds a new node to the control flow graph with the
+// given name, or with a numeric suffix added to the name if already present.
+func (g *Graph) newNodeWithUniqueName(name string) *Node {
+	base := name
+	for i := 1; ; i++ {
+		if _, ok := g.nodes[name]; !ok {
+			break
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	return nodeWithName(g, name)
+}
+
+// sccEntries returns the entry nodes of the given strongly connected component,
+// sorted by DOT ID; i.e. the nodes targeted by edges from outside of the
+// component, or the entry node of the graph. The first node of the component is
+// returned as entry node if the component is unreachable.
+func (g *Graph) sccEntries(scc []*Node, inSCC map[*Node]bool) []*Node {
+	var entries []*Node
+	for _, n := range scc {
+		if n.entry {
+			entries = append(entries, n)
+			continue
+		}
+		for _, pred := range nodesOf(graph.NodesOf(g.To(n.ID()))) {
+			if !inSCC[pred] {
+				entries = append(entries, n)
+				break
+			}
+		}
+	}
+	if len(entries) == 0 {
+		return scc[:1]
+	}
+	return nodesOf(sortByDOTID(graphNodesOf(entries)))
+}
+
+// isCyclic reports whether the given strongly connected component contains a
+// cycle; i.e. whether it has more than one node or a self-loop.
+func (g *Graph) isCyclic(scc []*Node) bool {
+	return len(scc) > 1 || g.HasEdgeFromTo(scc[0].ID(), scc[0].ID())
+}
+
+// sccs returns the strongly connected components of the subgraph with the
+// given nodes, as computed by Tarjan's algorithm. The nodes of each component
+// are sorted by DOT ID, and the components are sorted by the DOT ID of their
+// first node.
+func (g *Graph) sccs(nodes map[*Node]bool) [][]*Node {
+	index := make(map[*Node]int)
+	lowlink := make(map[*Node]int)
+	onStack := make(map[*Node]bool)
+	var stack []*Node
+	var sccs [][]*Node
+	var strongConnect func(n *Node)
+	strongConnect = func(n *Node) {
+		index[n] = len(index)
+		lowlink[n] = index[n]
+		stack = append(stack, n)
+		onStack[n] = true
+		for _, succ := range nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID())))) {
+			if !nodes[succ] {
+				continue
+			}
+			if _, ok := index[succ]; !ok {
+				strongConnect(succ)
+				if lowlink[succ] < lowlink[n] {
+					lowlink[n] = lowlink[succ]
+				}
+			} else if onStack[succ] && index[succ] < lowlink[n] {
+				lowlink[n] = index[succ]
+			}
+		}
+		if lowlink[n] != index[n] {
+			return
+		}
+		var scc []*Node
+		for {
+			m := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[m] = false
+			scc = append(scc, m)
+			if m == n {
+				break
+			}
+		}
+		sccs = append(sccs, nodesOf(sortByDOTID(graphNodesOf(scc))))
+	}
+	var ns []graph.Node
+	for n := range nodes {
+		ns = append(ns, n)
+	}
+	for _, n := range nodesOf(sortByDOTID(ns)) {
+		if _, ok := index[n]; !ok {
+			strongConnect(n)
+		}
+	}
+	sort.Slice(sccs, func(i, j int) bool {
+		return natsort.Less(sccs[i][0].name, sccs[j][0].name)
+	})
+	return sccs
+}
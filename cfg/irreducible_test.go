@@ -0,0 +1,57 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestMakeReducible(t *testing.T) {
+	golden := []struct {
+		path string
+		// Golden output; or empty if the graph is reducible.
+		wantPath string
+	}{
+		{path: "testdata/irreducible.dot", wantPath: "testdata/irreducible.dot.reducible.golden"},
+		{path: "testdata/sample.dot"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		orig := in.String()
+		want := orig
+		if len(gold.wantPath) > 0 {
+			buf, err := ioutil.ReadFile(gold.wantPath)
+			if err != nil {
+				t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+				continue
+			}
+			want = strings.TrimSpace(string(buf))
+		}
+		out, err := MakeReducible(in)
+		if err != nil {
+			t.Errorf("%q; unable to make control flow graph reducible; %v", gold.path, err)
+			continue
+		}
+		if got := strings.TrimSpace(out.String()); got != strings.TrimSpace(want) {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+		}
+		if got := in.String(); got != orig {
+			t.Errorf("%q; source graph modified; expected `%s`, got `%s`", gold.path, orig, got)
+		}
+		// Verify that the transformed graph is reducible.
+		InitDFSOrder(out)
+		d, err := DerivedSequence(out)
+		if err != nil {
+			t.Errorf("%q; unable to compute derived sequence; %v", gold.path, err)
+			continue
+		}
+		if !d.Reducible() {
+			t.Errorf("%q; expected reducible graph after transformation", gold.path)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+package cfg
+
+import (
+	"fmt"
+	"strings"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Natural loops ] =======================================================
+
+// Loop is a natural loop of a control flow graph.
+type Loop struct {
+	// Header node of the loop.
+	Head *Node
+	// Latch nodes of the loop; i.e. the source nodes of back edges to the
+	// header node, sorted by DOT ID.
+	Latches []*Node
+	// Nodes of the loop, sorted by DOT ID; including the header node.
+	Nodes []*Node
+}
+
+// NaturalLoop returns the natural loop with the given header node; i.e. the
+// header node and every node which reaches a latch node without passing
+// through the header node. The latch nodes of the loop are the predecessors of
+// the header node dominated by the header node. A nil loop is returned if the
+// node is not the header node of a loop.
+func NaturalLoop(g *Graph, dt *DomTree, head *Node) *Loop {
+	var latches []*Node
+	for _, pred := range nodesOf(sortByDOTID(graph.NodesOf(g.To(head.ID())))) {
+		if dt.Dominates(head, pred) {
+			latches = append(latches, pred)
+		}
+	}
+	if len(latches) == 0 {
+		return nil
+	}
+	in := map[*Node]bool{head: true}
+	nodes := []*Node{head}
+	queue := append([]*Node(nil), latches...)
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		if in[n] {
+			continue
+		}
+		in[n] = true
+		nodes = append(nodes, n)
+		queue = append(queue, nodesOf(sortByDOTID(graph.NodesOf(g.To(n.ID()))))...)
+	}
+	return &Loop{
+		Head:    head,
+		Latches: latches,
+		Nodes:   nodesOf(sortByDOTID(graphNodesOf(nodes))),
+	}
+}
+
+// Contains reports whether the loop contains the given node.
+func (l *Loop) Contains(n *Node) bool {
+	return containsNode(l.Nodes, n)
+}
+
+// Succs returns the successors of the loop; i.e. the nodes outside of the loop
+// which are targeted by edges from within the loop, sorted by DOT ID.
+func (l *Loop) Succs(g *Graph) []*Node {
+	var succs []*Node
+	for _, e := range l.Exits(g) {
+		if to := node(e.To()); !containsNode(succs, to) {
+			succs = append(succs, to)
+		}
+	}
+	return nodesOf(sortByDOTID(graphNodesOf(succs)))
+}
+
+// Exits returns the exit edges of the loop; i.e. the edges from nodes within
+// the loop to nodes outside of the loop.
+func (l *Loop) Exits(g *Graph) []*Edge {
+	var exits []*Edge
+	for _, n := range l.Nodes {
+		for _, succ := range nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID())))) {
+			if !l.Contains(succ) {
+				exits = append(exits, edge(g.Edge(n.ID(), succ.ID())))
+			}
+		}
+	}
+	return exits
+}
+
+// Entries returns the abnormal entry edges of the loop; i.e. the edges from
+// nodes outside of the loop to nodes within the loop other than the header
+// node.
+func (l *Loop) Entries(g *Graph) []*Edge {
+	var entries []*Edge
+	for _, n := range l.Nodes {
+		if n == l.Head {
+			continue
+		}
+		for _, pred := range nodesOf(sortByDOTID(graph.NodesOf(g.To(n.ID())))) {
+			if !l.Contains(pred) {
+				entries = append(entries, edge(g.Edge(pred.ID(), n.ID())))
+			}
+		}
+	}
+	return entries
+}
+
+// Add adds the given node to the loop.
+func (l *Loop) Add(n *Node) {
+	if l.Contains(n) {
+		return
+	}
+	l.Nodes = nodesOf(sortByDOTID(graphNodesOf(append(l.Nodes, n))))
+}
+
+// === [ Loop nesting forest ] =================================================
+
+// LoopForest is a loop nesting forest of a control flow graph, which captures
+// the loops of arbitrary (including irreducible) control flow graphs.
+type LoopForest struct {
+	// Outermost loops of the control flow graph, sorted by the DOT ID of their
+	// first header node.
+	Roots []*LoopNest
+	// innermost maps from node to the innermost loop containing the node.
+	innermost map[*Node]*LoopNest
+}
+
+// LoopNest is a loop of a loop nesting forest.
+type LoopNest struct {
+	// Header nodes of the loop, sorted by DOT ID; i.e. the nodes of the loop
+	// targeted by edges from outside of the loop, or the entry node of the
+	// control flow graph. Reducible loops have a single header node.
+	Headers []*Node
+	// Nodes of the loop, sorted by DOT ID; including the header nodes and the
+	// nodes of nested loops.
+	Nodes []*Node
+	// Entry edges of the loop; i.e. the edges from nodes outside of the loop to
+	// nodes within the loop.
+	Entries []*Edge
+	// Exit edges of the loop; i.e. the edges from nodes within the loop to
+	// nodes outside of the loop.
+	Exits []*Edge
+	// Parent loop; or nil if outermost loop.
+	Parent *LoopNest
+	// Nested loops, sorted by the DOT ID of their first header node.
+	Children []*LoopNest
+	// Nesting depth of the loop; 1 for outermost loops.
+	Depth int
+	// Irreducible specifies whether the loop has more than one header node.
+	Irreducible bool
+}
+
+// NewLoopForest returns the loop nesting forest of the given control flow
+// graph, as computed by the algorithm of Ramalingam [1], using the loop header
+// definition of Steensgaard.
+//
+// The outermost loops are the strongly connected components of the control
+// flow graph (with more than one node, or a self-loop). The headers of a loop
+// are its entry nodes, and the nested loops are the strongly connected
+// components of the loop body with the edges to its header nodes removed.
+//
+// [1]: https://doi.org/10.1145/570886.570887
+func NewLoopForest(g *Graph) *LoopForest {
+	f := &LoopForest{innermost: make(map[*Node]*LoopNest)}
+	nodes := make(map[*Node]bool)
+	for _, n := range nodesOf(graph.NodesOf(g.Nodes())) {
+		nodes[n] = true
+	}
+	f.Roots = f.loops(g, nodes, nil)
+	return f
+}
+
+// loops returns the loops of the subgraph with the given nodes, nested within
+// the given parent loop.
+func (f *LoopForest) loops(g *Graph, nodes map[*Node]bool, parent *LoopNest) []*LoopNest {
+	var loops []*LoopNest
+	for _, scc := range g.sccs(nodes) {
+		if !g.isCyclic(scc) {
+			continue
+		}
+		inSCC := make(map[*Node]bool)
+		for _, n := range scc {
+			inSCC[n] = true
+		}
+		l := &LoopNest{
+			Nodes:  scc,
+			Parent: parent,
+			Depth:  1,
+		}
+		if parent != nil {
+			l.Depth = parent.Depth + 1
+		}
+		for _, n := range scc {
+			f.innermost[n] = l
+			if n.entry {
+				l.Headers = append(l.Headers, n)
+			}
+			for _, pred := range nodesOf(sortByDOTID(graph.NodesOf(g.To(n.ID())))) {
+				if !inSCC[pred] {
+					l.Entries = append(l.Entries, edge(g.Edge(pred.ID(), n.ID())))
+					if !containsNode(l.Headers, n) {
+						l.Headers = append(l.Headers, n)
+					}
+				}
+			}
+			for _, succ := range nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID())))) {
+				if !inSCC[succ] {
+					l.Exits = append(l.Exits, edge(g.Edge(n.ID(), succ.ID())))
+				}
+			}
+		}
+		if len(l.Headers) == 0 {
+			// Loop unreachable from the entry node.
+			l.Headers = scc[:1]
+		}
+		l.Irreducible = len(l.Headers) > 1
+		body := make(map[*Node]bool)
+		for _, n := range scc {
+			if !containsNode(l.Headers, n) {
+				body[n] = true
+			}
+		}
+		l.Children = f.loops(g, body, l)
+		loops = append(loops, l)
+	}
+	return loops
+}
+
+// Loops returns the loops of the loop nesting forest, in pre-order.
+func (f *LoopForest) Loops() []*LoopNest {
+	var loops []*LoopNest
+	var walk func(ls []*LoopNest)
+	walk = func(ls []*LoopNest) {
+		for _, l := range ls {
+			loops = append(loops, l)
+			walk(l.Children)
+		}
+	}
+	walk(f.Roots)
+	return loops
+}
+
+// Innermost returns the innermost loop containing the given node; or nil if
+// not part of any loop.
+func (f *LoopForest) Innermost(n *Node) *LoopNest {
+	return f.innermost[n]
+}
+
+// Depth returns the loop nesting depth of the given node; i.e. the number of
+// loops containing the node.
+func (f *LoopForest) Depth(n *Node) int {
+	if l := f.innermost[n]; l != nil {
+		return l.Depth
+	}
+	return 0
+}
+
+// Reducible reports whether every loop of the loop nesting forest is
+// reducible.
+func (f *LoopForest) Reducible() bool {
+	for _, l := range f.Loops() {
+		if l.Irreducible {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns a string representation of the loop nesting forest, with one
+// line per loop; e.g.
+//
+//    loop B2 (depth 1): B2, B3, B4; entries: B1 -> B2; exits: B4 -> B5
+//    irreducible loop B, C (depth 1): B, C; entries: A -> B, A -> C; exits: C -> D
+func (f *LoopForest) String() string {
+	buf := &strings.Builder{}
+	for _, l := range f.Loops() {
+		indent := strings.Repeat("\t", l.Depth-1)
+		kind := "loop"
+		if l.Irreducible {
+			kind = "irreducible loop"
+		}
+		fmt.Fprintf(buf, "%s%s %s (depth %d): %s; entries: %s; exits: %s\n", indent, kind, nodeNames(l.Headers), l.Depth, nodeNames(l.Nodes), edgeNames(l.Entries), edgeNames(l.Exits))
+	}
+	return buf.String()
+}
+
+// nodeNames returns the comma-separated names of the given nodes.
+func nodeNames(ns []*Node) string {
+	var names []string
+	for _, n := range ns {
+		names = append(names, n.name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// edgeNames returns the comma-separated names of the given edges.
+func edgeNames(es []*Edge) string {
+	var names []string
+	for _, e := range es {
+		names = append(names, fmt.Sprintf("%s -> %s", node(e.From()).name, node(e.To()).name))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,118 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNaturalLoop(t *testing.T) {
+	golden := []struct {
+		path string
+		head string
+		// Latch nodes and nodes of the loop; nil if not a loop header.
+		latches, nodes []string
+		// Loop successors.
+		succs []string
+	}{
+		{
+			path:    "testdata/sample.dot",
+			head:    "B6",
+			latches: []string{"B15"},
+			nodes:   []string{"B6", "B12", "B13", "B14", "B15"},
+			succs:   []string{"B7"},
+		},
+		{
+			path:    "testdata/sample.dot",
+			head:    "B13",
+			latches: []string{"B14"},
+			nodes:   []string{"B13", "B14"},
+			succs:   []string{"B15"},
+		},
+		{
+			path: "testdata/sample.dot",
+			head: "B7",
+		},
+	}
+	for _, gold := range golden {
+		g, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		head, _ := g.NodeWithName(gold.head)
+		loop := NaturalLoop(g, NewDomTree(g), head)
+		if gold.nodes == nil {
+			if loop != nil {
+				t.Errorf("%q; expected no loop with header %q, got %v", gold.path, gold.head, names(loop.Nodes))
+			}
+			continue
+		}
+		if loop == nil {
+			t.Errorf("%q; unable to locate loop with header %q", gold.path, gold.head)
+			continue
+		}
+		if got := names(loop.Latches); !reflect.DeepEqual(got, gold.latches) {
+			t.Errorf("%q; latch nodes mismatch of loop %q; expected %v, got %v", gold.path, gold.head, gold.latches, got)
+		}
+		if got := names(loop.Nodes); !reflect.DeepEqual(got, gold.nodes) {
+			t.Errorf("%q; nodes mismatch of loop %q; expected %v, got %v", gold.path, gold.head, gold.nodes, got)
+		}
+		if got := names(loop.Succs(g)); !reflect.DeepEqual(got, gold.succs) {
+			t.Errorf("%q; successors mismatch of loop %q; expected %v, got %v", gold.path, gold.head, gold.succs, got)
+		}
+		if entries := loop.Entries(g); len(entries) != 0 {
+			t.Errorf("%q; expected no abnormal entries of loop %q, got %d", gold.path, gold.head, len(entries))
+		}
+	}
+}
+
+// names returns the names of the given nodes.
+func names(ns []*Node) []string {
+	var names []string
+	for _, n := range ns {
+		names = append(names, n.DOTID())
+	}
+	return names
+}
+
+func TestLoopForest(t *testing.T) {
+	golden := []struct {
+		path string
+		want string
+		// Nesting depth of nodes.
+		depths map[string]int
+	}{
+		{
+			path: "testdata/sample.dot",
+			want: "loop B6 (depth 1): B6, B12, B13, B14, B15; entries: B5 -> B6; exits: B6 -> B7\n" +
+				"\tloop B13 (depth 2): B13, B14; entries: B12 -> B13; exits: B14 -> B15\n",
+			depths: map[string]int{"B1": 0, "B6": 1, "B12": 1, "B13": 2, "B14": 2},
+		},
+		{
+			path:   "testdata/irreducible.dot",
+			want:   "irreducible loop B, C (depth 1): B, C; entries: A -> B, A -> C; exits: C -> D\n",
+			depths: map[string]int{"A": 0, "B": 1, "C": 1, "D": 0},
+		},
+		{
+			path: "testdata/irreducible2.dot",
+			want: "irreducible loop B, C, D (depth 1): B, C, D, E; entries: A -> B, A -> C, A -> D; exits: E -> F\n",
+		},
+	}
+	for _, gold := range golden {
+		g, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		f := NewLoopForest(g)
+		if got := f.String(); got != gold.want {
+			t.Errorf("%q; loop nesting forest mismatch; expected `%s`, got `%s`", gold.path, gold.want, got)
+		}
+		for name, want := range gold.depths {
+			n, _ := g.NodeWithName(name)
+			if got := f.Depth(n); got != want {
+				t.Errorf("%q; loop nesting depth mismatch of node %q; expected %d, got %d", gold.path, name, want, got)
+			}
+		}
+	}
+}
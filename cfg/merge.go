@@ -1,21 +1,47 @@
 package cfg
 
 import (
+	"sort"
+
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
 	"gonum.org/v1/gonum/graph"
 )
 
 // Merge returns a new control flow graph where the specified nodes have been
 // collapsed into a single node with the new node name, and the predecessors and
-// successors of the specified nodes.
-func Merge(src *Graph, delNodes map[string]bool, newName string) *Graph {
+// successors of the specified nodes. An error is returned if a node with the
+// new node name is already present in the source graph.
+//
+// The kind and DOT attributes of edges from predecessors are preserved if the
+// predecessor has a single edge to the specified nodes. The edge from a
+// predecessor with several edges to the specified nodes is unconditional if
+// every edge of the predecessor leads to the new node, and otherwise combines
+// the old edges (see mergeEdges). Likewise, the kind and DOT attributes of edges
+// to successors are preserved if the successor is targeted by a single edge from
+// the specified nodes, and combined otherwise.
+func Merge(src *Graph, delNodes map[string]bool, newName string) (*Graph, error) {
 	dst := NewGraph()
-	Copy(dst, src)
-	// preds marks predecessor nodes and records their edge attributes.
-	preds := make(map[graph.Node]Attrs)
-	succs := make(map[graph.Node]bool)
+	if err := Copy(dst, src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// preds maps from predecessor node to the edges from the predecessor.
+	preds := make(map[graph.Node][]*Edge)
+	// succs maps from successor node to the edges to the successor.
+	succs := make(map[graph.Node][]*Edge)
 	newNode := dst.NewNodeWithName(newName)
+	// Visit nodes in a deterministic order, as the labels of combined edges
+	// depend on the order of the old edges.
+	var delNames []string
 	for delName := range delNodes {
-		delNode := dst.nodeWithName(delName)
+		delNames = append(delNames, delName)
+	}
+	sort.Strings(delNames)
+	for _, delName := range delNames {
+		delNode, ok := dst.NodeWithName(delName)
+		if !ok {
+			return nil, errors.Errorf("unable to locate node %q to merge", delName)
+		}
 		if delNode.entry {
 			newNode.entry = true
 		}
@@ -25,7 +51,8 @@ func Merge(src *Graph, delNodes map[string]bool, newName string) *Graph {
 			pred := to.Node()
 			p := node(pred)
 			if !delNodes[p.name] {
-				preds[dst.nodeWithName(p.name)] = edge(dst.Edge(p.ID(), delNode.ID())).Attrs
+				pp := dst.nodeWithName(p.name)
+				preds[pp] = append(preds[pp], edge(dst.Edge(p.ID(), delNode.ID())))
 			}
 		}
 		// Record successors not part of nodes.
@@ -34,24 +61,92 @@ func Merge(src *Graph, delNodes map[string]bool, newName string) *Graph {
 			succ := from.Node()
 			s := node(succ)
 			if !delNodes[s.name] {
-				succs[dst.nodeWithName(s.name)] = true
+				ss := dst.nodeWithName(s.name)
+				succs[ss] = append(succs[ss], edge(dst.Edge(delNode.ID(), s.ID())))
 			}
 		}
 		dst.RemoveNode(delNode)
 	}
 	// Add new node after removing old nodes, to prevent potential collision with
 	// previous entry node.
-	dst.AddNode(newNode)
+	if err := dst.AddNode(newNode); err != nil {
+		return nil, errors.WithStack(err)
+	}
 	// Add edges from predecessors to new node.
-	for pred, attrs := range preds {
+	for pred, olds := range preds {
 		e := edge(dst.NewEdge(pred, newNode))
-		e.Attrs = attrs
+		// The predecessor branches unconditionally to the new node if every
+		// edge of the predecessor leads to the new node.
+		if len(olds) > 1 && dst.From(pred.ID()).Len() == 0 {
+			e.Kind = EdgeKindUncond
+		} else {
+			mergeEdges(e, olds)
+		}
 		dst.SetEdge(e)
 	}
 	// Add edges from new node to successors.
-	for succ := range succs {
-		e := dst.NewEdge(newNode, succ)
+	for succ, olds := range succs {
+		e := edge(dst.NewEdge(newNode, succ))
+		mergeEdges(e, olds)
 		dst.SetEdge(e)
 	}
-	return dst
+	return dst, nil
+}
+
+// mergeEdges sets the kind, case values and DOT attributes of the given edge to
+// those of the combined old edges.
+//
+// The edge of a single old edge preserves its kind, case values and DOT
+// attributes. Otherwise, the edge is a default edge if any of the conditional
+// old edges is a default edge, a case edge of the combined case values if each
+// of the conditional old edges is a case edge, and of the kind of the
+// conditional old edges if shared. The label of the edge is the disjunction of
+// the labels of the conditional old edges. Unconditional old edges (e.g. of
+// distinct nodes merged) are ignored if combined with conditional old edges.
+func mergeEdges(e *Edge, olds []*Edge) {
+	if len(olds) == 1 {
+		e.Kind = olds[0].Kind
+		e.Values = olds[0].Values
+		e.Attrs = olds[0].Attrs
+		return
+	}
+	var conds []*Edge
+	for _, old := range olds {
+		if old.Kind != EdgeKindUncond {
+			conds = append(conds, old)
+		}
+	}
+	if len(conds) == 0 {
+		e.Kind = EdgeKindUncond
+		return
+	}
+	e.Kind = conds[0].Kind
+	var xs []cond.Expr
+	labelled := true
+	for _, old := range conds {
+		switch {
+		case old.Kind == EdgeKindDefault || e.Kind == EdgeKindDefault:
+			e.Kind = EdgeKindDefault
+		case old.Kind != e.Kind:
+			e.Kind = EdgeKindNone
+		}
+		e.Values = append(e.Values, old.Values...)
+		x, err := cond.Parse(unquote(old.Attrs["label"]))
+		if err != nil {
+			// Labels not in the textual notation of package cond are dropped.
+			labelled = false
+			continue
+		}
+		if or, ok := x.(*cond.Or); ok {
+			xs = append(xs, or.Xs...)
+		} else {
+			xs = append(xs, x)
+		}
+	}
+	if e.Kind != EdgeKindCase {
+		e.Values = nil
+	}
+	if labelled {
+		e.Attrs["label"] = (&cond.Or{Xs: xs}).String()
+	}
 }
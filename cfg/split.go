@@ -0,0 +1,188 @@
+package cfg
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// === [ Node splitting ] ======================================================
+
+// SplitNode adds a copy of the given node to the control flow graph, with a
+// name derived from the node name (e.g. "B3.1" for "B3"), the DOT attributes of
+// the node, and copies of its out-edges. The in-edges of the node are left
+// unmodified. The copy is returned.
+func (g *Graph) SplitNode(n *Node) *Node {
+	var name string
+	for i := 1; ; i++ {
+		name = fmt.Sprintf("%s.%d", n.name, i)
+		if _, ok := g.nodes[name]; !ok {
+			break
+		}
+	}
+	c := nodeWithName(g, name)
+	for key, val := range n.Attrs {
+		if key == "label" && n.entry {
+			continue
+		}
+		c.Attrs[key] = val
+	}
+	for _, succ := range nodesOf(sortByDOTID(graph.NodesOf(g.From(n.ID())))) {
+		old := edge(g.Edge(n.ID(), succ.ID()))
+		e := edge(g.NewEdge(c, succ))
+		e.Kind = old.Kind
+		e.Values = old.Values
+		for key, val := range old.Attrs {
+			e.Attrs[key] = val
+		}
+		g.SetEdge(e)
+	}
+	return c
+}
+
+// MakeReducibleBySplitting returns a new control flow graph where each
+// multi-entry cycle of the given control flow graph has been transformed into a
+// single-entry loop, using controlled node splitting in the style of Janssen
+// and Corporaal [1].
+//
+// For each strongly connected component with more than one entry node, one
+// entry node is kept as the loop header. For every other entry node e, the
+// nodes of the component reachable from e without passing through the header
+// node are split (see Graph.SplitNode), and the edges entering e from outside
+// of the component are redirected to the copy of e; thus the copies lead back
+// into the loop through its header. The header node is selected to minimize
+// the number of split nodes, except for components containing the entry node
+// of the graph, which is always kept as the header. Nested strongly connected
+// components of the loop body are transformed recursively, until the graph has
+// no multi-entry cycles.
+//
+// Contrary to MakeReducible, no new variables are introduced; at the cost of
+// duplicated nodes. The nodes and edges of the given graph are left unmodified.
+//
+// [1]: https://doi.org/10.1145/236114.236119
+func MakeReducibleBySplitting(src *Graph) (*Graph, error) {
+	g := NewGraph()
+	if err := Copy(g, src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for {
+		nodes := make(map[*Node]bool)
+		for _, n := range nodesOf(graph.NodesOf(g.Nodes())) {
+			nodes[n] = true
+		}
+		if !g.splitIrreducible(nodes) {
+			return g, nil
+		}
+	}
+}
+
+// splitIrreducible transforms the multi-entry strongly connected components of
+// the subgraph with the given nodes into single-entry loops by node splitting.
+// The boolean return value indicates whether any node was split.
+func (g *Graph) splitIrreducible(nodes map[*Node]bool) bool {
+	changed := false
+	for _, scc := range g.sccs(nodes) {
+		if !g.isCyclic(scc) {
+			continue
+		}
+		inSCC := make(map[*Node]bool)
+		for _, n := range scc {
+			inSCC[n] = true
+		}
+		entries := g.sccEntries(scc, inSCC)
+		head := entries[0]
+		if len(entries) > 1 {
+			head = g.splitEntries(entries, inSCC)
+			changed = true
+		}
+		delete(inSCC, head)
+		if g.splitIrreducible(inSCC) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// splitEntries splits the nodes of the strongly connected component reachable
+// from each entry node but the selected header node, and redirects the edges
+// entering the entry node from outside of the component to its copy. The
+// header node is returned.
+func (g *Graph) splitEntries(entries []*Node, inSCC map[*Node]bool) *Node {
+	// Select the header node which minimizes the number of split nodes.
+	candidates := entries
+	for _, entry := range entries {
+		if entry.entry {
+			candidates = []*Node{entry}
+			break
+		}
+	}
+	var head *Node
+	min := 0
+	for _, h := range candidates {
+		n := 0
+		for _, entry := range entries {
+			if entry != h {
+				n += len(g.splitRegion(entry, h, inSCC))
+			}
+		}
+		if head == nil || n < min {
+			head, min = h, n
+		}
+	}
+	// Split the nodes reachable from the other entry nodes.
+	for _, entry := range entries {
+		if entry == head {
+			continue
+		}
+		region := g.splitRegion(entry, head, inSCC)
+		clones := make(map[*Node]*Node)
+		for _, n := range region {
+			clones[n] = g.SplitNode(n)
+		}
+		for _, n := range region {
+			c := clones[n]
+			for _, succ := range nodesOf(sortByDOTID(graph.NodesOf(g.From(c.ID())))) {
+				if clone, ok := clones[succ]; ok {
+					g.redirect(c, succ, clone)
+				}
+			}
+		}
+		for _, pred := range nodesOf(sortByDOTID(graph.NodesOf(g.To(entry.ID())))) {
+			if !inSCC[pred] {
+				g.redirect(pred, entry, clones[entry])
+			}
+		}
+	}
+	return head
+}
+
+// splitRegion returns the nodes of the strongly connected component reachable
+// from the given entry node without passing through the header node, sorted by
+// DOT ID.
+func (g *Graph) splitRegion(entry, head *Node, inSCC map[*Node]bool) []*Node {
+	visited := map[*Node]bool{entry: true}
+	region := []*Node{entry}
+	for i := 0; i < len(region); i++ {
+		for _, succ := range nodesOf(graph.NodesOf(g.From(region[i].ID()))) {
+			if !inSCC[succ] || succ == head || visited[succ] {
+				continue
+			}
+			visited[succ] = true
+			region = append(region, succ)
+		}
+	}
+	return nodesOf(sortByDOTID(graphNodesOf(region)))
+}
+
+// redirect redirects the edge from the given node to the old target node, to
+// the new target node; preserving the kind and DOT attributes of the edge.
+func (g *Graph) redirect(from, oldTo, newTo *Node) {
+	old := edge(g.Edge(from.ID(), oldTo.ID()))
+	g.RemoveEdge(from.ID(), oldTo.ID())
+	e := edge(g.NewEdge(from, newTo))
+	e.Kind = old.Kind
+	e.Values = old.Values
+	e.Attrs = old.Attrs
+	g.SetEdge(e)
+}
@@ -0,0 +1,85 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestSplitNode(t *testing.T) {
+	g, err := ParseFile("testdata/irreducible.dot")
+	if err != nil {
+		t.Fatalf("unable to parse file; %v", err)
+	}
+	n, _ := g.NodeWithName("C")
+	c := g.SplitNode(n)
+	if got, want := c.DOTID(), "C.1"; got != want {
+		t.Errorf("name mismatch of split node; expected %q, got %q", want, got)
+	}
+	if got, want := names(nodesOf(sortByDOTID(graph.NodesOf(g.From(c.ID()))))), []string{"B", "D"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("successors mismatch of split node; expected %v, got %v", want, got)
+	}
+	if got := g.To(c.ID()).Len(); got != 0 {
+		t.Errorf("expected no predecessors of split node, got %d", got)
+	}
+	if got := g.To(n.ID()).Len(); got != 2 {
+		t.Errorf("predecessors mismatch of original node; expected 2, got %d", got)
+	}
+	if got, want := g.SplitNode(n).DOTID(), "C.2"; got != want {
+		t.Errorf("name mismatch of second split node; expected %q, got %q", want, got)
+	}
+}
+
+func TestMakeReducibleBySplitting(t *testing.T) {
+	golden := []struct {
+		path string
+		// Golden output; or empty if the graph is reducible.
+		wantPath string
+	}{
+		{path: "testdata/irreducible.dot", wantPath: "testdata/irreducible.dot.split.golden"},
+		{path: "testdata/irreducible2.dot", wantPath: "testdata/irreducible2.dot.split.golden"},
+		{path: "testdata/sample.dot"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		orig := in.String()
+		want := orig
+		if len(gold.wantPath) > 0 {
+			buf, err := ioutil.ReadFile(gold.wantPath)
+			if err != nil {
+				t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+				continue
+			}
+			want = string(buf)
+		}
+		out, err := MakeReducibleBySplitting(in)
+		if err != nil {
+			t.Errorf("%q; unable to make control flow graph reducible; %v", gold.path, err)
+			continue
+		}
+		if got := strings.TrimSpace(out.String()); got != strings.TrimSpace(want) {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+		}
+		if got := in.String(); got != orig {
+			t.Errorf("%q; source graph modified; expected `%s`, got `%s`", gold.path, orig, got)
+		}
+		// Verify that the transformed graph is reducible.
+		InitDFSOrder(out)
+		d, err := DerivedSequence(out)
+		if err != nil {
+			t.Errorf("%q; unable to compute derived sequence; %v", gold.path, err)
+			continue
+		}
+		if !d.Reducible() {
+			t.Errorf("%q; expected reducible graph after transformation", gold.path)
+		}
+	}
+}
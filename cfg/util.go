@@ -11,39 +11,6 @@ import (
 	"gonum.org/v1/gonum/graph/encoding/dot"
 )
 
-// InitDFSOrder initializes the pre- and post depth first search visit order of
-// each node.
-func InitDFSOrder(g *Graph) {
-	visited := make(map[graph.Node]bool)
-	// post-order
-	var walk func(n graph.Node)
-	first := 0
-	last := g.Nodes().Len() - 1
-	walk = func(n graph.Node) {
-		nn, ok := n.(*Node)
-		if !ok {
-			panic(fmt.Errorf("invalid node type; exepcted *cfg.Node, got %T", n))
-		}
-		nn.Pre = first
-		first++
-		visited[n] = true
-		for _, succ := range sortByDOTID(graph.NodesOf(g.From(n.ID()))) {
-			if !visited[succ] {
-				walk(succ)
-			}
-		}
-		nn.RevPost = last
-		last--
-	}
-	walk(g.entry)
-	// Ensure that all nodes have been visited.
-	for _, n := range sortByDOTID(graph.NodesOf(g.Nodes())) {
-		if !visited[n] {
-			walk(n)
-		}
-	}
-}
-
 // SortByRevPost sorts the given list of nodes by reverse post-order.
 func SortByRevPost(ns []graph.Node) []*Node {
 	less := func(i, j int) bool {
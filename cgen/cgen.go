@@ -0,0 +1,355 @@
+// Package cgen implements a C pseudo-code back end, which prints LLVM IR
+// functions as C-like source code.
+//
+// The control flow of a function is given by the abstract syntax tree of its
+// structured control flow graph (see cfg.NewGraphFromFunc and
+// structure.Structure), and the instructions of each basic block are printed as
+// C statements within the recovered if, while and switch statements; e.g.
+//
+//    int32_t f(int32_t x) {
+//       bool cond;
+//       int32_t y;
+//
+//       cond = x < 10;
+//       if (cond) {
+//          y = x + 1;
+//          return y;
+//       }
+//       return x;
+//    }
+//
+// The output is intended to be read rather than compiled. Local variables are
+// declared at the start of the function, and phi instructions are lowered to
+// copies through temporary variables (e.g. "x_phi"), assigned at the end of
+// their predecessor basic blocks.
+package cgen
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/internal/backend"
+	"github.com/pkg/errors"
+)
+
+// Fprint writes the C pseudo-code of the given function to w, with the control
+// flow of the function given by the abstract syntax tree n of its structured
+// control flow graph.
+func Fprint(w io.Writer, f *ir.Func, n ast.Node) error {
+	p, err := newPrinter(w, f)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	p.printf("%s {", p.signature())
+	p.indent++
+	if p.decls(n) {
+		p.printf("")
+	}
+	p.stmt(n)
+	p.indent--
+	p.printf("}")
+	return p.err
+}
+
+// Sprint returns the C pseudo-code of the given function (see Fprint).
+func Sprint(f *ir.Func, n ast.Node) (string, error) {
+	buf := &strings.Builder{}
+	if err := Fprint(buf, f, n); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return buf.String(), nil
+}
+
+// printer is a C pseudo-code printer of functions.
+type printer struct {
+	// Output writer.
+	w io.Writer
+	// First error encountered while writing output.
+	err error
+	// Function being printed.
+	f *backend.Func
+	// Current level of indentation.
+	indent int
+	// Loops enclosing the current statement.
+	loops backend.Loops
+}
+
+// newPrinter returns a new C pseudo-code printer of the given function.
+func newPrinter(w io.Writer, f *ir.Func) (*printer, error) {
+	fn, err := backend.NewFunc(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &printer{w: w, f: fn}, nil
+}
+
+// printf writes the formatted line to the output at the current level of
+// indentation.
+func (p *printer) printf(format string, args ...interface{}) {
+	if p.err != nil {
+		return
+	}
+	if len(format) == 0 {
+		_, p.err = io.WriteString(p.w, "\n")
+		return
+	}
+	tabs := strings.Repeat("\t", p.indent)
+	_, p.err = fmt.Fprintf(p.w, tabs+format+"\n", args...)
+}
+
+// signature returns the function signature of the function being printed.
+func (p *printer) signature() string {
+	var params []string
+	for _, param := range p.f.Params {
+		params = append(params, decl(param.Type(), p.value(param)))
+	}
+	if p.f.Sig.Variadic {
+		params = append(params, "...")
+	}
+	if len(params) == 0 {
+		params = append(params, "void")
+	}
+	name := cIdent(p.f.Ident())
+	return fmt.Sprintf("%s(%s)", decl(p.f.Sig.RetType, name), strings.Join(params, ", "))
+}
+
+// decls writes the declarations of the local variables of the function being
+// printed; i.e. the results of its instructions, the temporary variables of its
+// phi instructions and the selector variables assigned by the abstract syntax
+// tree n. The boolean return value indicates
+// whether any variable was declared.
+func (p *printer) decls(n ast.Node) bool {
+	declared := false
+	for _, block := range p.f.Blocks {
+		for _, inst := range block.Insts {
+			v, ok := inst.(value.Value)
+			if !ok || p.f.Resolved(inst) {
+				continue
+			}
+			if _, ok := v.Type().(*types.VoidType); ok {
+				continue
+			}
+			p.printf("%s;", decl(v.Type(), p.value(v)))
+			if phi, ok := inst.(*ir.InstPhi); ok {
+				p.printf("%s;", decl(phi.Type(), backend.PhiVar(phi)))
+			}
+			declared = true
+		}
+	}
+	seen := make(map[string]bool)
+	ast.Inspect(n, func(n ast.Node) bool {
+		if a, ok := n.(*ast.Assign); ok && !seen[a.Var] {
+			seen[a.Var] = true
+			p.printf("int %s;", cIdent(a.Var))
+			declared = true
+		}
+		return true
+	})
+	return declared
+}
+
+// body writes the given node as the body of a compound statement.
+func (p *printer) body(n ast.Node) {
+	p.indent++
+	p.stmt(n)
+	p.indent--
+}
+
+// stmt writes the given node as a C statement.
+func (p *printer) stmt(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Block:
+		p.block(n)
+	case *ast.Seq:
+		for _, nn := range n.Nodes {
+			p.stmt(nn)
+		}
+	case *ast.If:
+		p.printf("if (%s) {", p.cond(n.Cond))
+		p.body(n.Then)
+		p.printf("}")
+	case *ast.IfElse:
+		p.ifElse(n)
+	case *ast.While:
+		p.loop(func() {
+			p.printf("while (%s) {", p.cond(n.Cond))
+			p.body(n.Body)
+			p.printf("}")
+		})
+	case *ast.DoWhile:
+		p.loop(func() {
+			p.printf("do {")
+			p.body(n.Body)
+			p.printf("} while (%s);", p.cond(n.Cond))
+		})
+	case *ast.For:
+		p.forStmt(n)
+	case *ast.Endless:
+		p.loop(func() {
+			p.printf("for (;;) {")
+			p.body(n.Body)
+			p.printf("}")
+		})
+	case *ast.Switch:
+		p.switchStmt(n)
+	case *ast.Break:
+		p.breakStmt()
+	case *ast.Continue:
+		p.printf("continue;")
+	case *ast.Return:
+		if len(n.Value) > 0 {
+			p.printf("return %s;", operand(n.Value))
+			break
+		}
+		p.printf("return;")
+	case *ast.Goto:
+		p.printf("goto %s;", cIdent(n.Label))
+	case *ast.Label:
+		p.printf("%s:", cIdent(n.Name))
+	case *ast.Assign:
+		p.printf("%s;", simpleStmt(n))
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+}
+
+// ifElse writes the given 2-way conditional with else-branch, where nested
+// conditionals of the else-branch are written as else-if clauses.
+func (p *printer) ifElse(n *ast.IfElse) {
+	p.printf("if (%s) {", p.cond(n.Cond))
+	p.body(n.Then)
+	els := n.Else
+chain:
+	for {
+		switch e := els.(type) {
+		case *ast.IfElse:
+			p.printf("} else if (%s) {", p.cond(e.Cond))
+			p.body(e.Then)
+			els = e.Else
+		case *ast.If:
+			p.printf("} else if (%s) {", p.cond(e.Cond))
+			p.body(e.Then)
+			els = nil
+		default:
+			break chain
+		}
+	}
+	if els != nil {
+		p.printf("} else {")
+		p.body(els)
+	}
+	p.printf("}")
+}
+
+// forStmt writes the given for loop. Init and post statements other than
+// assignments are written before the loop and at the end of the loop body
+// respectively.
+func (p *printer) forStmt(n *ast.For) {
+	init, post := "", ""
+	if n.Init != nil {
+		if s, ok := n.Init.(*ast.Assign); ok {
+			init = simpleStmt(s)
+		} else {
+			p.stmt(n.Init)
+		}
+	}
+	body := n.Body
+	if n.Post != nil {
+		if s, ok := n.Post.(*ast.Assign); ok {
+			post = simpleStmt(s)
+		} else {
+			body = ast.NewSeq(body, n.Post)
+		}
+	}
+	p.loop(func() {
+		p.printf("for (%s; %s; %s) {", init, p.cond(n.Cond), post)
+		p.body(body)
+		p.printf("}")
+	})
+}
+
+// switchStmt writes the given switch statement. Each case ends with a break
+// statement, unless its body ends with a jump statement.
+func (p *printer) switchStmt(n *ast.Switch) {
+	defer p.loops.EnterSwitch()()
+	p.printf("switch (%s) {", cIdent(n.Var))
+	clause := func(body ast.Node) {
+		p.body(body)
+		if !p.jumps(body) {
+			p.indent++
+			p.printf("break;")
+			p.indent--
+		}
+	}
+	for _, c := range n.Cases {
+		for _, v := range c.Values {
+			p.printf("case %s:", operand(v))
+		}
+		clause(c.Body)
+	}
+	if n.Default != nil {
+		p.printf("default:")
+		clause(n.Default)
+	}
+	p.printf("}")
+}
+
+// loop writes a loop statement using the given function, followed by the exit
+// label of the loop if used.
+func (p *printer) loop(print func()) {
+	l := p.loops.Enter()
+	print()
+	p.loops.Leave()
+	if l.Labelled {
+		p.printf("%s:;", exitLabel(l))
+	}
+}
+
+// breakStmt writes a break statement of the innermost enclosing loop. Within
+// switch statements, the loop is exited through a goto statement to the exit
+// label of the loop, as break statements would exit the switch statement.
+func (p *printer) breakStmt() {
+	if l := p.loops.Break(); l != nil {
+		p.printf("goto %s;", exitLabel(l))
+		return
+	}
+	p.printf("break;")
+}
+
+// exitLabel returns the exit label of the given loop (e.g. "loop1_exit").
+func exitLabel(l *backend.Loop) string {
+	return fmt.Sprintf("loop%d_exit", l.ID)
+}
+
+// simpleStmt returns the given assignment as a C statement, without trailing
+// semicolon.
+func simpleStmt(n *ast.Assign) string {
+	return fmt.Sprintf("%s = %d", cIdent(n.Var), n.Value)
+}
+
+// jumps reports whether the given node ends with a jump statement; i.e. a
+// break, continue, return or goto statement, or a basic block ending with a
+// return or unreachable terminator.
+func (p *printer) jumps(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.Block:
+		if block, ok := p.f.Block(n); ok {
+			switch block.Term.(type) {
+			case *ir.TermRet, *ir.TermUnreachable:
+				return true
+			}
+		}
+	case *ast.Seq:
+		return len(n.Nodes) > 0 && p.jumps(n.Nodes[len(n.Nodes)-1])
+	case *ast.Break, *ast.Continue, *ast.Return, *ast.Goto:
+		return true
+	case *ast.IfElse:
+		return p.jumps(n.Then) && p.jumps(n.Else)
+	}
+	return false
+}
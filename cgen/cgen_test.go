@@ -0,0 +1,227 @@
+package cgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/internal/backend/backendtest"
+)
+
+func TestFprint(t *testing.T) {
+	golden := []struct {
+		name string
+		f    *ir.Func
+		want string
+	}{
+		{
+			name: "if-else",
+			f:    backendtest.IfElse(),
+			want: `
+int32_t f(int32_t x) {
+	bool cond;
+	int32_t y;
+	int32_t z;
+	int32_t r;
+	int32_t r_phi;
+
+	cond = x < 10;
+	if (cond) {
+		y = x + 1;
+		r_phi = y;
+	} else {
+		z = x - 1;
+		r_phi = z;
+	}
+	r = r_phi;
+	return r;
+}`,
+		},
+		{
+			name: "if",
+			f:    backendtest.If(),
+			want: `
+int32_t abs(int32_t x) {
+	bool neg;
+	int32_t y;
+	int32_t r;
+	int32_t r_phi;
+
+	neg = x < 0;
+	r_phi = x;
+	if (neg) {
+		y = 0 - x;
+		r_phi = y;
+	}
+	r = r_phi;
+	return r;
+}`,
+		},
+		{
+			name: "loop",
+			f:    backendtest.ArrayLoop(),
+			want: `
+int32_t sum(int32_t *p, int32_t n) {
+	int32_t i;
+	int32_t i_phi;
+	int32_t s;
+	int32_t s_phi;
+	bool cond;
+	int32_t *elem;
+	int32_t v;
+	int32_t s_next;
+	int32_t i_next;
+
+	i_phi = 0;
+	s_phi = 0;
+	for (;;) {
+		i = i_phi;
+		s = s_phi;
+		cond = (uint32_t)i < (uint32_t)n;
+		if (!cond) {
+			break;
+		}
+		elem = &p[i];
+		v = *elem;
+		s_next = s + v;
+		i_next = i + 1;
+		i_phi = i_next;
+		s_phi = s_next;
+	}
+	return s;
+}`,
+		},
+		{
+			name: "swap",
+			f:    backendtest.Swap(),
+			want: `
+int32_t swap(int32_t x, int32_t y, int32_t n) {
+	int32_t a;
+	int32_t a_phi;
+	int32_t b;
+	int32_t b_phi;
+	int32_t i;
+	int32_t i_phi;
+	int32_t i_next;
+	bool cond;
+
+	a_phi = x;
+	b_phi = y;
+	i_phi = 0;
+	for (;;) {
+		do {
+			a = a_phi;
+			b = b_phi;
+			i = i_phi;
+			i_next = i + 1;
+			cond = i != n;
+			a_phi = b;
+			b_phi = a;
+			i_phi = i_next;
+		} while (cond);
+		return a;
+	}
+}`,
+		},
+		{
+			name: "chain",
+			f:    backendtest.Chain(),
+			want: `
+int32_t h(int32_t x) {
+	switch (x) {
+	case 1:
+		return 10;
+	case 2:
+		return 20;
+	default:
+		return 0;
+	}
+}`,
+		},
+		{
+			name: "switch",
+			f:    backendtest.Switch(),
+			want: `
+int32_t g(int32_t x) {
+	switch (x) {
+	case 1:
+		return 10;
+	case 2:
+	case 3:
+		return 20;
+	default:
+		return 0;
+	}
+}`,
+		},
+	}
+	for _, gold := range golden {
+		prim, err := backendtest.Structure(gold.f)
+		if err != nil {
+			t.Errorf("%q; unable to structure function; %v", gold.name, err)
+			continue
+		}
+		got, err := Sprint(gold.f, prim)
+		if err != nil {
+			t.Errorf("%q; unable to print function; %v", gold.name, err)
+			continue
+		}
+		want := strings.TrimPrefix(gold.want, "\n") + "\n"
+		if got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.name, want, got)
+		}
+	}
+}
+
+func TestFprintLoopExit(t *testing.T) {
+	// Break statements of a loop within a switch statement exit the loop.
+	f := backendtest.Switch()
+	n := &ast.Endless{Body: &ast.Switch{
+		Var: "x",
+		Cases: []*ast.Case{
+			{Values: []string{"1"}, Body: &ast.Break{}},
+			{Values: []string{"2"}, Body: &ast.Continue{}},
+		},
+	}}
+	want := `
+int32_t g(int32_t x) {
+	for (;;) {
+		switch (x) {
+		case 1:
+			goto loop1_exit;
+		case 2:
+			continue;
+		}
+	}
+	loop1_exit:;
+}
+`[1:]
+	got, err := Sprint(f, n)
+	if err != nil {
+		t.Fatalf("unable to print function; %v", err)
+	}
+	if got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+}
+
+func TestFprintUnsupported(t *testing.T) {
+	// Exceptional control flow has no C equivalent.
+	callee := ir.NewFunc("g", types.Void)
+	f := ir.NewFunc("f", types.Void)
+	entry := f.NewBlock("entry")
+	normal := f.NewBlock("normal")
+	unwind := f.NewBlock("unwind")
+	entry.NewInvoke(callee, nil, normal, unwind)
+	normal.NewRet(nil)
+	unwind.NewUnreachable()
+	prim, err := backendtest.Structure(f)
+	if err != nil {
+		t.Fatalf("unable to structure function; %v", err)
+	}
+	if _, err := Sprint(f, prim); err == nil {
+		t.Errorf("expected error for invoke terminator, got nil")
+	}
+}
@@ -0,0 +1,311 @@
+package cgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/internal/backend"
+	"github.com/pkg/errors"
+)
+
+// === [ Basic blocks ] ========================================================
+
+// block writes the instructions of the basic block of the given node as C
+// statements, followed by the copies of phi instructions in successor basic
+// blocks. Branch terminators and the comparisons evaluated by branching
+// conditions (see backend.Func.Resolved) are omitted, as their control flow is
+// given by the abstract syntax tree.
+func (p *printer) block(n *ast.Block) {
+	block, ok := p.f.Block(n)
+	if !ok {
+		if p.err == nil {
+			p.err = errors.Errorf("unable to locate basic block of node %q in function %q", n.Node.DOTID(), p.f.Name())
+		}
+		return
+	}
+	for _, inst := range block.Insts {
+		if p.f.Resolved(inst) {
+			continue
+		}
+		p.inst(inst)
+	}
+	p.phiCopies(block)
+	switch term := block.Term.(type) {
+	case *ir.TermRet:
+		if term.X == nil {
+			p.printf("return;")
+			break
+		}
+		p.printf("return %s;", p.value(term.X))
+	case *ir.TermBr, *ir.TermCondBr, *ir.TermSwitch, *ir.TermIndirectBr:
+		// Control flow given by the abstract syntax tree.
+	case *ir.TermUnreachable:
+		p.printf("// unreachable")
+	case *ir.TermInvoke, *ir.TermCatchSwitch:
+		// The branching conditions of exceptional control flow (e.g. "A.unwind")
+		// have no C equivalent.
+		if p.err == nil {
+			p.err = errors.Errorf("support for terminator %T not yet implemented", term)
+		}
+	default:
+		p.printf("// %s", term.LLString())
+	}
+}
+
+// phiCopies writes the copies of the incoming values from the given basic
+// block to the temporary variables of the phi instructions of its successors
+// (see backend.Func.PhiCopies).
+func (p *printer) phiCopies(block *ir.Block) {
+	for _, c := range p.f.PhiCopies(block) {
+		p.printf("%s = %s;", backend.PhiVar(c.Phi), p.value(c.X))
+	}
+}
+
+// === [ Instructions ] ========================================================
+
+// inst writes the given instruction as a C statement. Phi instructions are
+// written as copies of their temporary variables, assigned in their predecessor
+// basic blocks (see printer.phiCopies), and instructions without C equivalent
+// are written as comments.
+func (p *printer) inst(inst ir.Instruction) {
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		p.binary(inst, inst.X, "+", inst.Y)
+	case *ir.InstFAdd:
+		p.binary(inst, inst.X, "+", inst.Y)
+	case *ir.InstSub:
+		p.binary(inst, inst.X, "-", inst.Y)
+	case *ir.InstFSub:
+		p.binary(inst, inst.X, "-", inst.Y)
+	case *ir.InstMul:
+		p.binary(inst, inst.X, "*", inst.Y)
+	case *ir.InstFMul:
+		p.binary(inst, inst.X, "*", inst.Y)
+	case *ir.InstUDiv:
+		p.unsigned(inst, inst.X, "/", inst.Y)
+	case *ir.InstSDiv:
+		p.binary(inst, inst.X, "/", inst.Y)
+	case *ir.InstFDiv:
+		p.binary(inst, inst.X, "/", inst.Y)
+	case *ir.InstURem:
+		p.unsigned(inst, inst.X, "%", inst.Y)
+	case *ir.InstSRem:
+		p.binary(inst, inst.X, "%", inst.Y)
+	case *ir.InstFRem:
+		p.assign(inst, "fmod(%s, %s)", p.value(inst.X), p.value(inst.Y))
+	case *ir.InstFNeg:
+		p.assign(inst, "-%s", p.value(inst.X))
+	// Bitwise instructions.
+	case *ir.InstShl:
+		p.binary(inst, inst.X, "<<", inst.Y)
+	case *ir.InstLShr:
+		p.unsigned(inst, inst.X, ">>", inst.Y)
+	case *ir.InstAShr:
+		p.binary(inst, inst.X, ">>", inst.Y)
+	case *ir.InstAnd:
+		p.binary(inst, inst.X, "&", inst.Y)
+	case *ir.InstOr:
+		p.binary(inst, inst.X, "|", inst.Y)
+	case *ir.InstXor:
+		p.binary(inst, inst.X, "^", inst.Y)
+	// Memory instructions.
+	case *ir.InstAlloca:
+		size := fmt.Sprintf("sizeof(%s)", cType(inst.ElemType))
+		if inst.NElems != nil {
+			size = fmt.Sprintf("%s * %s", p.value(inst.NElems), size)
+		}
+		p.assign(inst, "alloca(%s)", size)
+	case *ir.InstLoad:
+		p.assign(inst, "*%s", p.value(inst.Src))
+	case *ir.InstStore:
+		p.printf("*%s = %s;", p.value(inst.Dst), p.value(inst.Src))
+	case *ir.InstGetElementPtr:
+		p.gep(inst)
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstZExt:
+		p.cast(inst, cType(inst.To), p.unsignedValue(inst.From))
+	case *ir.InstSExt:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstFPTrunc:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstFPExt:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstFPToUI:
+		p.cast(inst, unsignedType(inst.To), p.value(inst.From))
+	case *ir.InstFPToSI:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstUIToFP:
+		p.cast(inst, cType(inst.To), p.unsignedValue(inst.From))
+	case *ir.InstSIToFP:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstPtrToInt:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstIntToPtr:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstBitCast:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	case *ir.InstAddrSpaceCast:
+		p.cast(inst, cType(inst.To), p.value(inst.From))
+	// Other instructions.
+	case *ir.InstICmp:
+		p.icmp(inst)
+	case *ir.InstFCmp:
+		p.fcmp(inst)
+	case *ir.InstPhi:
+		p.assign(inst, "%s", backend.PhiVar(inst))
+	case *ir.InstSelect:
+		p.assign(inst, "%s ? %s : %s", p.value(inst.Cond), p.value(inst.X), p.value(inst.Y))
+	case *ir.InstCall:
+		var args []string
+		for _, arg := range inst.Args {
+			args = append(args, p.value(arg))
+		}
+		call := fmt.Sprintf("%s(%s)", p.value(inst.Callee), strings.Join(args, ", "))
+		if _, ok := inst.Type().(*types.VoidType); ok {
+			p.printf("%s;", call)
+			break
+		}
+		p.assign(inst, "%s", call)
+	default:
+		p.printf("// %s", inst.LLString())
+	}
+}
+
+// assign writes an assignment of the formatted expression to the given local
+// variable.
+func (p *printer) assign(v value.Value, format string, args ...interface{}) {
+	p.printf("%s = %s;", p.value(v), fmt.Sprintf(format, args...))
+}
+
+// binary writes an assignment of the binary operation on x and y to the given
+// local variable.
+func (p *printer) binary(v value.Value, x value.Value, op string, y value.Value) {
+	p.assign(v, "%s %s %s", p.value(x), op, p.value(y))
+}
+
+// unsigned writes an assignment of the binary operation on x and y, with
+// operands of unsigned integer type, to the given local variable.
+func (p *printer) unsigned(v value.Value, x value.Value, op string, y value.Value) {
+	p.assign(v, "%s %s %s", p.unsignedValue(x), op, p.unsignedValue(y))
+}
+
+// cast writes an assignment of the given expression converted to the specified
+// type to the given local variable.
+func (p *printer) cast(v value.Value, typ, expr string) {
+	p.assign(v, "(%s)%s", typ, expr)
+}
+
+// icmp writes the given integer comparison instruction.
+func (p *printer) icmp(inst *ir.InstICmp) {
+	var op string
+	switch inst.Pred {
+	case enum.IPredEQ:
+		op = "=="
+	case enum.IPredNE:
+		op = "!="
+	case enum.IPredSGE:
+		op = ">="
+	case enum.IPredSGT:
+		op = ">"
+	case enum.IPredSLE:
+		op = "<="
+	case enum.IPredSLT:
+		op = "<"
+	case enum.IPredUGE:
+		p.unsigned(inst, inst.X, ">=", inst.Y)
+		return
+	case enum.IPredUGT:
+		p.unsigned(inst, inst.X, ">", inst.Y)
+		return
+	case enum.IPredULE:
+		p.unsigned(inst, inst.X, "<=", inst.Y)
+		return
+	case enum.IPredULT:
+		p.unsigned(inst, inst.X, "<", inst.Y)
+		return
+	default:
+		panic(fmt.Errorf("support for integer comparison predicate %v not yet implemented", inst.Pred))
+	}
+	p.binary(inst, inst.X, op, inst.Y)
+}
+
+// fcmp writes the given floating-point comparison instruction. Ordered and
+// unordered comparisons are written alike, except for the ord and uno
+// predicates which check for NaN operands.
+func (p *printer) fcmp(inst *ir.InstFCmp) {
+	x, y := p.value(inst.X), p.value(inst.Y)
+	var op string
+	switch inst.Pred {
+	case enum.FPredFalse:
+		p.assign(inst, "false")
+		return
+	case enum.FPredTrue:
+		p.assign(inst, "true")
+		return
+	case enum.FPredORD:
+		p.assign(inst, "!isnan(%s) && !isnan(%s)", x, y)
+		return
+	case enum.FPredUNO:
+		p.assign(inst, "isnan(%s) || isnan(%s)", x, y)
+		return
+	case enum.FPredOEQ, enum.FPredUEQ:
+		op = "=="
+	case enum.FPredONE, enum.FPredUNE:
+		op = "!="
+	case enum.FPredOGE, enum.FPredUGE:
+		op = ">="
+	case enum.FPredOGT, enum.FPredUGT:
+		op = ">"
+	case enum.FPredOLE, enum.FPredULE:
+		op = "<="
+	case enum.FPredOLT, enum.FPredULT:
+		op = "<"
+	default:
+		panic(fmt.Errorf("support for floating-point comparison predicate %v not yet implemented", inst.Pred))
+	}
+	p.binary(inst, inst.X, op, inst.Y)
+}
+
+// gep writes the given getelementptr instruction as the address of an element
+// of the source pointer; e.g. `&p[i].field_1[j]`.
+func (p *printer) gep(inst *ir.InstGetElementPtr) {
+	ptr, ok := inst.Src.Type().(*types.PointerType)
+	if !ok || len(inst.Indices) == 0 {
+		p.printf("// %s", inst.LLString())
+		return
+	}
+	buf := &strings.Builder{}
+	fmt.Fprintf(buf, "&%s[%s]", p.value(inst.Src), p.value(inst.Indices[0]))
+	t := ptr.ElemType
+	for _, index := range inst.Indices[1:] {
+		switch tt := t.(type) {
+		case *types.StructType:
+			i, ok := index.(*constant.Int)
+			if !ok || !i.X.IsInt64() || i.X.Int64() >= int64(len(tt.Fields)) {
+				p.printf("// %s", inst.LLString())
+				return
+			}
+			fmt.Fprintf(buf, ".field_%d", i.X.Int64())
+			t = tt.Fields[i.X.Int64()]
+		case *types.ArrayType:
+			fmt.Fprintf(buf, "[%s]", p.value(index))
+			t = tt.ElemType
+		case *types.VectorType:
+			fmt.Fprintf(buf, "[%s]", p.value(index))
+			t = tt.ElemType
+		default:
+			p.printf("// %s", inst.LLString())
+			return
+		}
+	}
+	p.assign(inst, "%s", buf.String())
+}
@@ -0,0 +1,150 @@
+package cgen
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/cond"
+	"github.com/mewmew/pi/internal/backend"
+)
+
+// === [ Values ] ==============================================================
+
+// value returns the C expression of the given value.
+func (p *printer) value(v value.Value) string {
+	switch v := v.(type) {
+	case *constant.Null:
+		return "NULL"
+	case value.Named:
+		return cIdent(v.Ident())
+	}
+	return v.Ident()
+}
+
+// unsignedValue returns the C expression of the given value, converted to
+// unsigned integer type if of integer type.
+func (p *printer) unsignedValue(v value.Value) string {
+	t, ok := v.Type().(*types.IntType)
+	if !ok || t.BitSize == 1 {
+		return p.value(v)
+	}
+	return fmt.Sprintf("(%s)%s", unsignedType(t), p.value(v))
+}
+
+// cond returns the C expression of the given branching condition, as
+// represented in the textual notation of package cond.
+func (p *printer) cond(c string) string {
+	x, err := cond.Parse(c)
+	if err != nil {
+		return c
+	}
+	return renameCond(x).String()
+}
+
+// renameCond returns the given condition with variables renamed to C
+// identifiers.
+func renameCond(x cond.Expr) cond.Expr {
+	switch x := x.(type) {
+	case *cond.Var:
+		return &cond.Var{Name: cIdent(x.Name)}
+	case *cond.Compare:
+		return &cond.Compare{Op: x.Op, X: cIdent(x.X), Y: operand(x.Y)}
+	case *cond.Not:
+		return &cond.Not{X: renameCond(x.X)}
+	case *cond.And:
+		y := &cond.And{}
+		for _, xx := range x.Xs {
+			y.Xs = append(y.Xs, renameCond(xx))
+		}
+		return y
+	case *cond.Or:
+		y := &cond.Or{}
+		for _, xx := range x.Xs {
+			y.Xs = append(y.Xs, renameCond(xx))
+		}
+		return y
+	}
+	return x
+}
+
+// operand returns the C expression of the given operand of a condition or case
+// value; i.e. constants are kept as is, and variables are renamed to C
+// identifiers.
+func operand(s string) string {
+	switch {
+	case s == "true" || s == "false":
+		return s
+	case s == "null":
+		return "NULL"
+	case len(s) > 0 && (s[0] == '-' || unicode.IsDigit(rune(s[0]))):
+		return s
+	}
+	return cIdent(s)
+}
+
+// === [ Identifiers ] =========================================================
+
+// cIdent returns the C identifier of the given LLVM IR identifier (e.g. "foo"
+// for "%foo", "_1" for "%1" and "foo_bar" for `%"foo.bar"`).
+func cIdent(ident string) string {
+	return backend.Ident(ident)
+}
+
+// === [ Types ] ===============================================================
+
+// cType returns the C type of the given LLVM IR type.
+func cType(t types.Type) string {
+	switch t := t.(type) {
+	case *types.VoidType:
+		return "void"
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return "bool"
+		}
+		return fmt.Sprintf("int%d_t", t.BitSize)
+	case *types.FloatType:
+		switch t.Kind {
+		case types.FloatKindHalf:
+			return "_Float16"
+		case types.FloatKindFloat:
+			return "float"
+		case types.FloatKindDouble:
+			return "double"
+		default:
+			return "long double"
+		}
+	case *types.PointerType:
+		if _, ok := t.ElemType.(*types.FuncType); ok {
+			return "void *"
+		}
+		return decl(t.ElemType, "*")
+	case *types.StructType:
+		if len(t.TypeName) > 0 {
+			return "struct " + cIdent(t.TypeName)
+		}
+	}
+	return t.String()
+}
+
+// unsignedType returns the C type of the given LLVM IR type, where integer
+// types are unsigned.
+func unsignedType(t types.Type) string {
+	if t, ok := t.(*types.IntType); ok && t.BitSize != 1 {
+		return fmt.Sprintf("uint%d_t", t.BitSize)
+	}
+	return cType(t)
+}
+
+// decl returns the C declaration of the given name of the specified type (e.g.
+// "int32_t x" or "int8_t *p").
+func decl(t types.Type, name string) string {
+	typ := cType(t)
+	if strings.HasSuffix(typ, "*") {
+		return typ + name
+	}
+	return typ + " " + name
+}
@@ -0,0 +1,35 @@
+// Package cifuentes implements the interval-based control flow structuring
+// algorithms of C. Cifuentes [1].
+//
+// Structuring annotates the nodes of a control flow graph with the high-level
+// control flow primitives they belong to (e.g. loops), by setting the loop,
+// 2-way conditional and n-way conditional fields of cfg.Node. The abstract
+// syntax tree of the annotated control flow graph is produced by Structure.
+//
+// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+package cifuentes
+
+import (
+	"github.com/mewmew/pi/cfg"
+	"gonum.org/v1/gonum/graph"
+)
+
+// preds returns the predecessors of n in g, sorted by reverse post-order.
+func preds(g *cfg.Graph, n *cfg.Node) []*cfg.Node {
+	return cfg.SortByRevPost(graph.NodesOf(g.To(n.ID())))
+}
+
+// succs returns the successors of n in g, sorted by reverse post-order.
+func succs(g *cfg.Graph, n *cfg.Node) []*cfg.Node {
+	return cfg.SortByRevPost(graph.NodesOf(g.From(n.ID())))
+}
+
+// contains reports whether the given list of nodes contains n.
+func contains(ns []*cfg.Node, n *cfg.Node) bool {
+	for _, nn := range ns {
+		if nn == n {
+			return true
+		}
+	}
+	return false
+}
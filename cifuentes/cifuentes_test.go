@@ -0,0 +1,395 @@
+package cifuentes
+
+import (
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestLoopStruct(t *testing.T) {
+	golden := []struct {
+		path string
+		// Loops of the control flow graph, with header node as key.
+		loops map[string]loop
+		// Loop header of each node part of a loop.
+		heads map[string]string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			loops: map[string]loop{
+				"B6":  {typ: cfg.LoopTypePreTest, latch: "B15", follow: "B7"},
+				"B13": {typ: cfg.LoopTypePostTest, latch: "B14", follow: "B15"},
+			},
+			heads: map[string]string{
+				"B6":  "B6",
+				"B12": "B6",
+				"B13": "B13",
+				"B14": "B13",
+				"B15": "B6",
+			},
+		},
+		{
+			path: "testdata/endless.dot",
+			loops: map[string]loop{
+				"B": {typ: cfg.LoopTypeEndless, latch: "E", follow: "D"},
+			},
+			heads: map[string]string{
+				"B": "B",
+				"C": "B",
+				"E": "B",
+			},
+		},
+		{
+			// Node E is unreachable from the entry node.
+			path: "testdata/unreachable.dot",
+			loops: map[string]loop{
+				"B": {typ: cfg.LoopTypePostTest, latch: "C", follow: "D"},
+			},
+			heads: map[string]string{
+				"B": "B",
+				"C": "B",
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Structure loops twice, as the annotations of prior runs are reset.
+		if err := LoopStruct(in); err != nil {
+			t.Errorf("%q; unable to structure loops; %v", gold.path, err)
+			continue
+		}
+		if err := LoopStruct(in); err != nil {
+			t.Errorf("%q; unable to restructure loops; %v", gold.path, err)
+			continue
+		}
+		// Check results.
+		loops := make(map[string]loop)
+		heads := make(map[string]string)
+		for _, n := range cfg.SortByRevPost(graph.NodesOf(in.Nodes())) {
+			if n.LoopHead != nil {
+				heads[n.DOTID()] = n.LoopHead.DOTID()
+			}
+			if n.LoopType == cfg.LoopTypeNone {
+				continue
+			}
+			l := loop{typ: n.LoopType, latch: n.Latch.DOTID()}
+			if n.LoopFollow != nil {
+				l.follow = n.LoopFollow.DOTID()
+			}
+			if !n.Latch.IsLatch {
+				t.Errorf("%q; latching node %q of loop %q not marked as latch", gold.path, n.Latch.DOTID(), n.DOTID())
+			}
+			if n.NBackEdges == 0 {
+				t.Errorf("%q; missing back edge to loop header %q", gold.path, n.DOTID())
+			}
+			loops[n.DOTID()] = l
+		}
+		if !reflect.DeepEqual(loops, gold.loops) {
+			t.Errorf("%q; loops mismatch; expected `%v`, got `%v`", gold.path, gold.loops, loops)
+		}
+		if !reflect.DeepEqual(heads, gold.heads) {
+			t.Errorf("%q; loop headers mismatch; expected `%v`, got `%v`", gold.path, gold.heads, heads)
+		}
+	}
+}
+
+// loop is a loop of a control flow graph.
+type loop struct {
+	// Loop type.
+	typ cfg.LoopType
+	// Latching node.
+	latch string
+	// Follow node; empty if not present.
+	follow string
+}
+
+func TestTwoWayStruct(t *testing.T) {
+	golden := []struct {
+		path string
+		// Follow node of each 2-way conditional header.
+		follows map[string]string
+		// Golden output of the annotated control flow graph; empty if not
+		// present.
+		wantPath string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path: "testdata/sample.dot",
+			follows: map[string]string{
+				"B1": "B5",
+				"B2": "B5",
+				"B7": "B10",
+				"B8": "B10",
+			},
+			wantPath: "testdata/sample.dot.golden",
+		},
+		{
+			path: "testdata/endless.dot",
+			// The conditional exit of the endless loop has no follow node.
+			follows: map[string]string{},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Structure loops and 2-way conditionals.
+		if err := LoopStruct(in); err != nil {
+			t.Errorf("%q; unable to structure loops; %v", gold.path, err)
+			continue
+		}
+		TwoWayStruct(in)
+		// Check results.
+		follows := make(map[string]string)
+		for _, n := range cfg.SortByRevPost(graph.NodesOf(in.Nodes())) {
+			if n.IfFollow != nil {
+				follows[n.DOTID()] = n.IfFollow.DOTID()
+			}
+		}
+		if !reflect.DeepEqual(follows, gold.follows) {
+			t.Errorf("%q; follow nodes mismatch; expected `%v`, got `%v`", gold.path, gold.follows, follows)
+		}
+		if len(gold.wantPath) == 0 {
+			continue
+		}
+		buf, err := ioutil.ReadFile(gold.wantPath)
+		if err != nil {
+			t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+			continue
+		}
+		want := strings.TrimSpace(string(buf))
+		if got := in.String(); got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+		}
+	}
+}
+
+func TestNWayStruct(t *testing.T) {
+	golden := []struct {
+		path string
+		// Structured n-way conditionals.
+		want []sw
+		// Switch header of each node part of an n-way conditional.
+		heads map[string]string
+	}{
+		{
+			path: "testdata/switch.dot",
+			want: []sw{
+				{
+					head:   "A",
+					follow: "E",
+					cases: []swCase{
+						{target: "B", values: []string{"1"}, fallsThrough: true},
+						{target: "C", values: []string{"2", "3"}},
+						{target: "D", isDefault: true},
+					},
+				},
+			},
+			heads: map[string]string{
+				"A": "A",
+				"B": "A",
+				"C": "A",
+				"D": "A",
+			},
+		},
+		{
+			// Graph with 2-way and n-way conditionals.
+			path: "../cfg/testdata/kinds.dot",
+			want: []sw{
+				{
+					head:   "C",
+					follow: "",
+					cases: []swCase{
+						{target: "D", values: []string{"1", "2"}},
+						{target: "E", isDefault: true},
+					},
+				},
+			},
+			heads: map[string]string{
+				"C": "C",
+				"E": "C",
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Structure loops and n-way conditionals.
+		if err := LoopStruct(in); err != nil {
+			t.Errorf("%q; unable to structure loops; %v", gold.path, err)
+			continue
+		}
+		switches := NWayStruct(in)
+		// Check results.
+		var got []sw
+		for _, s := range switches {
+			g := sw{head: s.Head.DOTID()}
+			if s.Follow != nil {
+				g.follow = s.Follow.DOTID()
+			}
+			if s.Head.SwitchFollow != s.Follow {
+				t.Errorf("%q; switch follow mismatch of %q; expected %v, got %v", gold.path, s.Head.DOTID(), s.Follow, s.Head.SwitchFollow)
+			}
+			for _, c := range s.Cases {
+				g.cases = append(g.cases, swCase{target: c.Target.DOTID(), values: c.Values, isDefault: c.Default, fallsThrough: c.Fallthrough})
+			}
+			got = append(got, g)
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; switches mismatch; expected `%+v`, got `%+v`", gold.path, gold.want, got)
+		}
+		heads := make(map[string]string)
+		for _, n := range cfg.SortByRevPost(graph.NodesOf(in.Nodes())) {
+			if n.SwitchHead != nil {
+				heads[n.DOTID()] = n.SwitchHead.DOTID()
+			}
+		}
+		if !reflect.DeepEqual(heads, gold.heads) {
+			t.Errorf("%q; switch headers mismatch; expected `%v`, got `%v`", gold.path, gold.heads, heads)
+		}
+	}
+}
+
+// sw is a structured n-way conditional.
+type sw struct {
+	// Header node.
+	head string
+	// Follow node; empty if not present.
+	follow string
+	// Cases.
+	cases []swCase
+}
+
+// swCase is a case of a structured n-way conditional.
+type swCase struct {
+	// Target node.
+	target string
+	// Case values.
+	values []string
+	// Default case.
+	isDefault bool
+	// Case falls through into the next case.
+	fallsThrough bool
+}
+
+func TestCompCond(t *testing.T) {
+	golden := []struct {
+		path     string
+		wantPath string
+	}{
+		{
+			// Sample taken from Fig. 2 in C. Cifuentes' Structuring decompiled
+			// graphs [1].
+			//
+			// [1]: https://pdfs.semanticscholar.org/48bf/d31773af7b67f9d1b003b8b8ac889f08271f.pdf
+			path:     "testdata/sample.dot",
+			wantPath: "testdata/sample.dot.compcond.golden",
+		},
+		{
+			path:     "testdata/or.dot",
+			wantPath: "testdata/or.dot.compcond.golden",
+		},
+		{
+			// Nodes with side effects are not merged into compound conditions.
+			path:     "testdata/effects.dot",
+			wantPath: "testdata/effects.dot.compcond.golden",
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Parse golden output.
+		buf, err := ioutil.ReadFile(gold.wantPath)
+		if err != nil {
+			t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+			continue
+		}
+		want := strings.TrimSpace(string(buf))
+		// Recover compound conditions.
+		out, err := CompCond(in)
+		if err != nil {
+			t.Errorf("%q; unable to recover compound conditions; %v", gold.path, err)
+			continue
+		}
+		if got := out.String(); got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+		}
+		// Verify that the source graph is left unmodified.
+		for _, n := range cfg.SortByRevPost(graph.NodesOf(in.Nodes())) {
+			if n.Pre != 0 || n.RevPost != 0 {
+				t.Errorf("%q; depth first search order of source node %q modified", gold.path, n.DOTID())
+			}
+		}
+	}
+}
+
+func TestStructure(t *testing.T) {
+	golden := []struct {
+		path     string
+		wantPath string
+	}{
+		{path: "testdata/sample.dot", wantPath: "testdata/sample.dot.structure.golden"},
+		{path: "testdata/endless.dot", wantPath: "testdata/endless.dot.structure.golden"},
+		{path: "testdata/continue.dot", wantPath: "testdata/continue.dot.structure.golden"},
+		{path: "testdata/switch.dot", wantPath: "testdata/switch.dot.structure.golden"},
+		{path: "testdata/irreducible.dot", wantPath: "testdata/irreducible.dot.structure.golden"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Parse golden output.
+		buf, err := ioutil.ReadFile(gold.wantPath)
+		if err != nil {
+			t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+			continue
+		}
+		want := strings.TrimSpace(string(buf))
+		// Structure.
+		n, err := Structure(in)
+		if err != nil {
+			t.Errorf("%q; unable to structure control flow graph; %v", gold.path, err)
+			continue
+		}
+		if got := ast.Format(n); got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+		}
+		// Verify that the source graph is left unmodified.
+		for _, n := range cfg.SortByRevPost(graph.NodesOf(in.Nodes())) {
+			if n.LoopType != cfg.LoopTypeNone || n.IfFollow != nil || n.SwitchHead != nil {
+				t.Errorf("%q; structuring annotations of source node %q modified", gold.path, n.DOTID())
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package cifuentes
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// CompCond returns a new control flow graph where the short-circuit evaluation
+// of compound conditions has been recovered, using the compound condition
+// structuring algorithm of C. Cifuentes' Structuring decompiled graphs.
+//
+// A 2-way node x with a 2-way successor y, for which x is the only predecessor,
+// is merged with y if the two nodes share a branch target and y holds only the
+// computation of its branching condition (see cfg.Node.Guard), as statements of
+// y would be lost in the merged node; producing one of the following compound
+// conditions, where t and e are the true and false targets of x respectively.
+//
+//    x && y     y = t, false target of y = e
+//    x && !y    y = t, true target of y = e
+//    x || y     y = e, true target of y = t
+//    x || !y    y = e, false target of y = t
+//
+// The merged node keeps the name of x and whether it is a guard node, and its
+// true and false edges are labelled with the compound condition and its
+// negation respectively, in normalized form. The process is repeated until no more compound conditions
+// are located; thus compound conditions of arbitrary length are recovered.
+//
+// The true and false edges of 2-way nodes are identified by their edge kinds if
+// present, and otherwise by their labels (the false edge being labelled with
+// the negated condition, e.g. "!%cond"). The nodes and edges of the given graph
+// are left unmodified.
+func CompCond(src *cfg.Graph) (*cfg.Graph, error) {
+	g := cfg.NewGraph()
+	if err := cfg.Copy(g, src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for {
+		cfg.InitDFSOrder(g)
+		c, ok := findCompCond(g)
+		if !ok {
+			return g, nil
+		}
+		delNodes := map[string]bool{
+			c.x.DOTID(): true,
+			c.y.DOTID(): true,
+		}
+		merged, err := cfg.Merge(g, delNodes, c.x.DOTID())
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		n, _ := merged.NodeWithName(c.x.DOTID())
+		if c.x.Guard() {
+			n.Attrs["guard"] = "true"
+		}
+		t, _ := merged.NodeWithName(c.t.DOTID())
+		e, _ := merged.NodeWithName(c.e.DOTID())
+		setBranch(merged, n, t, cfg.EdgeKindTrue, c.cond)
+		setBranch(merged, n, e, cfg.EdgeKindFalse, negate(c.cond))
+		g = merged
+	}
+}
+
+// compCond is a compound condition of two 2-way nodes.
+type compCond struct {
+	// 2-way nodes of the compound condition, where x is the only predecessor of
+	// y.
+	x, y *cfg.Node
+	// True and false targets of the compound condition.
+	t, e *cfg.Node
+	// Compound condition.
+	cond cond.Expr
+}
+
+// findCompCond returns the first compound condition of the given control flow
+// graph, in post-order of x. The boolean return value indicates success.
+func findCompCond(g *cfg.Graph) (*compCond, bool) {
+	for _, x := range cfg.SortByPost(graph.NodesOf(g.Nodes())) {
+		xt, xe, xcond, ok := branches(g, x)
+		if !ok {
+			continue
+		}
+		for _, y := range []*cfg.Node{xt, xe} {
+			if y == x || !y.Guard() || g.To(y.ID()).Len() != 1 {
+				continue
+			}
+			yt, ye, ycond, ok := branches(g, y)
+			if !ok || yt == x || ye == x {
+				continue
+			}
+			switch {
+			case y == xt && ye == xe:
+				return &compCond{x: x, y: y, t: yt, e: xe, cond: and(xcond, ycond)}, true
+			case y == xt && yt == xe:
+				return &compCond{x: x, y: y, t: ye, e: xe, cond: and(xcond, negate(ycond))}, true
+			case y == xe && yt == xt:
+				return &compCond{x: x, y: y, t: xt, e: ye, cond: or(xcond, ycond)}, true
+			case y == xe && ye == xt:
+				return &compCond{x: x, y: y, t: xt, e: yt, cond: or(xcond, negate(ycond))}, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// branches returns the true and false targets of the given 2-way node, and its
+// branching condition. The boolean return value indicates success.
+func branches(g *cfg.Graph, n *cfg.Node) (t, e *cfg.Node, c cond.Expr, ok bool) {
+	ss := succs(g, n)
+	if len(ss) != 2 || isNWay(g, n) {
+		return nil, nil, nil, false
+	}
+	a, b := edge(g, n, ss[0]), edge(g, n, ss[1])
+	if a.Kind == cfg.EdgeKindFalse && b.Kind == cfg.EdgeKindTrue {
+		ss[0], ss[1] = ss[1], ss[0]
+		a, b = b, a
+	}
+	acond, err := cond.Parse(label(a))
+	if err != nil {
+		return nil, nil, nil, false
+	}
+	if a.Kind == cfg.EdgeKindTrue && b.Kind == cfg.EdgeKindFalse {
+		return ss[0], ss[1], acond, true
+	}
+	bcond, err := cond.Parse(label(b))
+	if err != nil || negate(acond).String() != cond.Normalize(bcond).String() {
+		return nil, nil, nil, false
+	}
+	// Use the positive condition as branching condition.
+	if _, ok := cond.Normalize(acond).(*cond.Not); ok {
+		return ss[1], ss[0], bcond, true
+	}
+	return ss[0], ss[1], acond, true
+}
+
+// setBranch sets the kind and label of the edge from the given node to the
+// given successor.
+func setBranch(g *cfg.Graph, from, to *cfg.Node, kind cfg.EdgeKind, c cond.Expr) {
+	e := edge(g, from, to)
+	e.Kind = kind
+	e.Values = nil
+	e.Attrs = cfg.Attrs{"label": c.String()}
+	switch kind {
+	case cfg.EdgeKindTrue:
+		e.Attrs["color"] = "darkgreen"
+	case cfg.EdgeKindFalse:
+		e.Attrs["color"] = "red"
+	}
+}
+
+// label returns the unquoted label of the given edge.
+func label(e *cfg.Edge) string {
+	s := e.Attrs["label"]
+	if strings.HasPrefix(s, `"`) {
+		if u, err := strconv.Unquote(s); err == nil {
+			return u
+		}
+	}
+	return s
+}
+
+// and returns the conjunction of the given conditions.
+func and(x, y cond.Expr) cond.Expr {
+	return cond.Normalize(&cond.And{Xs: []cond.Expr{x, y}})
+}
+
+// or returns the disjunction of the given conditions.
+func or(x, y cond.Expr) cond.Expr {
+	return cond.Normalize(&cond.Or{Xs: []cond.Expr{x, y}})
+}
+
+// negate returns the negation of the given condition.
+func negate(x cond.Expr) cond.Expr {
+	return cond.Normalize(cond.Negate(x))
+}
@@ -0,0 +1,170 @@
+package cifuentes
+
+import (
+	"github.com/mewmew/pi/cfg"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// LoopStruct structures the loops of the given control flow graph, using the
+// loop structuring algorithm of C. Cifuentes' Structuring decompiled graphs.
+//
+// Loops are located by traversing the intervals of the derived sequence of
+// graphs G1, ..., Gn; thus inner loops are structured before outer loops. For
+// each interval I(h) containing a latching node x (i.e. a node of the interval
+// with a back edge to the header h, not yet part of another loop), the loop
+// (h, x) is structured as follows.
+//
+//    - the nodes of the loop are marked as belonging to the loop (LoopHead)
+//    - the latching node is marked as such (IsLatch) and recorded (Latch)
+//    - the type of the loop is determined (LoopType)
+//    - the follow node of the loop is determined (LoopFollow)
+//
+// Loop annotations of prior runs are reset. The number of back edges to each
+// node (NBackEdges) and the depth first search order of the nodes are
+// initialized as a side effect (see cfg.InitDFSOrder).
+//
+// Loops with multiple entries are not part of any interval of an irreducible
+// graph, and are thus left unstructured; such graphs may first be transformed
+// into reducible graphs using cfg.MakeReducible.
+func LoopStruct(g *cfg.Graph) error {
+	cfg.InitDFSOrder(g)
+	// Reset loop annotations, and only mark the latching nodes of structured
+	// loops.
+	for _, n := range cfg.SortByRevPost(graph.NodesOf(g.Nodes())) {
+		n.IsLatch = false
+		n.LoopType = cfg.LoopTypeNone
+		n.LoopHead = nil
+		n.Latch = nil
+		n.LoopFollow = nil
+	}
+	d, err := cfg.DerivedSequence(g)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, is := range d.Intervals {
+		for _, in := range is {
+			// Original nodes of the interval, with the header node first.
+			var nodes []*cfg.Node
+			for _, n := range in.Nodes {
+				nodes = append(nodes, d.Orig(n)...)
+			}
+			head := nodes[0]
+			latch := findLatch(g, head, nodes)
+			if latch == nil {
+				continue
+			}
+			loop := loopNodes(head, latch, nodes)
+			for _, n := range loop {
+				if n.LoopHead == nil {
+					n.LoopHead = head
+				}
+			}
+			latch.IsLatch = true
+			head.Latch = latch
+			head.LoopType = findLoopType(g, head, latch, loop)
+			head.LoopFollow = findLoopFollow(g, head, latch, loop)
+		}
+	}
+	return nil
+}
+
+// findLatch returns the latching node of the loop with the given header node
+// within the given interval nodes, or nil if no such loop exists. The latching
+// node is the last node in reverse post-order with a back edge to the header
+// node, which does not already belong to a loop.
+func findLatch(g *cfg.Graph, head *cfg.Node, nodes []*cfg.Node) *cfg.Node {
+	var latch *cfg.Node
+	for _, pred := range preds(g, head) {
+		if pred.LoopHead != nil || !contains(nodes, pred) {
+			continue
+		}
+		if pred.RevPost < head.RevPost {
+			// Forward edge.
+			continue
+		}
+		if latch == nil || pred.RevPost > latch.RevPost {
+			latch = pred
+		}
+	}
+	return latch
+}
+
+// loopNodes returns the nodes of the loop (head, latch); i.e. the interval
+// nodes placed between the header node and the latching node in reverse
+// post-order.
+func loopNodes(head, latch *cfg.Node, nodes []*cfg.Node) []*cfg.Node {
+	var loop []*cfg.Node
+	for _, n := range nodes {
+		if head.RevPost <= n.RevPost && n.RevPost <= latch.RevPost {
+			loop = append(loop, n)
+		}
+	}
+	return cfg.SortByRevPost(graphNodesOf(loop))
+}
+
+// findLoopType returns the type of the loop (head, latch) with the given loop
+// nodes.
+func findLoopType(g *cfg.Graph, head, latch *cfg.Node, loop []*cfg.Node) cfg.LoopType {
+	headSuccs := succs(g, head)
+	if len(succs(g, latch)) == 2 {
+		if head == latch || len(headSuccs) != 2 {
+			return cfg.LoopTypePostTest
+		}
+		if contains(loop, headSuccs[0]) && contains(loop, headSuccs[1]) {
+			return cfg.LoopTypePostTest
+		}
+		return cfg.LoopTypePreTest
+	}
+	if len(headSuccs) == 2 {
+		return cfg.LoopTypePreTest
+	}
+	return cfg.LoopTypeEndless
+}
+
+// findLoopFollow returns the follow node of the loop (head, latch) with the
+// given loop nodes; i.e. the first node reached after exiting the loop, or nil
+// if the loop is never exited.
+func findLoopFollow(g *cfg.Graph, head, latch *cfg.Node, loop []*cfg.Node) *cfg.Node {
+	switch head.LoopType {
+	case cfg.LoopTypePreTest:
+		return firstOutside(succs(g, head), loop)
+	case cfg.LoopTypePostTest:
+		return firstOutside(succs(g, latch), loop)
+	}
+	// The follow node of endless loops is the closest node in reverse
+	// post-order targeted by a conditional exit of the loop.
+	var follow *cfg.Node
+	for _, n := range loop {
+		ss := succs(g, n)
+		if len(ss) < 2 {
+			continue
+		}
+		if succ := firstOutside(ss, loop); succ != nil {
+			if follow == nil || succ.RevPost < follow.RevPost {
+				follow = succ
+			}
+		}
+	}
+	return follow
+}
+
+// firstOutside returns the first of the given nodes not part of the loop, or
+// nil if every node is part of the loop.
+func firstOutside(ns, loop []*cfg.Node) *cfg.Node {
+	for _, n := range ns {
+		if !contains(loop, n) {
+			return n
+		}
+	}
+	return nil
+}
+
+// graphNodesOf returns the given nodes as a slice of graph nodes.
+func graphNodesOf(ns []*cfg.Node) []graph.Node {
+	nodes := make([]graph.Node, len(ns))
+	for i, n := range ns {
+		nodes[i] = n
+	}
+	return nodes
+}
@@ -0,0 +1,203 @@
+package cifuentes
+
+import (
+	"fmt"
+
+	"github.com/mewmew/pi/cfg"
+	"gonum.org/v1/gonum/graph"
+)
+
+// Switch is a structured n-way conditional.
+type Switch struct {
+	// Header node of the n-way conditional.
+	Head *cfg.Node
+	// Follow node of the n-way conditional; or nil if not present.
+	Follow *cfg.Node
+	// Cases of the n-way conditional, sorted by reverse post-order of their
+	// target nodes; with the default case last unless it takes part in a
+	// fallthrough.
+	Cases []*Case
+}
+
+// Case is a case of a structured n-way conditional.
+type Case struct {
+	// Target node of the case.
+	Target *cfg.Node
+	// Case values branching to the target node; or nil if not present (e.g.
+	// the default case).
+	Values []string
+	// Default specifies whether the target node is the target of the default
+	// case.
+	Default bool
+	// Fallthrough specifies whether the case falls through into the next case.
+	Fallthrough bool
+}
+
+// NWayStruct structures the n-way conditionals of the given control flow graph,
+// using the n-way conditional structuring algorithm of C. Cifuentes'
+// Structuring decompiled graphs, and returns the structured n-way conditionals
+// in post-order of their header nodes.
+//
+// The follow node of an n-way conditional header m is the node immediately
+// dominated by m with the largest number of forward in-edges (the last such
+// node in reverse post-order on ties). The follow node is recorded in the
+// SwitchFollow field of the header node, and the nodes of the n-way conditional
+// are tagged with the header node in their SwitchHead field. As for 2-way
+// conditionals, nested n-way conditionals without follow node are kept on a
+// stack of unresolved conditionals.
+//
+// A node is considered an n-way conditional header if it has more than two
+// successors, or if its out-edges are switch case edges. LoopStruct must be
+// invoked before NWayStruct.
+func NWayStruct(g *cfg.Graph) []*Switch {
+	dt := cfg.NewDomTree(g)
+	var switches []*Switch
+	var unresolved []*Switch
+	for _, m := range cfg.SortByPost(graph.NodesOf(g.Nodes())) {
+		if !isNWay(g, m) {
+			continue
+		}
+		sw := &Switch{Head: m, Cases: findCases(g, m)}
+		switches = append(switches, sw)
+		follow := findSwitchFollow(g, dt, m)
+		if follow == nil {
+			unresolved = append(unresolved, sw)
+			continue
+		}
+		resolve := []*Switch{sw}
+		for len(unresolved) > 0 {
+			resolve = append(resolve, unresolved[len(unresolved)-1])
+			unresolved = unresolved[:len(unresolved)-1]
+		}
+		for _, sw := range resolve {
+			sw.Follow = follow
+			sw.Head.SwitchFollow = follow
+			tagCases(g, dt, sw)
+		}
+	}
+	// Tag the nodes of n-way conditionals without follow node.
+	for _, sw := range unresolved {
+		tagCases(g, dt, sw)
+	}
+	return switches
+}
+
+// isNWay reports whether the given node is an n-way conditional header.
+func isNWay(g *cfg.Graph, n *cfg.Node) bool {
+	ss := succs(g, n)
+	if len(ss) > 2 {
+		return true
+	}
+	for _, succ := range ss {
+		if kind := edge(g, n, succ).Kind; kind == cfg.EdgeKindCase || kind == cfg.EdgeKindDefault {
+			return true
+		}
+	}
+	return false
+}
+
+// findSwitchFollow returns the follow node of the n-way conditional with header
+// m; i.e. the node immediately dominated by m with the largest number of
+// forward in-edges (at least two), or nil if no such node exists.
+func findSwitchFollow(g *cfg.Graph, dt *cfg.DomTree, m *cfg.Node) *cfg.Node {
+	var follow *cfg.Node
+	max := 1
+	for _, n := range cfg.SortByRevPost(graphNodesOf(dt.Children(m))) {
+		if nin := g.To(n.ID()).Len() - n.NBackEdges; nin >= max {
+			follow = n
+			max = nin
+		}
+	}
+	if max < 2 {
+		return nil
+	}
+	return follow
+}
+
+// findCases returns the cases of the n-way conditional with header m.
+func findCases(g *cfg.Graph, m *cfg.Node) []*Case {
+	var cases []*Case
+	for _, succ := range succs(g, m) {
+		e := edge(g, m, succ)
+		c := &Case{
+			Target:  succ,
+			Values:  e.Values,
+			Default: e.Kind == cfg.EdgeKindDefault,
+		}
+		cases = append(cases, c)
+	}
+	return cases
+}
+
+// tagCases tags the nodes of the given n-way conditional with its header node,
+// and records cases falling through into the next case.
+//
+// The cases are ordered by reverse post-order of their target nodes, with the
+// default case placed last unless it takes part in a fallthrough.
+func tagCases(g *cfg.Graph, dt *cfg.DomTree, sw *Switch) {
+	m := sw.Head
+	if m.SwitchHead == nil {
+		m.SwitchHead = m
+	}
+	isTarget := make(map[*cfg.Node]bool)
+	for _, c := range sw.Cases {
+		isTarget[c.Target] = true
+	}
+	// falls maps from case target to the case target it falls through into.
+	falls := make(map[*cfg.Node]*cfg.Node)
+	for _, c := range sw.Cases {
+		// Nodes of the case; i.e. the nodes dominated by the n-way header which
+		// are reachable from the case target without passing through the follow
+		// node or the targets of other cases.
+		visited := make(map[*cfg.Node]bool)
+		stack := []*cfg.Node{c.Target}
+		for len(stack) > 0 {
+			n := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if visited[n] || n == sw.Follow || n == m || !dt.Dominates(m, n) {
+				continue
+			}
+			visited[n] = true
+			if n.SwitchHead == nil {
+				n.SwitchHead = m
+			}
+			for _, succ := range succs(g, n) {
+				if isTarget[succ] && succ != c.Target {
+					falls[c.Target] = succ
+					continue
+				}
+				stack = append(stack, succ)
+			}
+		}
+	}
+	// Place default case last.
+	for i, c := range sw.Cases {
+		if !c.Default || falls[c.Target] != nil {
+			continue
+		}
+		fallenInto := false
+		for _, dst := range falls {
+			if dst == c.Target {
+				fallenInto = true
+			}
+		}
+		if !fallenInto {
+			sw.Cases = append(append(sw.Cases[:i:i], sw.Cases[i+1:]...), c)
+		}
+		break
+	}
+	for i, c := range sw.Cases {
+		if i+1 < len(sw.Cases) && falls[c.Target] == sw.Cases[i+1].Target {
+			c.Fallthrough = true
+		}
+	}
+}
+
+// edge returns the edge from the given node to the given successor.
+func edge(g *cfg.Graph, from, to *cfg.Node) *cfg.Edge {
+	e, ok := g.Edge(from.ID(), to.ID()).(*cfg.Edge)
+	if !ok {
+		panic(fmt.Errorf("invalid edge type; expected *cfg.Edge, got %T", g.Edge(from.ID(), to.ID())))
+	}
+	return e
+}
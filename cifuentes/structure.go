@@ -0,0 +1,341 @@
+package cifuentes
+
+import (
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+)
+
+// Structure returns the abstract syntax tree of the given control flow graph,
+// as structured by the loop, n-way conditional and 2-way conditional
+// structuring algorithms of C. Cifuentes' Structuring decompiled graphs (see
+// LoopStruct, NWayStruct and TwoWayStruct).
+//
+// Code is generated by traversing the control flow graph from the entry node,
+// emitting each structured loop and conditional at its header node, and
+// continuing with its follow node. Control flow not captured by the structured
+// primitives (e.g. the edges of irreducible loops, or cases falling through
+// into the next case) is expressed using goto statements; labels are only
+// emitted for nodes targeted by goto statements.
+//
+// As branching conditions are not propagated into expressions, the header node
+// of pre-test loops is emitted at the start of an endless loop, followed by a
+// conditional break statement (see generator.loop); e.g.
+//
+//    for {
+//       B6
+//       if (%c6) {
+//          break
+//       }
+//       B12
+//    }
+//
+// Nodes unreachable from the entry node are ignored. The nodes and edges of the
+// given graph are left unmodified.
+func Structure(src *cfg.Graph) (ast.Node, error) {
+	g := cfg.NewGraph()
+	if err := cfg.Copy(g, src); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	entry, ok := g.Entry().(*cfg.Node)
+	if !ok {
+		return nil, errors.Errorf("unable to locate entry node of control flow graph %q", g.DOTID())
+	}
+	if err := LoopStruct(g); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	NWayStruct(g)
+	TwoWayStruct(g)
+	gen := &generator{
+		g:       g,
+		dt:      cfg.NewDomTree(g),
+		visited: make(map[*cfg.Node]bool),
+		labels:  make(map[string]bool),
+	}
+	stmts, err := gen.seq(entry, context{})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Remove labels not targeted by goto statements.
+	n := ast.Rewrite(ast.NewSeq(stmts...), func(n ast.Node) ast.Node {
+		if l, ok := n.(*ast.Label); ok && !gen.labels[l.Name] {
+			return &ast.Seq{}
+		}
+		return n
+	})
+	return ast.Flatten(n), nil
+}
+
+// generator keeps track of the state of code generation.
+type generator struct {
+	// Control flow graph annotated by the structuring algorithms.
+	g *cfg.Graph
+	// Dominator tree of the control flow graph.
+	dt *cfg.DomTree
+	// visited tracks the nodes for which code has been generated.
+	visited map[*cfg.Node]bool
+	// labels tracks the names of nodes targeted by goto statements.
+	labels map[string]bool
+}
+
+// context is the structured context in which code is generated.
+type context struct {
+	// Header node of the innermost enclosing loop; or nil if not present.
+	loop *cfg.Node
+	// Follow node of the innermost enclosing conditional; or nil if not present.
+	follow *cfg.Node
+	// Targets of the other cases of the innermost enclosing n-way conditional.
+	targets map[*cfg.Node]bool
+}
+
+// seq returns the statements of the sequence of nodes starting at n in the
+// given context. The sequence ends at the follow node of the enclosing
+// conditional, at the follow node (break) or header node (continue) of the
+// enclosing loop, or at a node already generated or targeted by another case
+// (goto).
+func (gen *generator) seq(n *cfg.Node, ctx context) ([]ast.Node, error) {
+	var stmts []ast.Node
+	for n != nil {
+		switch {
+		case n == ctx.follow:
+			return stmts, nil
+		case ctx.loop != nil && n == ctx.loop.LoopFollow:
+			return append(stmts, &ast.Break{}), nil
+		case ctx.loop != nil && n == ctx.loop:
+			return append(stmts, &ast.Continue{}), nil
+		case gen.visited[n] || ctx.targets[n]:
+			gen.labels[n.DOTID()] = true
+			return append(stmts, &ast.Goto{Label: n.DOTID()}), nil
+		}
+		gen.visited[n] = true
+		stmts = append(stmts, &ast.Label{Name: n.DOTID()})
+		var ss []ast.Node
+		var err error
+		if n.LoopType != cfg.LoopTypeNone {
+			var loop ast.Node
+			loop, err = gen.loop(n)
+			ss, n = []ast.Node{loop}, n.LoopFollow
+		} else {
+			ss, n, err = gen.node(n, ctx)
+		}
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		stmts = append(stmts, ss...)
+	}
+	return stmts, nil
+}
+
+// node returns the statements of the given node and of the conditional headed
+// by the node in the given context, and the node succeeding them.
+func (gen *generator) node(n *cfg.Node, ctx context) ([]ast.Node, *cfg.Node, error) {
+	stmts := []ast.Node{&ast.Block{Node: n}}
+	ss := succs(gen.g, n)
+	switch {
+	case len(ss) == 0:
+		return stmts, nil, nil
+	case len(ss) == 1:
+		return stmts, ss[0], nil
+	case isNWay(gen.g, n):
+		sw, err := gen.nway(n, ctx)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		return append(stmts, sw), n.SwitchFollow, nil
+	}
+	t, e, c, ok := branches(gen.g, n)
+	if !ok {
+		return nil, nil, errors.Errorf("unable to locate branching condition of 2-way node %q", n.DOTID())
+	}
+	// Both branches end at the follow node of the conditional if present, and
+	// otherwise at the end of the enclosing context. Follow nodes already
+	// generated (e.g. the follow node of an enclosing conditional assigned to
+	// an unresolved conditional) are reached through goto statements.
+	follow := n.IfFollow
+	if gen.visited[follow] {
+		follow = nil
+	}
+	inner := ctx
+	if follow != nil {
+		inner.follow = follow
+	}
+	then, err := gen.seq(t, inner)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	els, err := gen.seq(e, inner)
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	return append(stmts, newIf(c, then, els)...), follow, nil
+}
+
+// loop returns the loop statement of the loop with the given header node.
+//
+// Loops are generated as endless loops, exited through break statements.
+// Post-test loops whose body ends with the latching node followed by a
+// conditional break statement are turned into do-while loops; unless the body
+// contains continue statements of the loop (i.e. other back edges to the header
+// node), as continue statements of do-while loops evaluate the loop condition.
+func (gen *generator) loop(head *cfg.Node) (ast.Node, error) {
+	inner := context{loop: head}
+	stmts, next, err := gen.node(head, inner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ss, err := gen.seq(next, inner)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	stmts = append(stmts, ss...)
+	// Continue statements at the end of the loop body are implicit.
+	if n := len(stmts); n > 0 && isContinue(stmts[n-1:]) {
+		stmts = stmts[:n-1]
+	}
+	if n := len(stmts); head.LoopType == cfg.LoopTypePostTest && n >= 2 {
+		b, ok1 := stmts[n-2].(*ast.Block)
+		exit, ok2 := stmts[n-1].(*ast.If)
+		if ok1 && ok2 && b.Node == head.Latch && !hasContinue(ast.NewSeq(stmts[:n-2]...)) {
+			if _, ok := exit.Then.(*ast.Break); ok {
+				c, err := cond.Parse(exit.Cond)
+				if err != nil {
+					return nil, errors.WithStack(err)
+				}
+				return &ast.DoWhile{Body: ast.NewSeq(stmts[:n-1]...), Cond: negate(c).String()}, nil
+			}
+		}
+	}
+	return &ast.Endless{Body: ast.NewSeq(stmts...)}, nil
+}
+
+// nway returns the switch statement of the n-way conditional with the given
+// header node in the given context.
+func (gen *generator) nway(head *cfg.Node, ctx context) (ast.Node, error) {
+	sw := &Switch{Head: head, Follow: head.SwitchFollow, Cases: findCases(gen.g, head)}
+	tagCases(gen.g, gen.dt, sw)
+	s := &ast.Switch{}
+	for _, c := range sw.Cases {
+		// Cases falling through into other cases branch to their targets.
+		inner := context{loop: ctx.loop, follow: sw.Follow, targets: make(map[*cfg.Node]bool)}
+		if sw.Follow == nil {
+			inner.follow = ctx.follow
+		}
+		for _, other := range sw.Cases {
+			if other.Target != c.Target {
+				inner.targets[other.Target] = true
+			}
+		}
+		stmts, err := gen.seq(c.Target, inner)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if c.Default {
+			s.Default = ast.NewSeq(stmts...)
+			continue
+		}
+		x, values, err := caseValues(gen.g, head, c)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if len(s.Var) > 0 && x != s.Var {
+			return nil, errors.Errorf("invalid n-way conditional %q; mismatch between compared variables %q and %q", head.DOTID(), s.Var, x)
+		}
+		s.Var = x
+		s.Cases = append(s.Cases, &ast.Case{Values: values, Body: ast.NewSeq(stmts...)})
+	}
+	return s, nil
+}
+
+// caseValues returns the compared variable and case values of the given case
+// of an n-way conditional, as specified by the label of its edge (e.g.
+// "x == 2 || x == 3").
+func caseValues(g *cfg.Graph, head *cfg.Node, c *Case) (string, []string, error) {
+	e := edge(g, head, c.Target)
+	x, err := cond.Parse(label(e))
+	if err != nil {
+		return "", nil, errors.Wrapf(err, "unable to parse label of edge (%q -> %q)", head.DOTID(), c.Target.DOTID())
+	}
+	var terms []cond.Expr
+	switch x := cond.Normalize(x).(type) {
+	case *cond.Or:
+		terms = x.Xs
+	default:
+		terms = []cond.Expr{x}
+	}
+	var v string
+	var values []string
+	for _, term := range terms {
+		cmp, ok := term.(*cond.Compare)
+		if !ok || cmp.Op != cond.CmpEq || (len(v) > 0 && cmp.X != v) {
+			return "", nil, errors.Errorf("support for case condition %q of edge (%q -> %q) not yet implemented", x, head.DOTID(), c.Target.DOTID())
+		}
+		v = cmp.X
+		values = append(values, cmp.Y)
+	}
+	if len(c.Values) > 0 {
+		values = c.Values
+	}
+	return v, values, nil
+}
+
+// newIf returns the statements of the conditional of the given branching
+// condition and branches. Branches ending with a jump statement are placed in
+// the conditional, and the other branch after the conditional; where the
+// branch to the next iteration of the loop is preferably placed after the
+// conditional, as continue statements at the end of the loop body are implicit.
+func newIf(c cond.Expr, then, els []ast.Node) []ast.Node {
+	switch {
+	case len(then) == 0 && len(els) == 0:
+		return nil
+	case len(els) == 0:
+		return []ast.Node{&ast.If{Cond: c.String(), Then: ast.NewSeq(then...)}}
+	case len(then) == 0:
+		return []ast.Node{&ast.If{Cond: negate(c).String(), Then: ast.NewSeq(els...)}}
+	case isJump(then) && !isContinue(then):
+		return append([]ast.Node{&ast.If{Cond: c.String(), Then: ast.NewSeq(then...)}}, els...)
+	case isJump(els):
+		return append([]ast.Node{&ast.If{Cond: negate(c).String(), Then: ast.NewSeq(els...)}}, then...)
+	case isJump(then):
+		return append([]ast.Node{&ast.If{Cond: c.String(), Then: ast.NewSeq(then...)}}, els...)
+	}
+	return []ast.Node{&ast.IfElse{Cond: c.String(), Then: ast.NewSeq(then...), Else: ast.NewSeq(els...)}}
+}
+
+// isJump reports whether the given statements end with a jump statement.
+func isJump(stmts []ast.Node) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	switch stmts[len(stmts)-1].(type) {
+	case *ast.Break, *ast.Continue, *ast.Goto, *ast.Return:
+		return true
+	}
+	return false
+}
+
+// hasContinue reports whether the given node contains a continue statement of
+// the enclosing loop; i.e. a continue statement not nested within another loop.
+func hasContinue(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Continue:
+			found = true
+		case *ast.While, *ast.DoWhile, *ast.For, *ast.Endless:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// isContinue reports whether the given statements consist of a single continue
+// statement.
+func isContinue(stmts []ast.Node) bool {
+	if len(stmts) != 1 {
+		return false
+	}
+	_, ok := stmts[0].(*ast.Continue)
+	return ok
+}
@@ -0,0 +1,70 @@
+package cifuentes
+
+import (
+	"github.com/mewmew/pi/cfg"
+	"gonum.org/v1/gonum/graph"
+)
+
+// TwoWayStruct structures the 2-way conditionals of the given control flow
+// graph, using the 2-way conditional structuring algorithm of C. Cifuentes'
+// Structuring decompiled graphs.
+//
+// Nodes are visited in post-order, thus inner conditionals are structured
+// before outer conditionals. The follow node of a 2-way conditional header m is
+// the last node in reverse post-order immediately dominated by m, with at least
+// two forward in-edges. The follow node is recorded in the IfFollow field of the
+// header node.
+//
+// Nested conditionals for which no such follow node exists (e.g. conditionals
+// with branches ending in return statements) are kept on a stack of unresolved
+// conditionals, and are assigned the follow node of the first enclosing
+// conditional with a follow node.
+//
+// The headers of pre-test loops and the latching nodes of loops are not
+// considered 2-way conditionals, as their branches are part of the loop
+// structure; thus LoopStruct must be invoked before TwoWayStruct. Neither are
+// n-way conditional headers with two successors (e.g. a switch with a single
+// case).
+func TwoWayStruct(g *cfg.Graph) {
+	dt := cfg.NewDomTree(g)
+	var unresolved []*cfg.Node
+	for _, m := range cfg.SortByPost(graph.NodesOf(g.Nodes())) {
+		if len(succs(g, m)) != 2 || isLoopCond(m) || isNWay(g, m) {
+			continue
+		}
+		follow := findIfFollow(g, dt, m)
+		if follow == nil {
+			unresolved = append(unresolved, m)
+			continue
+		}
+		m.IfFollow = follow
+		for len(unresolved) > 0 {
+			x := unresolved[len(unresolved)-1]
+			unresolved = unresolved[:len(unresolved)-1]
+			x.IfFollow = follow
+		}
+	}
+}
+
+// findIfFollow returns the follow node of the 2-way conditional with header m;
+// i.e. the last node in reverse post-order immediately dominated by m with at
+// least two forward in-edges, or nil if no such node exists.
+func findIfFollow(g *cfg.Graph, dt *cfg.DomTree, m *cfg.Node) *cfg.Node {
+	var follow *cfg.Node
+	for _, n := range dt.Children(m) {
+		if g.To(n.ID()).Len()-n.NBackEdges < 2 {
+			continue
+		}
+		if follow == nil || n.RevPost > follow.RevPost {
+			follow = n
+		}
+	}
+	return follow
+}
+
+// isLoopCond reports whether the branching condition of the given node belongs
+// to a loop; i.e. whether the node is the header node of a pre-test loop or the
+// latching node of a loop.
+func isLoopCond(n *cfg.Node) bool {
+	return n.IsLatch || n.LoopType == cfg.LoopTypePreTest
+}
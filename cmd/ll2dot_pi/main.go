@@ -17,9 +17,12 @@
 //
 // Flags:
 //
+//    -c    print C pseudo-code of each function to standard output
 //    -f    force overwrite existing graph directories
 //    -funcs string
 //          comma-separated list of functions to parse
+//    -go string
+//          print Go source file of the given package name to standard output
 //    -img
 //          generate an image representation of the control flow graph
 //    -q    suppress non-error messages
@@ -28,6 +31,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	goast "go/ast"
 	"io/ioutil"
 	"log"
 	"os"
@@ -40,6 +44,9 @@ import (
 	"github.com/mewkiz/pkg/pathutil"
 	"github.com/mewkiz/pkg/term"
 	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cgen"
+	"github.com/mewmew/pi/gogen"
+	"github.com/mewmew/pi/structure"
 	"github.com/pkg/errors"
 	"gonum.org/v1/gonum/graph"
 	"gonum.org/v1/gonum/graph/encoding/dot"
@@ -68,18 +75,26 @@ Flags:
 func main() {
 	// Parse command line flags.
 	var (
+		// c specifies whether to print the C pseudo-code of each function to
+		// standard output.
+		c bool
 		// force specifies whether to force overwrite existing graph directories.
 		force bool
 		// funcs represents a comma-separated list of functions to parse.
 		funcs string
+		// goPkg specifies the package name of the Go source file to print to
+		// standard output; or empty if not set.
+		goPkg string
 		// img specifies whether to generate an image representation of the
 		// control flow graph.
 		img bool
 		// quiet specifies whether to suppress non-error messages.
 		quiet bool
 	)
+	flag.BoolVar(&c, "c", false, "print C pseudo-code of each function to standard output")
 	flag.BoolVar(&force, "f", false, "force overwrite existing graph directories")
 	flag.StringVar(&funcs, "funcs", "", "comma-separated list of functions to parse")
+	flag.StringVar(&goPkg, "go", "", "print Go source file of the given package name to standard output")
 	flag.BoolVar(&img, "img", false, "generate an image representation of the control flow graph")
 	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
 	flag.Usage = usage
@@ -101,17 +116,26 @@ func main() {
 		dbg.SetOutput(ioutil.Discard)
 	}
 
-	// Generate control flow graphs from LLVM IR files.
+	// Generate control flow graphs from LLVM IR files. Continue with the
+	// remaining files if a file cannot be processed.
+	failed := false
 	for _, llPath := range flag.Args() {
-		if err := ll2dot(llPath, funcNames, force, img); err != nil {
-			log.Fatalf("%+v", err)
+		if err := ll2dot(llPath, funcNames, force, img, c, goPkg); err != nil {
+			log.Printf("%+v", err)
+			failed = true
 		}
 	}
+	if failed {
+		os.Exit(1)
+	}
 }
 
 // ll2dot parses the provided LLVM IR assembly file and generates a control flow
-// graph for each of its defined functions using one node per basic block.
-func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
+// graph for each of its defined functions using one node per basic block. The
+// C pseudo-code of each function is printed to standard output if c is set, and
+// a Go source file of the functions with the given package name if goPkg is
+// non-empty.
+func ll2dot(llPath string, funcNames map[string]bool, force, img, c bool, goPkg string) error {
 	var module *ir.Module
 	var err error
 	if llPath == "-" {
@@ -141,6 +165,7 @@ func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
 	if err != nil {
 		return errors.WithStack(err)
 	}
+	var decls []goast.Decl
 	for _, f := range funcs {
 		// Skip function declarations.
 		if len(f.Blocks) == 0 {
@@ -149,12 +174,46 @@ func ll2dot(llPath string, funcNames map[string]bool, force, img bool) error {
 
 		// Generate control flow graph.
 		dbg.Printf("parsing function %q.", f.Name())
-		g := cfg.NewGraphFromFunc(f)
+		g, err := cfg.NewGraphFromFunc(f)
+		if err != nil {
+			return errors.WithStack(err)
+		}
 
 		// Store DOT graph.
 		if err := storeCFG(g, f.Name(), dotDir, img); err != nil {
 			return errors.WithStack(err)
 		}
+
+		if !c && len(goPkg) == 0 {
+			continue
+		}
+		n, err := structure.Structure(g)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		// Print C pseudo-code.
+		if c {
+			if err := cgen.Fprint(os.Stdout, f, n); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+
+		// Generate Go source code.
+		if len(goPkg) > 0 {
+			ds, err := gogen.NewDecls(f, n)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			decls = append(decls, ds...)
+		}
+	}
+
+	// Print Go source file.
+	if len(goPkg) > 0 {
+		if err := gogen.Format(os.Stdout, gogen.NewFile(goPkg, decls...)); err != nil {
+			return errors.WithStack(err)
+		}
 	}
 	return nil
 }
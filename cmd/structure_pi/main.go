@@ -0,0 +1,115 @@
+// The structure tool recovers high-level control flow primitives from control
+// flow graphs (*.dot -> pseudo-code).
+//
+// The input of structure is a set of Graphviz DOT files, each representing the
+// control flow graph of a function (as generated by ll2dot), and the output is
+// the structured pseudo-code of each function, printed to standard output.
+//
+// Usage:
+//
+//    structure [OPTION]... FILE.dot...
+//
+// Flags:
+//
+//    -q       suppress non-error messages
+//    -split   make irreducible control flow graphs reducible by node splitting
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/mewkiz/pkg/term"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/structure"
+	"github.com/pkg/errors"
+)
+
+var (
+	// dbg represents a logger with the "structure:" prefix, which logs debug
+	// messages to standard error.
+	dbg = log.New(os.Stderr, term.MagentaBold("structure:")+" ", 0)
+)
+
+func usage() {
+	const use = `
+Recover high-level control flow primitives from control flow graphs (*.dot -> pseudo-code).
+
+Usage:
+
+	structure [OPTION]... FILE.dot...
+
+Flags:
+`
+	fmt.Fprintln(os.Stderr, use[1:])
+	flag.PrintDefaults()
+}
+
+func main() {
+	// Parse command line flags.
+	var (
+		// quiet specifies whether to suppress non-error messages.
+		quiet bool
+		// split specifies whether to make irreducible control flow graphs
+		// reducible by node splitting, rather than by introducing selector
+		// variables.
+		split bool
+	)
+	flag.BoolVar(&quiet, "q", false, "suppress non-error messages")
+	flag.BoolVar(&split, "split", false, "make irreducible control flow graphs reducible by node splitting")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(1)
+	}
+	// Mute debug messages if `-q` is set.
+	if quiet {
+		dbg.SetOutput(ioutil.Discard)
+	}
+
+	// Structure control flow graphs.
+	for _, dotPath := range flag.Args() {
+		if err := structureFile(dotPath, split); err != nil {
+			log.Fatalf("%+v", err)
+		}
+	}
+}
+
+// structureFile parses the provided Graphviz DOT file and prints the structured
+// pseudo-code of its control flow graph. Irreducible control flow graphs are
+// made reducible by node splitting if split is set.
+func structureFile(dotPath string, split bool) error {
+	dbg.Printf("parsing file %q.", dotPath)
+	g, err := cfg.ParseFile(dotPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	// Report irreducible loops.
+	for _, l := range cfg.NewLoopForest(g).Loops() {
+		if !l.Irreducible {
+			continue
+		}
+		var headers []string
+		for _, head := range l.Headers {
+			headers = append(headers, head.DOTID())
+		}
+		dbg.Printf("irreducible loop of %d nodes at depth %d in %q; %d entry edges to headers %s.", len(l.Nodes), l.Depth, dotPath, len(l.Entries), strings.Join(headers, ", "))
+	}
+	if split {
+		if g, err = cfg.MakeReducibleBySplitting(g); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	prim, err := structure.Structure(g)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	fmt.Println(ast.Format(prim))
+	return nil
+}
@@ -0,0 +1,184 @@
+// Package cond provides symbolic Boolean formulas of branching conditions.
+//
+// Conditions are parsed from and printed as the edge labels of control flow
+// graphs, as produced by cfg.NewGraphFromFunc; e.g.
+//
+//    %cond
+//    !%cond
+//    x == 1 || x == 2
+//    x != 1 && x != 2
+package cond
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a Boolean formula.
+//
+// Expr may have one of the following underlying types.
+//
+//    cond.Const
+//    *cond.Var
+//    *cond.Compare
+//    *cond.Not
+//    *cond.And
+//    *cond.Or
+type Expr interface {
+	fmt.Stringer
+	// isExpr ensures that only Boolean formulas can be assigned to the Expr
+	// interface.
+	isExpr()
+}
+
+// === [ Constants ] ===========================================================
+
+// Const is a Boolean constant.
+type Const bool
+
+// Boolean constants.
+const (
+	// True is the condition which always holds.
+	True Const = true
+	// False is the condition which never holds.
+	False Const = false
+)
+
+// String returns the string representation of the Boolean constant.
+func (c Const) String() string {
+	if c {
+		return "true"
+	}
+	return "false"
+}
+
+// === [ Variables ] ===========================================================
+
+// Var is a Boolean variable (e.g. `%cond`).
+type Var struct {
+	// Variable name.
+	Name string
+}
+
+// String returns the string representation of the Boolean variable.
+func (x *Var) String() string {
+	return x.Name
+}
+
+// === [ Comparisons ] =========================================================
+
+// Compare is a comparison of two operands (e.g. `x == 1`).
+type Compare struct {
+	// Comparison operator.
+	Op CmpOp
+	// Operands.
+	X, Y string
+}
+
+// String returns the string representation of the comparison.
+func (x *Compare) String() string {
+	return fmt.Sprintf("%s %s %s", x.X, x.Op, x.Y)
+}
+
+//go:generate stringer -type CmpOp -linecomment
+
+// CmpOp is a comparison operator.
+type CmpOp uint8
+
+// Comparison operators.
+const (
+	CmpEq CmpOp = iota + 1 // ==
+	CmpNe                  // !=
+	CmpLt                  // <
+	CmpLe                  // <=
+	CmpGt                  // >
+	CmpGe                  // >=
+)
+
+// Negate returns the negated comparison operator; e.g. `!=` for `==`.
+func (op CmpOp) Negate() CmpOp {
+	switch op {
+	case CmpEq:
+		return CmpNe
+	case CmpNe:
+		return CmpEq
+	case CmpLt:
+		return CmpGe
+	case CmpLe:
+		return CmpGt
+	case CmpGt:
+		return CmpLe
+	case CmpGe:
+		return CmpLt
+	}
+	panic(fmt.Errorf("support for comparison operator %v not yet implemented", op))
+}
+
+// === [ Operators ] ===========================================================
+
+// Not is the negation of a Boolean formula.
+type Not struct {
+	// Negated operand.
+	X Expr
+}
+
+// String returns the string representation of the negation.
+func (x *Not) String() string {
+	if v, ok := x.X.(*Var); ok && !strings.ContainsAny(v.Name, " ") {
+		return "!" + v.Name
+	}
+	return "!(" + x.X.String() + ")"
+}
+
+// And is the conjunction of Boolean formulas.
+type And struct {
+	// Operands of the conjunction.
+	Xs []Expr
+}
+
+// String returns the string representation of the conjunction.
+func (x *And) String() string {
+	if len(x.Xs) == 0 {
+		return True.String()
+	}
+	var ss []string
+	for _, y := range x.Xs {
+		if _, ok := y.(*Or); ok {
+			ss = append(ss, "("+y.String()+")")
+			continue
+		}
+		ss = append(ss, y.String())
+	}
+	return strings.Join(ss, " && ")
+}
+
+// Or is the disjunction of Boolean formulas.
+type Or struct {
+	// Operands of the disjunction.
+	Xs []Expr
+}
+
+// String returns the string representation of the disjunction.
+func (x *Or) String() string {
+	if len(x.Xs) == 0 {
+		return False.String()
+	}
+	var ss []string
+	for _, y := range x.Xs {
+		if _, ok := y.(*And); ok {
+			ss = append(ss, "("+y.String()+")")
+			continue
+		}
+		ss = append(ss, y.String())
+	}
+	return strings.Join(ss, " || ")
+}
+
+// isExpr ensures that only Boolean formulas can be assigned to the Expr
+// interface.
+func (Const) isExpr()    {}
+func (*Var) isExpr()     {}
+func (*Compare) isExpr() {}
+func (*Not) isExpr()     {}
+func (*And) isExpr()     {}
+func (*Or) isExpr()      {}
@@ -0,0 +1,164 @@
+package cond
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	a := &Var{Name: "%a"}
+	b := &Var{Name: "%b"}
+	c := &Compare{Op: CmpEq, X: "x", Y: "1"}
+	golden := []struct {
+		x    Expr
+		want string
+	}{
+		{x: True, want: "true"},
+		{x: False, want: "false"},
+		{x: a, want: "%a"},
+		{x: &Not{X: a}, want: "!%a"},
+		{x: &Not{X: c}, want: "!(x == 1)"},
+		{x: &And{Xs: []Expr{a, &Not{X: b}}}, want: "%a && !%b"},
+		{x: &Or{Xs: []Expr{a, &And{Xs: []Expr{b, c}}}}, want: "%a || (%b && x == 1)"},
+		{x: &And{Xs: []Expr{&Or{Xs: []Expr{a, b}}, c}}, want: "(%a || %b) && x == 1"},
+		{x: &Not{X: &Or{Xs: []Expr{a, b}}}, want: "!(%a || %b)"},
+		{x: &And{}, want: "true"},
+		{x: &Or{}, want: "false"},
+	}
+	for _, gold := range golden {
+		got := gold.x.String()
+		if got != gold.want {
+			t.Errorf("output mismatch; expected %q, got %q", gold.want, got)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	golden := []struct {
+		in   string
+		want string
+		err  bool
+	}{
+		{in: "%cond", want: "%cond"},
+		{in: "!%cond", want: "!%cond"},
+		{in: "x == 1 || x == 2", want: "x == 1 || x == 2"},
+		{in: "x != 1 && x != 2", want: "x != 1 && x != 2"},
+		{in: "%a || %b && !%c", want: "%a || (%b && !%c)"},
+		{in: "(%a || %b) && %c", want: "(%a || %b) && %c"},
+		{in: "!(%a && %b)", want: "!(%a && %b)"},
+		{in: "x <= y", want: "x <= y"},
+		{in: "true", want: "true"},
+		{in: "", err: true},
+		{in: "%a &&", err: true},
+		{in: "(%a", err: true},
+	}
+	for _, gold := range golden {
+		x, err := Parse(gold.in)
+		if gold.err {
+			if err == nil {
+				t.Errorf("%q: expected error, got %v", gold.in, x)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unable to parse condition; %v", gold.in, err)
+			continue
+		}
+		got := x.String()
+		if got != gold.want {
+			t.Errorf("%q: output mismatch; expected %q, got %q", gold.in, gold.want, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	golden := []struct {
+		in   string
+		neg  bool
+		want string
+	}{
+		{in: "!!%a", want: "%a"},
+		{in: "%a && (%b && %c)", want: "%a && %b && %c"},
+		{in: "%a || (%b || %c)", want: "%a || %b || %c"},
+		{in: "%a && true", want: "%a"},
+		{in: "%a && false", want: "false"},
+		{in: "%a || true", want: "true"},
+		{in: "%a && %a", want: "%a"},
+		{in: "%a && !%a", want: "false"},
+		{in: "%a || !%a", want: "true"},
+		{in: "!(%a && %b)", want: "!%a || !%b"},
+		{in: "!(x == 1 || x == 2)", want: "x != 1 && x != 2"},
+		{in: "%a && !%b", neg: true, want: "!%a || %b"},
+		{in: "x < 1 || %c", neg: true, want: "x >= 1 && !%c"},
+		{in: "x > y", neg: true, want: "x <= y"},
+	}
+	for _, gold := range golden {
+		x, err := Parse(gold.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse condition; %v", gold.in, err)
+			continue
+		}
+		if gold.neg {
+			x = Negate(x)
+		}
+		got := Normalize(x).String()
+		if got != gold.want {
+			t.Errorf("%q: output mismatch; expected %q, got %q", gold.in, gold.want, got)
+		}
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	golden := []struct {
+		in   string
+		want string
+	}{
+		{in: "%a || (!%a && %b)", want: "%a || %b"},
+		{in: "(%a && %b) || (%a && !%b)", want: "%a"},
+		{in: "(%a && %b) || (!%a && %c) || (%b && %c)", want: "(%a && %b) || (!%a && %c)"},
+		{in: "(%a || %b) && (%a || !%b)", want: "%a"},
+		{in: "%a && !%a", want: "false"},
+		{in: "%a || !%a", want: "true"},
+		{in: "x == 1 || (x != 1 && x < 2)", want: "x == 1 || x < 2"},
+		{in: "(x >= 1 && %c) || (x < 1 && %c)", want: "%c"},
+		{in: "!(%a && %b) && %a", want: "%a && !%b"},
+	}
+	for _, gold := range golden {
+		x, err := Parse(gold.in)
+		if err != nil {
+			t.Errorf("%q: unable to parse condition; %v", gold.in, err)
+			continue
+		}
+		got := Minimize(x).String()
+		if got != gold.want {
+			t.Errorf("%q: output mismatch; expected %q, got %q", gold.in, gold.want, got)
+		}
+	}
+}
+
+func TestMinimizeWide(t *testing.T) {
+	// Disjunction of MaxMinimizeVars variables, with a redundant conjunction.
+	var vars []string
+	for i := 0; i < MaxMinimizeVars; i++ {
+		vars = append(vars, fmt.Sprintf("%%v%d", i))
+	}
+	want := strings.Join(vars, " || ")
+	in := fmt.Sprintf("(%s && %s) || %s", vars[0], vars[1], want)
+	x, err := Parse(in)
+	if err != nil {
+		t.Fatalf("%q: unable to parse condition; %v", in, err)
+	}
+	if got := Minimize(x).String(); got != want {
+		t.Errorf("%q: output mismatch; expected %q, got %q", in, want, got)
+	}
+	// Formulas with more than MaxMinimizeVars atomic conditions are normalized.
+	in += " || %w"
+	x, err = Parse(in)
+	if err != nil {
+		t.Fatalf("%q: unable to parse condition; %v", in, err)
+	}
+	if got, want := Minimize(x).String(), Normalize(x).String(); got != want {
+		t.Errorf("%q: output mismatch; expected %q, got %q", in, want, got)
+	}
+}
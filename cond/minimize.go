@@ -0,0 +1,333 @@
+package cond
+
+import (
+	"fmt"
+	"math/bits"
+	"sort"
+)
+
+// MaxMinimizeVars is the maximum number of atomic conditions of formulas
+// minimized by Minimize. Formulas with more atomic conditions are normalized
+// but not minimized, as the number of minterms grows exponentially with the
+// number of atomic conditions.
+const MaxMinimizeVars = 10
+
+// Minimize returns a minimal equivalent of the given condition in disjunctive
+// normal form (e.g. `%a || %b` for `%a || (!%a && %b)`), using the
+// Quine-McCluskey algorithm.
+//
+// The atomic conditions of the formula are its Boolean variables and
+// comparisons, where the comparison operators `!=`, `>=` and `>` are treated as
+// the negation of `==`, `<` and `<=` respectively. Distinct atomic conditions
+// are assumed to be independent; e.g. `x == 1 && x == 2` is not considered a
+// contradiction.
+//
+// The prime implicants of the formula are located by repeatedly merging
+// implicants which differ in a single atomic condition; only implicants whose
+// number of true atomic conditions differ by one are compared. Essential prime
+// implicants are selected first, and the remaining minterms are covered
+// greedily by the prime implicants covering the most minterms, preferring prime
+// implicants with fewer literals.
+//
+// Conditions with more than MaxMinimizeVars atomic conditions are returned in
+// normalized form.
+func Minimize(x Expr) Expr {
+	x = Normalize(x)
+	atoms := newAtomSet()
+	atoms.collect(x)
+	n := len(atoms.list)
+	if n > MaxMinimizeVars {
+		return x
+	}
+	// Enumerate minterms.
+	var minterms []uint32
+	for m := uint32(0); m < 1<<uint(n); m++ {
+		if atoms.eval(x, m) {
+			minterms = append(minterms, m)
+		}
+	}
+	switch {
+	case len(minterms) == 0:
+		return False
+	case len(minterms) == 1<<uint(n):
+		return True
+	}
+	primes := primeImplicants(minterms)
+	cover := selectCover(primes, minterms)
+	return atoms.expr(cover, n)
+}
+
+// === [ Atomic conditions ] ===================================================
+
+// atom is an atomic condition; a Boolean variable or a comparison using the
+// comparison operator `==`, `<` or `<=`.
+type atom struct {
+	// Key of the atomic condition.
+	key string
+	// Atomic condition.
+	x Expr
+}
+
+// atomSet is an ordered set of atomic conditions.
+type atomSet struct {
+	// Atomic conditions in order of occurrence.
+	list []atom
+	// index maps from atom key to index in list.
+	index map[string]int
+}
+
+// newAtomSet returns a new empty set of atomic conditions.
+func newAtomSet() *atomSet {
+	return &atomSet{index: make(map[string]int)}
+}
+
+// atomOf returns the atomic condition of the given Boolean variable or
+// comparison, and reports whether the atomic condition is negated.
+func atomOf(x Expr) (atom, bool) {
+	y, neg := Atom(x)
+	return atom{key: y.String(), x: y}, neg
+}
+
+// collect adds the atomic conditions of the given condition to the set.
+func (set *atomSet) collect(x Expr) {
+	switch x := x.(type) {
+	case Const:
+		// nothing to do.
+	case *Var, *Compare:
+		a, _ := atomOf(x)
+		if _, ok := set.index[a.key]; !ok {
+			set.index[a.key] = len(set.list)
+			set.list = append(set.list, a)
+		}
+	case *Not:
+		set.collect(x.X)
+	case *And:
+		for _, y := range x.Xs {
+			set.collect(y)
+		}
+	case *Or:
+		for _, y := range x.Xs {
+			set.collect(y)
+		}
+	default:
+		panic(fmt.Errorf("support for condition %T not yet implemented", x))
+	}
+}
+
+// eval evaluates the given condition, where bit i of m specifies the value of
+// the i:th atomic condition of the set.
+func (set *atomSet) eval(x Expr, m uint32) bool {
+	switch x := x.(type) {
+	case Const:
+		return bool(x)
+	case *Var, *Compare:
+		a, neg := atomOf(x)
+		return (m&(1<<uint(set.index[a.key])) != 0) != neg
+	case *Not:
+		return !set.eval(x.X, m)
+	case *And:
+		for _, y := range x.Xs {
+			if !set.eval(y, m) {
+				return false
+			}
+		}
+		return true
+	case *Or:
+		for _, y := range x.Xs {
+			if set.eval(y, m) {
+				return true
+			}
+		}
+		return false
+	}
+	panic(fmt.Errorf("support for condition %T not yet implemented", x))
+}
+
+// expr returns the disjunction of the given implicants over n atomic
+// conditions.
+//
+// The implicants are ordered by the atomic conditions of their literals, in
+// order of occurrence; with positive literals before negative literals.
+func (set *atomSet) expr(imps []implicant, n int) Expr {
+	sort.Slice(imps, func(i, j int) bool {
+		a, b := imps[i], imps[j]
+		for k := 0; k < n; k++ {
+			bit := uint32(1) << uint(k)
+			if a.mask&bit != b.mask&bit {
+				return a.mask&bit == 0
+			}
+			if a.mask&bit == 0 && a.value&bit != b.value&bit {
+				return a.value&bit != 0
+			}
+		}
+		return false
+	})
+	if len(imps) == 1 {
+		return set.term(imps[0], n)
+	}
+	or := &Or{}
+	for _, imp := range imps {
+		or.Xs = append(or.Xs, set.term(imp, n))
+	}
+	return or
+}
+
+// term returns the conjunction of the literals of the given implicant over n
+// atomic conditions.
+func (set *atomSet) term(imp implicant, n int) Expr {
+	var xs []Expr
+	for i := 0; i < n; i++ {
+		bit := uint32(1) << uint(i)
+		if imp.mask&bit != 0 {
+			continue
+		}
+		x := set.list[i].x
+		if imp.value&bit == 0 {
+			x = Negate(x)
+		}
+		xs = append(xs, x)
+	}
+	switch len(xs) {
+	case 0:
+		return True
+	case 1:
+		return xs[0]
+	}
+	return &And{Xs: xs}
+}
+
+// === [ Quine-McCluskey ] =====================================================
+
+// implicant is a product term of atomic conditions.
+type implicant struct {
+	// Values of the atomic conditions present in the term.
+	value uint32
+	// Atomic conditions absent from the term (i.e. "don't care").
+	mask uint32
+}
+
+// covers reports whether the implicant covers the given minterm.
+func (imp implicant) covers(m uint32) bool {
+	return m&^imp.mask == imp.value
+}
+
+// group identifies a group of implicants with the same atomic conditions
+// absent and the same number of true atomic conditions.
+type group struct {
+	// Atomic conditions absent from the implicants.
+	mask uint32
+	// Number of true atomic conditions of the implicants.
+	ones int
+}
+
+// groupOf returns the group of the given implicant.
+func groupOf(imp implicant) group {
+	return group{mask: imp.mask, ones: bits.OnesCount32(imp.value)}
+}
+
+// primeImplicants returns the prime implicants of the given minterms.
+//
+// Implicants are grouped by their absent atomic conditions and their number of
+// true atomic conditions, as only implicants of adjacent groups may differ in a
+// single atomic condition.
+func primeImplicants(minterms []uint32) []implicant {
+	var cur []implicant
+	for _, m := range minterms {
+		cur = append(cur, implicant{value: m})
+	}
+	var primes []implicant
+	for len(cur) > 0 {
+		groups := make(map[group][]implicant)
+		for _, a := range cur {
+			g := groupOf(a)
+			groups[g] = append(groups[g], a)
+		}
+		merged := make(map[implicant]bool)
+		seen := make(map[implicant]bool)
+		var next []implicant
+		for _, a := range cur {
+			g := groupOf(a)
+			g.ones++
+			for _, b := range groups[g] {
+				diff := a.value ^ b.value
+				if bits.OnesCount32(diff) != 1 {
+					continue
+				}
+				merged[a] = true
+				merged[b] = true
+				c := implicant{value: a.value &^ diff, mask: a.mask | diff}
+				if !seen[c] {
+					seen[c] = true
+					next = append(next, c)
+				}
+			}
+		}
+		for _, a := range cur {
+			if !merged[a] {
+				primes = append(primes, a)
+			}
+		}
+		cur = next
+	}
+	return primes
+}
+
+// selectCover returns a set of prime implicants covering the given minterms.
+func selectCover(primes []implicant, minterms []uint32) []implicant {
+	uncovered := make(map[uint32]bool)
+	for _, m := range minterms {
+		uncovered[m] = true
+	}
+	var cover []implicant
+	used := make([]bool, len(primes))
+	use := func(i int) {
+		used[i] = true
+		cover = append(cover, primes[i])
+		for _, m := range minterms {
+			if primes[i].covers(m) {
+				delete(uncovered, m)
+			}
+		}
+	}
+	// Select essential prime implicants; i.e. the prime implicants which are the
+	// only ones covering some minterm.
+	for _, m := range minterms {
+		if !uncovered[m] {
+			continue
+		}
+		only := -1
+		for i, p := range primes {
+			if !p.covers(m) {
+				continue
+			}
+			if only != -1 {
+				only = -1
+				break
+			}
+			only = i
+		}
+		if only != -1 && !used[only] {
+			use(only)
+		}
+	}
+	// Cover remaining minterms greedily.
+	for len(uncovered) > 0 {
+		best, bestCount := -1, 0
+		for i, p := range primes {
+			if used[i] {
+				continue
+			}
+			count := 0
+			for m := range uncovered {
+				if p.covers(m) {
+					count++
+				}
+			}
+			if count > bestCount || (count == bestCount && count > 0 && bits.OnesCount32(p.mask) > bits.OnesCount32(primes[best].mask)) {
+				best, bestCount = i, count
+			}
+		}
+		use(best)
+	}
+	return cover
+}
@@ -0,0 +1,129 @@
+package cond
+
+import "fmt"
+
+// Negate returns the negation of the given condition, with negations pushed
+// inwards to the Boolean variables and comparisons (e.g. `!%a || x != 1` for
+// `%a && x == 1`).
+func Negate(x Expr) Expr {
+	switch x := x.(type) {
+	case Const:
+		return !x
+	case *Var:
+		return &Not{X: x}
+	case *Compare:
+		return &Compare{Op: x.Op.Negate(), X: x.X, Y: x.Y}
+	case *Not:
+		return x.X
+	case *And:
+		or := &Or{}
+		for _, y := range x.Xs {
+			or.Xs = append(or.Xs, Negate(y))
+		}
+		return or
+	case *Or:
+		and := &And{}
+		for _, y := range x.Xs {
+			and.Xs = append(and.Xs, Negate(y))
+		}
+		return and
+	}
+	panic(fmt.Errorf("support for condition %T not yet implemented", x))
+}
+
+// Normalize returns the normalized form of the given condition.
+//
+// Normalized conditions are in negation normal form (i.e. only Boolean
+// variables are negated), with nested conjunctions and disjunctions flattened,
+// Boolean constants folded, duplicate operands removed, and conjunctions and
+// disjunctions of a single operand replaced by the operand.
+func Normalize(x Expr) Expr {
+	switch x := x.(type) {
+	case Const, *Var, *Compare:
+		return x
+	case *Not:
+		y := Normalize(x.X)
+		if _, ok := y.(*Var); ok {
+			return &Not{X: y}
+		}
+		return Negate(y)
+	case *And:
+		return normJunction(x.Xs, True)
+	case *Or:
+		return normJunction(x.Xs, False)
+	}
+	panic(fmt.Errorf("support for condition %T not yet implemented", x))
+}
+
+// normJunction returns the normalized conjunction (if unit is true) or
+// disjunction (if unit is false) of the given operands.
+func normJunction(xs []Expr, unit Const) Expr {
+	// Normalize and flatten operands.
+	var ys []Expr
+	for _, x := range xs {
+		y := Normalize(x)
+		switch y := y.(type) {
+		case *And:
+			if unit == True {
+				ys = append(ys, y.Xs...)
+				continue
+			}
+		case *Or:
+			if unit == False {
+				ys = append(ys, y.Xs...)
+				continue
+			}
+		}
+		ys = append(ys, y)
+	}
+	// Fold constants, and remove duplicate and complementary operands.
+	seen := make(map[string]bool)
+	var zs []Expr
+	for _, y := range ys {
+		if c, ok := y.(Const); ok {
+			if c != unit {
+				// x && false = false, x || true = true
+				return !unit
+			}
+			continue
+		}
+		s := y.String()
+		if seen[s] {
+			continue
+		}
+		if seen[Negate(y).String()] {
+			// x && !x = false, x || !x = true
+			return !unit
+		}
+		seen[s] = true
+		zs = append(zs, y)
+	}
+	switch len(zs) {
+	case 0:
+		return unit
+	case 1:
+		return zs[0]
+	}
+	if unit == True {
+		return &And{Xs: zs}
+	}
+	return &Or{Xs: zs}
+}
+
+// Atom returns the atomic condition of the given Boolean variable or
+// comparison, and reports whether the atomic condition is negated. The
+// comparison operators `!=`, `>=` and `>` are represented as the negation of
+// `==`, `<` and `<=` respectively; e.g. `x == 1` negated for `x != 1`.
+func Atom(x Expr) (Expr, bool) {
+	switch x := x.(type) {
+	case *Var:
+		return x, false
+	case *Compare:
+		switch x.Op {
+		case CmpNe, CmpGe, CmpGt:
+			return &Compare{Op: x.Op.Negate(), X: x.X, Y: x.Y}, true
+		}
+		return x, false
+	}
+	panic(fmt.Errorf("support for atomic condition %T not yet implemented", x))
+}
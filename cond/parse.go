@@ -0,0 +1,211 @@
+package cond
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Parse parses the given condition, using the syntax of edge labels produced
+// by cfg.NewGraphFromFunc.
+//
+// Conditions may be combined using `&&`, `||`, `!` and parentheses, and
+// operands compared using `==`, `!=`, `<`, `<=`, `>` and `>=`. The identifiers
+// `true` and `false` denote Boolean constants.
+func Parse(s string) (Expr, error) {
+	toks, err := lex(s)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse condition %q", s)
+	}
+	p := &parser{toks: toks}
+	x, err := p.parseOr()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse condition %q", s)
+	}
+	if p.pos != len(p.toks) {
+		return nil, errors.Errorf("unable to parse condition %q; unexpected token %q", s, p.toks[p.pos])
+	}
+	return x, nil
+}
+
+// parser is a recursive descent parser of conditions.
+type parser struct {
+	// Tokens of the condition.
+	toks []string
+	// Current position in toks.
+	pos int
+}
+
+// peek returns the current token, or the empty string at end of input.
+func (p *parser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+// parseOr parses a disjunction.
+//
+//    Or = And { "||" And } .
+func (p *parser) parseOr() (Expr, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "||" {
+		return x, nil
+	}
+	or := &Or{Xs: []Expr{x}}
+	for p.peek() == "||" {
+		p.pos++
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or.Xs = append(or.Xs, y)
+	}
+	return or, nil
+}
+
+// parseAnd parses a conjunction.
+//
+//    And = Not { "&&" Not } .
+func (p *parser) parseAnd() (Expr, error) {
+	x, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != "&&" {
+		return x, nil
+	}
+	and := &And{Xs: []Expr{x}}
+	for p.peek() == "&&" {
+		p.pos++
+		y, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		and.Xs = append(and.Xs, y)
+	}
+	return and, nil
+}
+
+// parseNot parses a possibly negated primary condition.
+//
+//    Not     = "!" Not | Primary .
+//    Primary = "(" Or ")" | Operand [ CmpOp Operand ] .
+func (p *parser) parseNot() (Expr, error) {
+	switch tok := p.peek(); {
+	case tok == "!":
+		p.pos++
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Not{X: x}, nil
+	case tok == "(":
+		p.pos++
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.Errorf("expected %q, got %q", ")", p.peek())
+		}
+		p.pos++
+		return x, nil
+	case !isOperand(tok):
+		return nil, errors.Errorf("expected operand, got %q", tok)
+	}
+	x := p.toks[p.pos]
+	p.pos++
+	if op, ok := cmpOps[p.peek()]; ok {
+		p.pos++
+		y := p.peek()
+		if !isOperand(y) {
+			return nil, errors.Errorf("expected operand, got %q", y)
+		}
+		p.pos++
+		return &Compare{Op: op, X: x, Y: y}, nil
+	}
+	switch x {
+	case "true":
+		return True, nil
+	case "false":
+		return False, nil
+	}
+	return &Var{Name: x}, nil
+}
+
+// cmpOps maps from comparison operator token to comparison operator.
+var cmpOps = map[string]CmpOp{
+	"==": CmpEq,
+	"!=": CmpNe,
+	"<":  CmpLt,
+	"<=": CmpLe,
+	">":  CmpGt,
+	">=": CmpGe,
+}
+
+// isOperand reports whether the given token is an operand.
+func isOperand(tok string) bool {
+	switch tok {
+	case "", "!", "(", ")", "&&", "||":
+		return false
+	}
+	_, ok := cmpOps[tok]
+	return !ok
+}
+
+// lex splits the given condition into tokens.
+func lex(s string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(s); {
+		switch {
+		case s[i] == ' ' || s[i] == '\t':
+			i++
+		case hasAnyPrefix(s[i:], "&&", "||", "==", "!=", "<=", ">="):
+			toks = append(toks, s[i:i+2])
+			i += 2
+		case strings.IndexByte("!()<>", s[i]) != -1:
+			toks = append(toks, s[i:i+1])
+			i++
+		default:
+			j := i
+			for j < len(s) && !isDelim(rune(s[j])) {
+				if s[j] == '"' {
+					// Quoted identifier (e.g. `%"foo bar"`).
+					end := strings.IndexByte(s[j+1:], '"')
+					if end == -1 {
+						return nil, errors.Errorf("unterminated quoted identifier %q", s[i:])
+					}
+					j += end + 1
+				}
+				j++
+			}
+			if j == i {
+				// Consume unknown character as a single token.
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+// hasAnyPrefix reports whether s begins with any of the given prefixes.
+func hasAnyPrefix(s string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDelim reports whether the given character delimits an operand.
+func isDelim(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("!()&|=<>", r)
+}
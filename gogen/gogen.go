@@ -0,0 +1,650 @@
+// Package gogen implements a Go back end, which translates LLVM IR functions
+// into Go source code.
+//
+// The control flow of a function is given by the abstract syntax tree of its
+// structured control flow graph (see cfg.NewGraphFromFunc and
+// structure.Structure), and the instructions of each basic block are translated
+// into Go statements within the recovered if, for and switch statements; e.g.
+//
+//    func f(x int32) int32 {
+//       var (
+//          cond bool
+//          y    int32
+//       )
+//       cond = x < 10
+//       if cond {
+//          y = x + 1
+//          return y
+//       }
+//       return x
+//    }
+//
+// Integer types of 8, 16, 32 and 64 bits are mapped to the signed Go integer
+// types of the same size, the 1-bit integer type to bool, and pointers to
+// integers to Go pointers. Unsigned operations convert their operands to the
+// unsigned Go integer types. The generated code type-checks with go/types.
+// Instructions and types without Go equivalent (e.g. floating-point arithmetic
+// and getelementptr) are reported as errors.
+//
+// Global variables referenced by a function are declared as package-level
+// variables (e.g. "var g = new(int32)" for a global variable of type i32), and
+// functions referenced by a function as function declarations without body
+// (e.g. "func h(int32) int32"), to be implemented externally (e.g. in assembly)
+// unless defined in the same source file (see NewFile). The initial values of
+// global variables are not translated.
+//
+// Local variables are declared at the start of the function, and phi
+// instructions are lowered to copies through temporary variables (e.g.
+// "x_phi"), assigned at the end of their predecessor basic blocks. Results of
+// instructions never read are assigned to the blank identifier.
+package gogen
+
+import (
+	"bytes"
+	"fmt"
+	goast "go/ast"
+	"go/format"
+	"go/token"
+	"io"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cond"
+	"github.com/mewmew/pi/internal/backend"
+	"github.com/pkg/errors"
+)
+
+// Fprint writes the gofmt'ed Go source code of the given function to w, with
+// the control flow of the function given by the abstract syntax tree n of its
+// structured control flow graph. The function is preceded by the declarations
+// of the global variables and functions it references (see NewDecls).
+func Fprint(w io.Writer, f *ir.Func, n ast.Node) error {
+	decls, err := NewDecls(f, n)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for i, decl := range decls {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		if err := Format(w, decl); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Format writes the gofmt'ed Go source code of the given node (e.g. a function
+// declaration or source file) to w.
+func Format(w io.Writer, node goast.Node) error {
+	buf := &bytes.Buffer{}
+	if err := format.Node(buf, token.NewFileSet(), node); err != nil {
+		return errors.WithStack(err)
+	}
+	// Nodes without position information are not formatted canonically (e.g.
+	// line breaks); reformat the source code after parsing.
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if !bytes.HasSuffix(src, []byte("\n")) {
+		src = append(src, '\n')
+	}
+	if _, err := w.Write(src); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// NewFile returns a Go source file of the given package, containing the given
+// declarations. Function declarations without body of functions defined by
+// other declarations, and declarations of identifiers already declared by
+// preceding declarations are omitted (e.g. the declarations of a function
+// referenced by several functions, or defined in the source file).
+func NewFile(pkgName string, decls ...goast.Decl) *goast.File {
+	defined := make(map[string]bool)
+	for _, decl := range decls {
+		if fn, ok := decl.(*goast.FuncDecl); ok && fn.Recv == nil && fn.Body != nil {
+			defined[fn.Name.Name] = true
+		}
+	}
+	declared := make(map[string]bool)
+	file := &goast.File{Name: goast.NewIdent(pkgName)}
+	for _, decl := range decls {
+		if fn, ok := decl.(*goast.FuncDecl); ok && fn.Recv == nil {
+			if fn.Body == nil && (defined[fn.Name.Name] || declared[fn.Name.Name]) {
+				continue
+			}
+			declared[fn.Name.Name] = true
+		}
+		if gen, ok := decl.(*goast.GenDecl); ok && gen.Tok == token.VAR {
+			var specs []goast.Spec
+			for _, spec := range gen.Specs {
+				if v, ok := spec.(*goast.ValueSpec); ok && len(v.Names) == 1 {
+					if declared[v.Names[0].Name] || defined[v.Names[0].Name] {
+						continue
+					}
+					declared[v.Names[0].Name] = true
+				}
+				specs = append(specs, spec)
+			}
+			if len(specs) == 0 {
+				continue
+			}
+			gen.Specs = specs
+		}
+		file.Decls = append(file.Decls, decl)
+	}
+	return file
+}
+
+// NewDecls returns the Go declarations of the given function, with the control
+// flow of the function given by the abstract syntax tree n of its structured
+// control flow graph; i.e. the declarations of the global variables and
+// functions referenced by the function, followed by the function declaration.
+func NewDecls(f *ir.Func, n ast.Node) ([]goast.Decl, error) {
+	g, err := newGenerator(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sig, err := g.signature()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	body := g.stmt(n)
+	if g.err != nil {
+		return nil, errors.WithStack(g.err)
+	}
+	var decls []goast.Decl
+	for _, v := range g.refs {
+		decl, err := refDecl(v)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		decls = append(decls, decl)
+	}
+	if sig.Results != nil && (len(body) == 0 || !isTerminating(body[len(body)-1], "")) {
+		// Control never reaches the end of the function, as each path of the
+		// LLVM IR function ends with a terminator.
+		body = append(body, panicStmt("unreachable"))
+	}
+	if decls := g.decls(body); decls != nil {
+		body = append([]goast.Stmt{decls}, body...)
+	}
+	decl := &goast.FuncDecl{
+		Name: goast.NewIdent(goIdent(f.Ident())),
+		Type: sig,
+		Body: &goast.BlockStmt{List: body},
+	}
+	return append(decls, decl), nil
+}
+
+// refDecl returns the Go declaration of the given global variable or function,
+// referenced by the function being translated; i.e. a variable declaration of
+// global variables and a function declaration without body of functions.
+func refDecl(v value.Named) (goast.Decl, error) {
+	name := goast.NewIdent(goIdent(v.Ident()))
+	switch v := v.(type) {
+	case *ir.Global:
+		typ, err := goType(v.ContentType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		spec := &goast.ValueSpec{
+			Names:  []*goast.Ident{name},
+			Values: []goast.Expr{call(goast.NewIdent("new"), typ)},
+		}
+		return &goast.GenDecl{Tok: token.VAR, Specs: []goast.Spec{spec}}, nil
+	case *ir.Func:
+		typ, err := funcType(v.Sig)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &goast.FuncDecl{Name: name, Type: typ}, nil
+	default:
+		return nil, errors.Errorf("support for global value %T not yet implemented", v)
+	}
+}
+
+// generator is a Go code generator of functions.
+type generator struct {
+	// First error encountered while generating code.
+	err error
+	// Function being translated.
+	f *backend.Func
+	// Loops enclosing the current statement.
+	loops backend.Loops
+	// Go identifiers of the local variables of the function, in order of first
+	// assignment.
+	vars []string
+	// varTypes maps from Go identifier of local variables to their Go type.
+	varTypes map[string]goast.Expr
+	// Global variables and functions referenced by the function, in order of
+	// first reference.
+	refs []value.Named
+}
+
+// newGenerator returns a new Go code generator of the given function.
+func newGenerator(f *ir.Func) (*generator, error) {
+	fn, err := backend.NewFunc(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	g := &generator{
+		f:        fn,
+		varTypes: make(map[string]goast.Expr),
+	}
+	return g, nil
+}
+
+// fail records the given error, unless an error has already been encountered.
+func (g *generator) fail(err error) {
+	if g.err == nil {
+		g.err = errors.WithStack(err)
+	}
+}
+
+// errorf records the formatted error, unless an error has already been
+// encountered.
+func (g *generator) errorf(format string, args ...interface{}) {
+	g.fail(errors.Errorf(format, args...))
+}
+
+// signature returns the Go function type of the function being translated.
+func (g *generator) signature() (*goast.FuncType, error) {
+	if g.f.Sig.Variadic {
+		return nil, errors.Errorf("support for variadic function %q not yet implemented", g.f.Name())
+	}
+	sig, err := funcType(g.f.Sig)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for i, param := range g.f.Params {
+		sig.Params.List[i].Names = []*goast.Ident{goast.NewIdent(goIdent(param.Ident()))}
+	}
+	return sig, nil
+}
+
+// ref records the reference to the given global variable or function, unless
+// referring to the function being translated.
+func (g *generator) ref(v value.Named) {
+	if f, ok := v.(*ir.Func); ok && f == g.f.Func {
+		return
+	}
+	for _, ref := range g.refs {
+		if ref == v {
+			return
+		}
+	}
+	g.refs = append(g.refs, v)
+}
+
+// def records the definition of the given local variable.
+func (g *generator) def(v value.Value) {
+	typ, err := goType(v.Type())
+	if err != nil {
+		g.fail(err)
+		return
+	}
+	g.defVar(goIdent(v.Ident()), typ)
+}
+
+// defVar records the definition of the local variable with the given Go
+// identifier and type.
+func (g *generator) defVar(name string, typ goast.Expr) {
+	if _, ok := g.varTypes[name]; ok {
+		return
+	}
+	g.vars = append(g.vars, name)
+	g.varTypes[name] = typ
+}
+
+// decls returns the declaration statement of the local variables read by the
+// given statements; or nil if no local variable is read. Assignments to local
+// variables never read are replaced by assignments to the blank identifier, as
+// Go does not permit unused variables.
+func (g *generator) decls(body []goast.Stmt) goast.Stmt {
+	// Locate assigned and read identifiers.
+	assigned := make(map[*goast.Ident]bool)
+	for _, stmt := range body {
+		goast.Inspect(stmt, func(n goast.Node) bool {
+			if assign, ok := n.(*goast.AssignStmt); ok {
+				for _, lhs := range assign.Lhs {
+					if ident, ok := lhs.(*goast.Ident); ok {
+						assigned[ident] = true
+					}
+				}
+			}
+			return true
+		})
+	}
+	read := make(map[string]bool)
+	for _, stmt := range body {
+		goast.Inspect(stmt, func(n goast.Node) bool {
+			if ident, ok := n.(*goast.Ident); ok && !assigned[ident] {
+				read[ident.Name] = true
+			}
+			return true
+		})
+	}
+	for ident := range assigned {
+		if _, ok := g.varTypes[ident.Name]; ok && !read[ident.Name] {
+			ident.Name = "_"
+		}
+	}
+	// Declare local variables.
+	decl := &goast.GenDecl{Tok: token.VAR}
+	for _, name := range g.vars {
+		if !read[name] {
+			continue
+		}
+		spec := &goast.ValueSpec{
+			Names: []*goast.Ident{goast.NewIdent(name)},
+			Type:  g.varTypes[name],
+		}
+		decl.Specs = append(decl.Specs, spec)
+	}
+	if len(decl.Specs) == 0 {
+		return nil
+	}
+	if len(decl.Specs) > 1 {
+		decl.Lparen = 1
+	}
+	return &goast.DeclStmt{Decl: decl}
+}
+
+// === [ Statements ] ==========================================================
+
+// stmt returns the Go statements of the given node.
+func (g *generator) stmt(n ast.Node) []goast.Stmt {
+	switch n := n.(type) {
+	case *ast.Block:
+		return g.block(n)
+	case *ast.Seq:
+		var stmts []goast.Stmt
+		for _, nn := range n.Nodes {
+			stmts = append(stmts, g.stmt(nn)...)
+		}
+		return stmts
+	case *ast.If:
+		return []goast.Stmt{&goast.IfStmt{Cond: g.cond(n.Cond), Body: g.body(n.Then)}}
+	case *ast.IfElse:
+		stmt := &goast.IfStmt{Cond: g.cond(n.Cond), Body: g.body(n.Then)}
+		// Nested conditionals of the else-branch are written as else-if
+		// clauses.
+		els := g.stmt(n.Else)
+		if len(els) == 1 {
+			if nested, ok := els[0].(*goast.IfStmt); ok {
+				stmt.Else = nested
+				return []goast.Stmt{stmt}
+			}
+		}
+		stmt.Else = &goast.BlockStmt{List: els}
+		return []goast.Stmt{stmt}
+	case *ast.While:
+		return g.loop(func() *goast.ForStmt {
+			return &goast.ForStmt{Cond: g.cond(n.Cond), Body: g.body(n.Body)}
+		})
+	case *ast.DoWhile:
+		// Go has no post-test loops; the loop condition is evaluated at the end
+		// of the loop body of an endless loop. Continue statements would skip
+		// the loop condition.
+		if hasContinue(n.Body) {
+			g.errorf("support for continue statements in post-test loop of function %q not yet implemented", g.f.Name())
+			return nil
+		}
+		return g.loop(func() *goast.ForStmt {
+			body := g.body(n.Body)
+			exit := &goast.IfStmt{
+				Cond: g.negCond(n.Cond),
+				Body: &goast.BlockStmt{List: []goast.Stmt{&goast.BranchStmt{Tok: token.BREAK}}},
+			}
+			body.List = append(body.List, exit)
+			return &goast.ForStmt{Body: body}
+		})
+	case *ast.For:
+		return g.forStmt(n)
+	case *ast.Endless:
+		return g.loop(func() *goast.ForStmt {
+			return &goast.ForStmt{Body: g.body(n.Body)}
+		})
+	case *ast.Switch:
+		return []goast.Stmt{g.switchStmt(n)}
+	case *ast.Break:
+		return []goast.Stmt{g.breakStmt()}
+	case *ast.Continue:
+		return []goast.Stmt{&goast.BranchStmt{Tok: token.CONTINUE}}
+	case *ast.Return:
+		stmt := &goast.ReturnStmt{}
+		if len(n.Value) > 0 {
+			stmt.Results = []goast.Expr{operand(n.Value)}
+		}
+		return []goast.Stmt{stmt}
+	case *ast.Goto:
+		return []goast.Stmt{&goast.BranchStmt{Tok: token.GOTO, Label: goast.NewIdent(goIdent(n.Label))}}
+	case *ast.Label:
+		return []goast.Stmt{&goast.LabeledStmt{Label: goast.NewIdent(goIdent(n.Name)), Stmt: &goast.EmptyStmt{Implicit: true}}}
+	case *ast.Assign:
+		return []goast.Stmt{g.assignStmt(n)}
+	default:
+		panic(fmt.Errorf("support for abstract syntax tree node %T not yet implemented", n))
+	}
+}
+
+// body returns the Go block statement of the given node.
+func (g *generator) body(n ast.Node) *goast.BlockStmt {
+	return &goast.BlockStmt{List: g.stmt(n)}
+}
+
+// forStmt returns the Go statements of the given for loop. Init and post
+// statements other than assignments are placed before the loop and at the end
+// of the loop body respectively.
+func (g *generator) forStmt(n *ast.For) []goast.Stmt {
+	var stmts []goast.Stmt
+	var init, post goast.Stmt
+	if n.Init != nil {
+		if s, ok := n.Init.(*ast.Assign); ok {
+			init = g.assignStmt(s)
+		} else {
+			stmts = append(stmts, g.stmt(n.Init)...)
+		}
+	}
+	body := n.Body
+	if n.Post != nil {
+		if s, ok := n.Post.(*ast.Assign); ok {
+			post = g.assignStmt(s)
+		} else {
+			body = ast.NewSeq(body, n.Post)
+		}
+	}
+	loop := g.loop(func() *goast.ForStmt {
+		return &goast.ForStmt{Init: init, Cond: g.cond(n.Cond), Post: post, Body: g.body(body)}
+	})
+	return append(stmts, loop...)
+}
+
+// switchStmt returns the Go switch statement of the given switch statement.
+func (g *generator) switchStmt(n *ast.Switch) goast.Stmt {
+	defer g.loops.EnterSwitch()()
+	body := &goast.BlockStmt{}
+	for _, c := range n.Cases {
+		clause := &goast.CaseClause{Body: g.stmt(c.Body)}
+		for _, v := range c.Values {
+			clause.List = append(clause.List, operand(v))
+		}
+		body.List = append(body.List, clause)
+	}
+	if n.Default != nil {
+		body.List = append(body.List, &goast.CaseClause{Body: g.stmt(n.Default)})
+	}
+	return &goast.SwitchStmt{Tag: goast.NewIdent(goIdent(n.Var)), Body: body}
+}
+
+// loop returns the loop statement of the given function, labelled if the label
+// of the loop is used.
+func (g *generator) loop(gen func() *goast.ForStmt) []goast.Stmt {
+	l := g.loops.Enter()
+	stmt := gen()
+	g.loops.Leave()
+	if l.Labelled {
+		return []goast.Stmt{&goast.LabeledStmt{Label: loopLabel(l), Stmt: stmt}}
+	}
+	return []goast.Stmt{stmt}
+}
+
+// breakStmt returns a break statement of the innermost enclosing loop. Within
+// switch statements, the break statement refers to the label of the loop, as
+// unlabelled break statements would exit the switch statement.
+func (g *generator) breakStmt() goast.Stmt {
+	if l := g.loops.Break(); l != nil {
+		return &goast.BranchStmt{Tok: token.BREAK, Label: loopLabel(l)}
+	}
+	return &goast.BranchStmt{Tok: token.BREAK}
+}
+
+// loopLabel returns the label of the given loop (e.g. "loop1").
+func loopLabel(l *backend.Loop) *goast.Ident {
+	return goast.NewIdent(fmt.Sprintf("loop%d", l.ID))
+}
+
+// assignStmt returns the Go assignment statement of the given assignment to a
+// selector variable.
+func (g *generator) assignStmt(n *ast.Assign) goast.Stmt {
+	name := goIdent(n.Var)
+	g.defVar(name, goast.NewIdent("int"))
+	return assign(goast.NewIdent(name), intLit(fmt.Sprint(n.Value)))
+}
+
+// cond returns the Go expression of the given branching condition, as
+// represented in the textual notation of package cond.
+func (g *generator) cond(c string) goast.Expr {
+	x, err := cond.Parse(c)
+	if err != nil {
+		g.fail(err)
+		return goast.NewIdent("false")
+	}
+	return condExpr(x)
+}
+
+// negCond returns the Go expression of the negation of the given branching
+// condition.
+func (g *generator) negCond(c string) goast.Expr {
+	x, err := cond.Parse(c)
+	if err != nil {
+		g.fail(err)
+		return goast.NewIdent("false")
+	}
+	return condExpr(cond.Negate(x))
+}
+
+// hasContinue reports whether the given node contains a continue statement of
+// the enclosing loop; i.e. a continue statement not nested within another
+// loop.
+func hasContinue(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Continue:
+			found = true
+		case *ast.While, *ast.DoWhile, *ast.For, *ast.Endless:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// === [ Terminating statements ] ==============================================
+
+// isTerminating reports whether the given statement is a terminating statement,
+// as defined by the Go specification; where label is the label of the
+// statement, or empty if not labelled.
+func isTerminating(s goast.Stmt, label string) bool {
+	switch s := s.(type) {
+	case *goast.ReturnStmt:
+		return true
+	case *goast.BranchStmt:
+		return s.Tok == token.GOTO || s.Tok == token.FALLTHROUGH
+	case *goast.ExprStmt:
+		call, ok := s.X.(*goast.CallExpr)
+		if !ok {
+			return false
+		}
+		ident, ok := call.Fun.(*goast.Ident)
+		return ok && ident.Name == "panic"
+	case *goast.BlockStmt:
+		return len(s.List) > 0 && isTerminating(s.List[len(s.List)-1], "")
+	case *goast.IfStmt:
+		return s.Else != nil && isTerminating(s.Body, "") && isTerminating(s.Else, "")
+	case *goast.LabeledStmt:
+		return isTerminating(s.Stmt, s.Label.Name)
+	case *goast.ForStmt:
+		return s.Cond == nil && !hasBreak(s.Body, label, false)
+	case *goast.SwitchStmt:
+		hasDefault := false
+		for _, stmt := range s.Body.List {
+			clause := stmt.(*goast.CaseClause)
+			if clause.List == nil {
+				hasDefault = true
+			}
+			body := &goast.BlockStmt{List: clause.Body}
+			if !isTerminating(body, "") || hasBreak(body, label, false) {
+				return false
+			}
+		}
+		return hasDefault
+	}
+	return false
+}
+
+// hasBreak reports whether the given statement contains a break statement
+// referring to the enclosing statement with the given label (or empty if not
+// labelled); where nested specifies whether the statement is nested within
+// another for or switch statement, and unlabelled break statements thus refer
+// to the nested statement.
+func hasBreak(s goast.Stmt, label string, nested bool) bool {
+	switch s := s.(type) {
+	case *goast.BranchStmt:
+		if s.Tok != token.BREAK {
+			return false
+		}
+		if s.Label == nil {
+			return !nested
+		}
+		return s.Label.Name == label
+	case *goast.BlockStmt:
+		for _, stmt := range s.List {
+			if hasBreak(stmt, label, nested) {
+				return true
+			}
+		}
+	case *goast.IfStmt:
+		return hasBreak(s.Body, label, nested) || (s.Else != nil && hasBreak(s.Else, label, nested))
+	case *goast.LabeledStmt:
+		return hasBreak(s.Stmt, label, nested)
+	case *goast.ForStmt:
+		return hasBreak(s.Body, label, true)
+	case *goast.SwitchStmt:
+		return hasBreak(s.Body, label, true)
+	case *goast.CaseClause:
+		return hasBreak(&goast.BlockStmt{List: s.Body}, label, nested)
+	}
+	return false
+}
+
+// panicStmt returns a call to panic with the given message.
+func panicStmt(msg string) goast.Stmt {
+	call := &goast.CallExpr{
+		Fun:  goast.NewIdent("panic"),
+		Args: []goast.Expr{&goast.BasicLit{Kind: token.STRING, Value: fmt.Sprintf("%q", msg)}},
+	}
+	return &goast.ExprStmt{X: call}
+}
+
+// assign returns the assignment of the given expression to lhs.
+func assign(lhs, rhs goast.Expr) goast.Stmt {
+	return &goast.AssignStmt{Lhs: []goast.Expr{lhs}, Tok: token.ASSIGN, Rhs: []goast.Expr{rhs}}
+}
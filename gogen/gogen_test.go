@@ -0,0 +1,459 @@
+package gogen
+
+import (
+	goast "go/ast"
+	"go/parser"
+	"go/token"
+	gotypes "go/types"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/internal/backend/backendtest"
+)
+
+func TestFprint(t *testing.T) {
+	golden := []struct {
+		name string
+		f    *ir.Func
+		want string
+	}{
+		{
+			name: "if-else",
+			f:    backendtest.IfElse(),
+			want: `
+func f(x int32) int32 {
+	var (
+		cond  bool
+		y     int32
+		r_phi int32
+		z     int32
+		r     int32
+	)
+	cond = x < 10
+	if cond {
+		y = x + 1
+		r_phi = y
+	} else {
+		z = x - 1
+		r_phi = z
+	}
+	r = r_phi
+	return r
+}
+`,
+		},
+		{
+			name: "if",
+			f:    backendtest.If(),
+			want: `
+func abs(x int32) int32 {
+	var (
+		neg   bool
+		r_phi int32
+		y     int32
+		r     int32
+	)
+	neg = x < 0
+	r_phi = x
+	if neg {
+		y = 0 - x
+		r_phi = y
+	}
+	r = r_phi
+	return r
+}
+`,
+		},
+		{
+			name: "loop",
+			f:    backendtest.Loop(),
+			want: `
+func sum(n int32) int32 {
+	var (
+		i_phi  int32
+		s_phi  int32
+		i      int32
+		s      int32
+		cond   bool
+		s_next int32
+		i_next int32
+	)
+	i_phi = 0
+	s_phi = 0
+	for {
+		i = i_phi
+		s = s_phi
+		cond = uint32(i) < uint32(n)
+		if !cond {
+			break
+		}
+		s_next = s + i
+		i_next = i + 1
+		i_phi = i_next
+		s_phi = s_next
+	}
+	return s
+}
+`,
+		},
+		{
+			name: "swap",
+			f:    backendtest.Swap(),
+			want: `
+func swap(x int32, y int32, n int32) int32 {
+	var (
+		a_phi  int32
+		b_phi  int32
+		i_phi  int32
+		a      int32
+		b      int32
+		i      int32
+		i_next int32
+		cond   bool
+	)
+	a_phi = x
+	b_phi = y
+	i_phi = 0
+	for {
+		for {
+			a = a_phi
+			b = b_phi
+			i = i_phi
+			i_next = i + 1
+			cond = i != n
+			a_phi = b
+			b_phi = a
+			i_phi = i_next
+			if !cond {
+				break
+			}
+		}
+		return a
+	}
+}
+`,
+		},
+		{
+			name: "chain",
+			f:    backendtest.Chain(),
+			want: `
+func h(x int32) int32 {
+	switch x {
+	case 1:
+		return 10
+	case 2:
+		return 20
+	default:
+		return 0
+	}
+}
+`,
+		},
+		{
+			name: "switch",
+			f:    backendtest.Switch(),
+			want: `
+func g(x int32) int32 {
+	switch x {
+	case 1:
+		return 10
+	case 2, 3:
+		return 20
+	default:
+		return 0
+	}
+}
+`,
+		},
+		{
+			name: "call",
+			f:    callFunc(),
+			want: `
+func h(int32, int8) int32
+
+func call(x int32) int32 {
+	var (
+		y int32
+		z int32
+		r int32
+	)
+	y = h(x, 1)
+	z = h(y, 2)
+	r = call(z)
+	return r
+}
+`,
+		},
+		{
+			name: "global",
+			f:    globalFunc(),
+			want: `
+var count = new(int32)
+
+func inc() int32 {
+	var (
+		x int32
+		y int32
+	)
+	x = *count
+	y = x + 1
+	*count = y
+	return y
+}
+`,
+		},
+		{
+			name: "ops",
+			f:    opsFunc(),
+			want: `
+func ops(x int32, len_ int8) int64 {
+	var (
+		a    int32
+		b    int32
+		c    int32
+		odd  bool
+		even bool
+		d    int32
+		e    int32
+		sel  int32
+		p    *int32
+		v    int32
+		r    int64
+	)
+	a = int32(uint32(x) / 4294967294)
+	b = int32(uint32(a) >> 3)
+	c = b << uint32(x)
+	odd = c&1 != 0
+	even = odd != true
+	d = 0
+	if even {
+		d = 1
+	}
+	e = int32(len_)
+	if odd {
+		sel = d
+	} else {
+		sel = e
+	}
+	p = new(int32)
+	*p = sel
+	v = *p
+	_ = 255
+	r = int64(uint32(v))
+	return r
+}
+`,
+		},
+	}
+	for _, gold := range golden {
+		n, err := backendtest.Structure(gold.f)
+		if err != nil {
+			t.Errorf("%q; unable to structure function; %v", gold.name, err)
+			continue
+		}
+		buf := &strings.Builder{}
+		if err := Fprint(buf, gold.f, n); err != nil {
+			t.Errorf("%q; unable to print function; %v", gold.name, err)
+			continue
+		}
+		got := buf.String()
+		want := strings.TrimPrefix(gold.want, "\n")
+		if got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.name, want, got)
+		}
+		if err := typeCheck(got); err != nil {
+			t.Errorf("%q; unable to type-check output; %v", gold.name, err)
+		}
+	}
+}
+
+func TestFprintLoopLabel(t *testing.T) {
+	// Break statements of a loop within a switch statement refer to the label
+	// of the loop.
+	f := backendtest.Switch()
+	n := &ast.Endless{Body: &ast.Switch{
+		Var: "x",
+		Cases: []*ast.Case{
+			{Values: []string{"1"}, Body: &ast.Break{}},
+			{Values: []string{"2"}, Body: &ast.Continue{}},
+		},
+	}}
+	want := `
+func g(x int32) int32 {
+loop1:
+	for {
+		switch x {
+		case 1:
+			break loop1
+		case 2:
+			continue
+		}
+	}
+	panic("unreachable")
+}
+`[1:]
+	buf := &strings.Builder{}
+	if err := Fprint(buf, f, n); err != nil {
+		t.Fatalf("unable to print function; %v", err)
+	}
+	got := buf.String()
+	if got != want {
+		t.Errorf("output mismatch; expected `%s`, got `%s`", want, got)
+	}
+	if err := typeCheck(got); err != nil {
+		t.Errorf("unable to type-check output; %v", err)
+	}
+}
+
+func TestNewFile(t *testing.T) {
+	h := ir.NewFunc("h", types.I32, ir.NewParam("a", types.I32), ir.NewParam("b", types.I8))
+	h.NewBlock("entry").NewRet(constant.NewInt(types.I32, 0))
+	g := ir.NewFunc("g", types.I32)
+	entry := g.NewBlock("entry")
+	y := entry.NewCall(h, constant.NewInt(types.I32, 1), constant.NewInt(types.I8, 2))
+	entry.NewRet(y)
+	golden := []struct {
+		name  string
+		funcs []*ir.Func
+		want  []string
+	}{
+		// Declarations of functions defined in the source file are omitted, and
+		// declarations of global variables are only included once.
+		{
+			name:  "defined",
+			funcs: []*ir.Func{callFunc(), g, globalFunc(), h},
+			want:  []string{"func call", "func g", "var count", "func inc", "func h"},
+		},
+		// Declarations of external functions referenced by several functions
+		// are only included once.
+		{
+			name:  "external",
+			funcs: []*ir.Func{callFunc(), g},
+			want:  []string{"external func h", "func call", "func g"},
+		},
+	}
+	for _, gold := range golden {
+		var decls []goast.Decl
+		for _, f := range gold.funcs {
+			n, err := backendtest.Structure(f)
+			if err != nil {
+				t.Fatalf("%q; unable to structure function; %v", f.Name(), err)
+			}
+			ds, err := NewDecls(f, n)
+			if err != nil {
+				t.Fatalf("%q; unable to translate function; %v", f.Name(), err)
+			}
+			decls = append(decls, ds...)
+		}
+		file := NewFile("p", decls...)
+		var got []string
+		for _, decl := range file.Decls {
+			switch decl := decl.(type) {
+			case *goast.FuncDecl:
+				if decl.Body == nil {
+					got = append(got, "external func "+decl.Name.Name)
+				} else {
+					got = append(got, "func "+decl.Name.Name)
+				}
+			case *goast.GenDecl:
+				got = append(got, "var "+decl.Specs[0].(*goast.ValueSpec).Names[0].Name)
+			}
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; declarations mismatch; expected %q, got %q", gold.name, gold.want, got)
+		}
+	}
+}
+
+func TestFprintUnsupported(t *testing.T) {
+	// Instructions without Go equivalent are reported as errors.
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	y := entry.NewGetElementPtr(entry.NewAlloca(types.I32), x)
+	entry.NewRet(entry.NewLoad(y))
+	n, err := backendtest.Structure(f)
+	if err != nil {
+		t.Fatalf("unable to structure function; %v", err)
+	}
+	if err := Fprint(&strings.Builder{}, f, n); err == nil {
+		t.Errorf("expected error for getelementptr instruction, got nil")
+	}
+}
+
+// typeCheck type-checks the given Go function declaration.
+func typeCheck(src string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "out.go", "package p\n\n"+src, 0)
+	if err != nil {
+		return err
+	}
+	conf := &gotypes.Config{}
+	_, err = conf.Check("p", fset, []*goast.File{file}, nil)
+	return err
+}
+
+// callFunc returns a function calling an external function, and itself.
+func callFunc() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("call", types.I32, x)
+	callee := ir.NewFunc("h", types.I32, ir.NewParam("", types.I32), ir.NewParam("", types.I8))
+	entry := f.NewBlock("entry")
+	y := entry.NewCall(callee, x, constant.NewInt(types.I8, 1))
+	y.SetName("y")
+	z := entry.NewCall(callee, y, constant.NewInt(types.I8, 2))
+	z.SetName("z")
+	r := entry.NewCall(f, z)
+	r.SetName("r")
+	entry.NewRet(r)
+	return f
+}
+
+// globalFunc returns a function loading and storing the value of a global
+// variable.
+func globalFunc() *ir.Func {
+	f := ir.NewFunc("inc", types.I32)
+	g := ir.NewGlobal("count", types.I32)
+	entry := f.NewBlock("entry")
+	x := entry.NewLoad(g)
+	x.SetName("x")
+	y := entry.NewAdd(x, constant.NewInt(types.I32, 1))
+	y.SetName("y")
+	entry.NewStore(y, g)
+	entry.NewRet(y)
+	return f
+}
+
+// opsFunc returns a function of a single basic block, using unsigned, bitwise,
+// conversion and memory instructions.
+func opsFunc() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("len", types.I8)
+	f := ir.NewFunc("ops", types.I64, x, y)
+	entry := f.NewBlock("entry")
+	named := func(v value.Named, name string) value.Value {
+		v.SetName(name)
+		return v
+	}
+	a := named(entry.NewUDiv(x, constant.NewInt(types.I32, -2)), "a")
+	b := named(entry.NewLShr(a, constant.NewInt(types.I32, 3)), "b")
+	c := named(entry.NewShl(b, x), "c")
+	odd := named(entry.NewTrunc(c, types.I1), "odd")
+	even := named(entry.NewXor(odd, constant.True), "even")
+	d := named(entry.NewZExt(even, types.I32), "d")
+	e := named(entry.NewSExt(y, types.I32), "e")
+	sel := named(entry.NewSelect(odd, d, e), "sel")
+	p := named(entry.NewAlloca(types.I32), "p")
+	entry.NewStore(sel, p)
+	v := named(entry.NewLoad(p), "v")
+	named(entry.NewZExt(constant.NewInt(types.I8, -1), types.I16), "unused")
+	r := named(entry.NewZExt(v, types.I64), "r")
+	entry.NewRet(r)
+	return f
+}
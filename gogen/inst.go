@@ -0,0 +1,349 @@
+package gogen
+
+import (
+	goast "go/ast"
+	"go/token"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/internal/backend"
+)
+
+// === [ Basic blocks ] ========================================================
+
+// block returns the Go statements of the instructions of the basic block of the
+// given node, followed by the copies of phi instructions in successor basic
+// blocks. Branch terminators and the comparisons evaluated by branching
+// conditions (see backend.Func.Resolved) are omitted, as their control flow is
+// given by the abstract syntax tree.
+func (g *generator) block(n *ast.Block) []goast.Stmt {
+	block, ok := g.f.Block(n)
+	if !ok {
+		name := "<nil>"
+		if n.Node != nil {
+			name = n.Node.DOTID()
+		}
+		g.errorf("unable to locate basic block of node %q in function %q", name, g.f.Name())
+		return nil
+	}
+	var stmts []goast.Stmt
+	for _, inst := range block.Insts {
+		if g.f.Resolved(inst) {
+			continue
+		}
+		stmts = append(stmts, g.inst(inst)...)
+	}
+	stmts = append(stmts, g.phiCopies(block)...)
+	switch term := block.Term.(type) {
+	case *ir.TermRet:
+		stmt := &goast.ReturnStmt{}
+		if term.X != nil {
+			stmt.Results = []goast.Expr{g.value(term.X)}
+		}
+		stmts = append(stmts, stmt)
+	case *ir.TermBr, *ir.TermCondBr, *ir.TermSwitch:
+		// Control flow given by the abstract syntax tree.
+	case *ir.TermUnreachable:
+		stmts = append(stmts, panicStmt("unreachable"))
+	default:
+		g.errorf("support for terminator %T not yet implemented", term)
+	}
+	return stmts
+}
+
+// phiCopies returns the copies of the incoming values from the given basic
+// block to the temporary variables of the phi instructions of its successors
+// (see backend.Func.PhiCopies).
+func (g *generator) phiCopies(block *ir.Block) []goast.Stmt {
+	var stmts []goast.Stmt
+	for _, c := range g.f.PhiCopies(block) {
+		stmts = append(stmts, assign(g.phiVar(c.Phi), g.value(c.X)))
+	}
+	return stmts
+}
+
+// phiVar returns the Go identifier of the temporary variable of the given phi
+// instruction (see backend.PhiVar).
+func (g *generator) phiVar(phi *ir.InstPhi) goast.Expr {
+	typ, err := goType(phi.Type())
+	if err != nil {
+		g.fail(err)
+		return goast.NewIdent("_")
+	}
+	name := backend.PhiVar(phi)
+	g.defVar(name, typ)
+	return goast.NewIdent(name)
+}
+
+// === [ Instructions ] ========================================================
+
+// inst returns the Go statements of the given instruction. Phi instructions are
+// translated into copies of their temporary variables, assigned in their
+// predecessor basic blocks (see generator.phiCopies).
+func (g *generator) inst(inst ir.Instruction) []goast.Stmt {
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		return g.binary(inst, inst.X, token.ADD, inst.Y)
+	case *ir.InstSub:
+		return g.binary(inst, inst.X, token.SUB, inst.Y)
+	case *ir.InstMul:
+		return g.binary(inst, inst.X, token.MUL, inst.Y)
+	case *ir.InstUDiv:
+		return g.unsigned(inst, inst.X, token.QUO, inst.Y)
+	case *ir.InstSDiv:
+		return g.binary(inst, inst.X, token.QUO, inst.Y)
+	case *ir.InstURem:
+		return g.unsigned(inst, inst.X, token.REM, inst.Y)
+	case *ir.InstSRem:
+		return g.binary(inst, inst.X, token.REM, inst.Y)
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return g.shift(inst, inst.X, token.SHL, inst.Y)
+	case *ir.InstLShr:
+		return g.unsigned(inst, inst.X, token.SHR, inst.Y)
+	case *ir.InstAShr:
+		return g.shift(inst, inst.X, token.SHR, inst.Y)
+	case *ir.InstAnd:
+		return g.bitwise(inst, inst.X, token.AND, token.LAND, inst.Y)
+	case *ir.InstOr:
+		return g.bitwise(inst, inst.X, token.OR, token.LOR, inst.Y)
+	case *ir.InstXor:
+		return g.bitwise(inst, inst.X, token.XOR, token.NEQ, inst.Y)
+	// Memory instructions.
+	case *ir.InstAlloca:
+		if inst.NElems != nil {
+			g.errorf("support for alloca instruction with number of elements not yet implemented")
+			return nil
+		}
+		typ, err := goType(inst.ElemType)
+		if err != nil {
+			g.fail(err)
+			return nil
+		}
+		return g.assign(inst, call(goast.NewIdent("new"), typ))
+	case *ir.InstLoad:
+		return g.assign(inst, &goast.StarExpr{X: g.value(inst.Src)})
+	case *ir.InstStore:
+		return []goast.Stmt{assign(&goast.StarExpr{X: g.value(inst.Dst)}, g.value(inst.Src))}
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		return g.trunc(inst)
+	case *ir.InstZExt:
+		return g.ext(inst, inst.From, inst.To, false)
+	case *ir.InstSExt:
+		return g.ext(inst, inst.From, inst.To, true)
+	// Other instructions.
+	case *ir.InstICmp:
+		return g.icmp(inst)
+	case *ir.InstPhi:
+		return g.assign(inst, g.phiVar(inst))
+	case *ir.InstSelect:
+		return []goast.Stmt{&goast.IfStmt{
+			Cond: g.value(inst.Cond),
+			Body: &goast.BlockStmt{List: g.assign(inst, g.value(inst.X))},
+			Else: &goast.BlockStmt{List: g.assign(inst, g.value(inst.Y))},
+		}}
+	case *ir.InstCall:
+		var args []goast.Expr
+		for _, arg := range inst.Args {
+			args = append(args, g.value(arg))
+		}
+		x := &goast.CallExpr{Fun: g.value(inst.Callee), Args: args}
+		if _, ok := inst.Type().(*types.VoidType); ok {
+			return []goast.Stmt{&goast.ExprStmt{X: x}}
+		}
+		return g.assign(inst, x)
+	default:
+		g.errorf("support for instruction %T not yet implemented", inst)
+		return nil
+	}
+}
+
+// dest returns the Go identifier of the given local variable, as the
+// destination of an assignment.
+func (g *generator) dest(v value.Value) goast.Expr {
+	g.def(v)
+	return goast.NewIdent(goIdent(v.Ident()))
+}
+
+// assign returns the assignment of the given expression to the given local
+// variable.
+func (g *generator) assign(v value.Value, x goast.Expr) []goast.Stmt {
+	return []goast.Stmt{assign(g.dest(v), x)}
+}
+
+// binary returns the assignment of the binary operation on x and y, of integer
+// type, to the given local variable.
+func (g *generator) binary(v value.Value, x value.Value, op token.Token, y value.Value) []goast.Stmt {
+	if !g.isInt(x) {
+		return nil
+	}
+	if isBool(x.Type()) {
+		g.errorf("support for arithmetic on operands of type %v not yet implemented", x.Type())
+		return nil
+	}
+	return g.assign(v, binary(g.value(x), op, g.value(y)))
+}
+
+// unsigned returns the assignment of the binary operation on x and y, with
+// operands converted to unsigned integer type, to the given local variable.
+func (g *generator) unsigned(v value.Value, x value.Value, op token.Token, y value.Value) []goast.Stmt {
+	if !g.isInt(x) {
+		return nil
+	}
+	typ, err := goType(v.Type())
+	if err != nil {
+		g.fail(err)
+		return nil
+	}
+	return g.assign(v, call(typ, binary(g.unsignedValue(x), op, g.unsignedValue(y))))
+}
+
+// shift returns the assignment of the shift operation on x by y bits to the
+// given local variable. The shift count is converted to unsigned integer type.
+func (g *generator) shift(v value.Value, x value.Value, op token.Token, y value.Value) []goast.Stmt {
+	if !g.isInt(x) {
+		return nil
+	}
+	return g.assign(v, binary(g.value(x), op, g.unsignedValue(y)))
+}
+
+// bitwise returns the assignment of the bitwise operation on x and y to the
+// given local variable; where boolOp is the corresponding operation on
+// booleans, used for operands of 1-bit integer type.
+func (g *generator) bitwise(v value.Value, x value.Value, op, boolOp token.Token, y value.Value) []goast.Stmt {
+	if isBool(x.Type()) {
+		return g.assign(v, binary(g.value(x), boolOp, g.value(y)))
+	}
+	return g.binary(v, x, op, y)
+}
+
+// trunc returns the Go statements of the given trunc instruction. Truncation to
+// 1-bit integer type tests the least significant bit.
+func (g *generator) trunc(inst *ir.InstTrunc) []goast.Stmt {
+	if !g.isInt(inst.From) {
+		return nil
+	}
+	if isBool(inst.To) {
+		lsb := binary(g.value(inst.From), token.AND, intLit("1"))
+		return g.assign(inst, binary(lsb, token.NEQ, intLit("0")))
+	}
+	return g.convert(inst, inst.From, inst.To, false)
+}
+
+// ext returns the Go statements of the zero or sign extension of the given
+// value to the specified type. Extension of 1-bit integer values (i.e. bool)
+// is translated into conditional assignment of 1 (or -1 if signed) and 0.
+func (g *generator) ext(v, from value.Value, to types.Type, signed bool) []goast.Stmt {
+	if isBool(from.Type()) {
+		one := "1"
+		if signed {
+			one = "-1"
+		}
+		stmts := g.assign(v, intLit("0"))
+		set := &goast.IfStmt{Cond: g.value(from), Body: &goast.BlockStmt{List: g.assign(v, intLit(one))}}
+		return append(stmts, set)
+	}
+	if !g.isInt(from) {
+		return nil
+	}
+	return g.convert(v, from, to, !signed)
+}
+
+// convert returns the assignment of the given value, converted to the
+// specified integer type, to the given local variable. The value is converted
+// to unsigned integer type first if unsigned is set.
+func (g *generator) convert(v, from value.Value, to types.Type, unsigned bool) []goast.Stmt {
+	typ, err := goType(to)
+	if err != nil {
+		g.fail(err)
+		return nil
+	}
+	if c, ok := constInt(from); ok {
+		// Go does not permit conversion of constants not representable by the
+		// target type.
+		x := convConst(c, from.Type().(*types.IntType), unsigned)
+		return g.assign(v, intLit(convConst(x, to.(*types.IntType), false).String()))
+	}
+	x := g.value(from)
+	if unsigned {
+		x = g.unsignedValue(from)
+	}
+	return g.assign(v, call(typ, x))
+}
+
+// icmp returns the Go statements of the given integer comparison instruction.
+func (g *generator) icmp(inst *ir.InstICmp) []goast.Stmt {
+	var op token.Token
+	unsigned := false
+	switch inst.Pred {
+	case enum.IPredEQ:
+		op = token.EQL
+	case enum.IPredNE:
+		op = token.NEQ
+	case enum.IPredSGE:
+		op = token.GEQ
+	case enum.IPredSGT:
+		op = token.GTR
+	case enum.IPredSLE:
+		op = token.LEQ
+	case enum.IPredSLT:
+		op = token.LSS
+	case enum.IPredUGE:
+		op, unsigned = token.GEQ, true
+	case enum.IPredUGT:
+		op, unsigned = token.GTR, true
+	case enum.IPredULE:
+		op, unsigned = token.LEQ, true
+	case enum.IPredULT:
+		op, unsigned = token.LSS, true
+	default:
+		g.errorf("support for integer comparison predicate %v not yet implemented", inst.Pred)
+		return nil
+	}
+	if op == token.EQL || op == token.NEQ {
+		// Equality comparisons are valid for booleans and pointers.
+		return g.assign(inst, binary(g.value(inst.X), op, g.value(inst.Y)))
+	}
+	if !g.isInt(inst.X) || isBool(inst.X.Type()) {
+		g.errorf("support for ordered comparison of %v operands not yet implemented", inst.X.Type())
+		return nil
+	}
+	if unsigned {
+		return g.assign(inst, binary(g.unsignedValue(inst.X), op, g.unsignedValue(inst.Y)))
+	}
+	return g.assign(inst, binary(g.value(inst.X), op, g.value(inst.Y)))
+}
+
+// isInt reports whether the given value is of integer type, and records an
+// error otherwise.
+func (g *generator) isInt(v value.Value) bool {
+	if _, ok := v.Type().(*types.IntType); !ok {
+		g.errorf("support for operands of type %v not yet implemented", v.Type())
+		return false
+	}
+	return true
+}
+
+// binary returns the binary expression of the given operands.
+func binary(x goast.Expr, op token.Token, y goast.Expr) goast.Expr {
+	return &goast.BinaryExpr{X: x, Op: op, Y: y}
+}
+
+// join returns the binary expression of the given operands; or y if x is nil.
+func join(x goast.Expr, op token.Token, y goast.Expr) goast.Expr {
+	if x == nil {
+		return y
+	}
+	return binary(x, op, y)
+}
+
+// call returns the call expression of the given function (or type conversion)
+// and arguments.
+func call(fun goast.Expr, args ...goast.Expr) goast.Expr {
+	return &goast.CallExpr{Fun: fun, Args: args}
+}
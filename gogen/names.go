@@ -0,0 +1,255 @@
+package gogen
+
+import (
+	"fmt"
+	goast "go/ast"
+	"go/token"
+	gotypes "go/types"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/types"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/cond"
+	"github.com/mewmew/pi/internal/backend"
+	"github.com/pkg/errors"
+)
+
+// === [ Values ] ==============================================================
+
+// value returns the Go expression of the given value.
+func (g *generator) value(v value.Value) goast.Expr {
+	switch v := v.(type) {
+	case *constant.Int:
+		if isBool(v.Type()) {
+			return goast.NewIdent(fmt.Sprint(v.X.Sign() != 0))
+		}
+		return intLit(v.X.String())
+	case *constant.Null:
+		return goast.NewIdent("nil")
+	case *ir.Global:
+		g.ref(v)
+		return goast.NewIdent(goIdent(v.Ident()))
+	case *ir.Func:
+		g.ref(v)
+		return goast.NewIdent(goIdent(v.Ident()))
+	case value.Named:
+		return goast.NewIdent(goIdent(v.Ident()))
+	}
+	g.errorf("support for value %T not yet implemented", v)
+	return goast.NewIdent("nil")
+}
+
+// unsignedValue returns the Go expression of the given value of integer type,
+// converted to unsigned integer type.
+func (g *generator) unsignedValue(v value.Value) goast.Expr {
+	t, ok := v.Type().(*types.IntType)
+	if !ok || t.BitSize == 1 {
+		return g.value(v)
+	}
+	if c, ok := constInt(v); ok {
+		// Go does not permit conversion of negative constants to unsigned
+		// integer types.
+		return intLit(convConst(c, t, true).String())
+	}
+	typ, err := unsignedType(t)
+	if err != nil {
+		g.fail(err)
+		return g.value(v)
+	}
+	return call(typ, g.value(v))
+}
+
+// constInt returns the integer value of the given integer constant. The boolean
+// return value indicates success.
+func constInt(v value.Value) (*big.Int, bool) {
+	if c, ok := v.(*constant.Int); ok {
+		return c.X, true
+	}
+	return nil, false
+}
+
+// convConst returns the given integer constant, represented in two's
+// complement using the bit size of the specified integer type, as an unsigned
+// or signed integer.
+func convConst(x *big.Int, t *types.IntType, unsigned bool) *big.Int {
+	mod := new(big.Int).Lsh(big.NewInt(1), uint(t.BitSize))
+	y := new(big.Int).Mod(x, mod)
+	if !unsigned && y.Bit(int(t.BitSize)-1) == 1 {
+		y.Sub(y, mod)
+	}
+	return y
+}
+
+// intLit returns the Go expression of the given integer literal.
+func intLit(s string) goast.Expr {
+	if strings.HasPrefix(s, "-") {
+		return &goast.UnaryExpr{Op: token.SUB, X: intLit(s[1:])}
+	}
+	return &goast.BasicLit{Kind: token.INT, Value: s}
+}
+
+// operand returns the Go expression of the given operand of a condition or
+// case value; i.e. constants are kept as is, and variables are renamed to Go
+// identifiers.
+func operand(s string) goast.Expr {
+	switch {
+	case s == "true" || s == "false":
+		return goast.NewIdent(s)
+	case s == "null":
+		return goast.NewIdent("nil")
+	case len(s) > 0 && (s[0] == '-' || unicode.IsDigit(rune(s[0]))):
+		return intLit(s)
+	}
+	return goast.NewIdent(goIdent(s))
+}
+
+// === [ Conditions ] ==========================================================
+
+// condExpr returns the Go expression of the given condition.
+func condExpr(x cond.Expr) goast.Expr {
+	switch x := x.(type) {
+	case cond.Const:
+		return goast.NewIdent(x.String())
+	case *cond.Var:
+		return goast.NewIdent(goIdent(x.Name))
+	case *cond.Compare:
+		return binary(goast.NewIdent(goIdent(x.X)), cmpOps[x.Op], operand(x.Y))
+	case *cond.Not:
+		y := condExpr(x.X)
+		switch x.X.(type) {
+		case cond.Const, *cond.Var:
+		default:
+			y = &goast.ParenExpr{X: y}
+		}
+		return &goast.UnaryExpr{Op: token.NOT, X: y}
+	case *cond.And:
+		if len(x.Xs) == 0 {
+			return goast.NewIdent("true")
+		}
+		var y goast.Expr
+		for _, xx := range x.Xs {
+			z := condExpr(xx)
+			if _, ok := xx.(*cond.Or); ok {
+				z = &goast.ParenExpr{X: z}
+			}
+			y = join(y, token.LAND, z)
+		}
+		return y
+	case *cond.Or:
+		if len(x.Xs) == 0 {
+			return goast.NewIdent("false")
+		}
+		var y goast.Expr
+		for _, xx := range x.Xs {
+			z := condExpr(xx)
+			if _, ok := xx.(*cond.And); ok {
+				z = &goast.ParenExpr{X: z}
+			}
+			y = join(y, token.LOR, z)
+		}
+		return y
+	}
+	panic(fmt.Errorf("support for condition %T not yet implemented", x))
+}
+
+// cmpOps maps from comparison operator to Go token.
+var cmpOps = map[cond.CmpOp]token.Token{
+	cond.CmpEq: token.EQL,
+	cond.CmpNe: token.NEQ,
+	cond.CmpLt: token.LSS,
+	cond.CmpLe: token.LEQ,
+	cond.CmpGt: token.GTR,
+	cond.CmpGe: token.GEQ,
+}
+
+// === [ Identifiers ] =========================================================
+
+// goIdent returns the Go identifier of the given LLVM IR identifier (e.g. "foo"
+// for "%foo", "_1" for "%1" and "foo_bar" for `%"foo.bar"`). Identifiers
+// clashing with Go keywords or predeclared identifiers are suffixed by an
+// underscore (e.g. "len_" for "%len").
+func goIdent(ident string) string {
+	s := backend.Ident(ident)
+	if s == "_" || token.Lookup(s).IsKeyword() || gotypes.Universe.Lookup(s) != nil {
+		return s + "_"
+	}
+	return s
+}
+
+// === [ Types ] ===============================================================
+
+// goType returns the Go type of the given LLVM IR type.
+func goType(t types.Type) (goast.Expr, error) {
+	switch t := t.(type) {
+	case *types.IntType:
+		if t.BitSize == 1 {
+			return goast.NewIdent("bool"), nil
+		}
+		if !isGoIntSize(t.BitSize) {
+			return nil, errors.Errorf("support for integer type %v not yet implemented", t)
+		}
+		return goast.NewIdent(fmt.Sprintf("int%d", t.BitSize)), nil
+	case *types.PointerType:
+		elem, err := goType(t.ElemType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &goast.StarExpr{X: elem}, nil
+	}
+	return nil, errors.Errorf("support for type %v not yet implemented", t)
+}
+
+// funcType returns the Go function type of the given LLVM IR function type,
+// with unnamed parameters.
+func funcType(sig *types.FuncType) (*goast.FuncType, error) {
+	if sig.Variadic {
+		return nil, errors.Errorf("support for variadic function type %v not yet implemented", sig)
+	}
+	params := &goast.FieldList{}
+	for _, param := range sig.Params {
+		typ, err := goType(param)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		params.List = append(params.List, &goast.Field{Type: typ})
+	}
+	t := &goast.FuncType{Params: params}
+	if _, ok := sig.RetType.(*types.VoidType); !ok {
+		typ, err := goType(sig.RetType)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		t.Results = &goast.FieldList{List: []*goast.Field{{Type: typ}}}
+	}
+	return t, nil
+}
+
+// unsignedType returns the unsigned Go integer type of the given LLVM IR
+// integer type.
+func unsignedType(t *types.IntType) (goast.Expr, error) {
+	if !isGoIntSize(t.BitSize) {
+		return nil, errors.Errorf("support for integer type %v not yet implemented", t)
+	}
+	return goast.NewIdent(fmt.Sprintf("uint%d", t.BitSize)), nil
+}
+
+// isGoIntSize reports whether the given bit size is the size of a Go integer
+// type.
+func isGoIntSize(size uint64) bool {
+	switch size {
+	case 8, 16, 32, 64:
+		return true
+	}
+	return false
+}
+
+// isBool reports whether the given type is the 1-bit integer type, which is
+// mapped to bool.
+func isBool(t types.Type) bool {
+	i, ok := t.(*types.IntType)
+	return ok && i.BitSize == 1
+}
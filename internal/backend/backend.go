@@ -0,0 +1,360 @@
+// Package backend implements the lowering of LLVM IR functions shared by the
+// back ends (see packages cgen and gogen); i.e. locating the basic blocks of
+// abstract syntax tree nodes, omitting comparisons evaluated by branching
+// conditions, lowering phi instructions to copies, keeping track of the loops
+// exited by break statements, and translating LLVM IR identifiers.
+package backend
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/value"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/pkg/errors"
+)
+
+// === [ Basic blocks ] ========================================================
+
+// Func is an LLVM IR function being lowered.
+type Func struct {
+	*ir.Func
+	// blocks maps from basic block name to basic block.
+	blocks map[string]*ir.Block
+	// resolved records the comparisons evaluated by branching conditions.
+	resolved map[*ir.InstICmp]bool
+}
+
+// NewFunc returns a new LLVM IR function being lowered.
+func NewFunc(f *ir.Func) (*Func, error) {
+	// Force generate local IDs.
+	if err := f.AssignIDs(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fn := &Func{
+		Func:     f,
+		blocks:   make(map[string]*ir.Block),
+		resolved: resolvedICmps(f),
+	}
+	for _, block := range f.Blocks {
+		fn.blocks[localName(block)] = block
+	}
+	return fn, nil
+}
+
+// Block returns the basic block of the given node. Nodes split by
+// cfg.MakeReducibleBySplitting (e.g. "B3.1") are mapped to the basic block of
+// the original node. The boolean return value indicates success.
+func (f *Func) Block(n *ast.Block) (*ir.Block, bool) {
+	if n.Node == nil {
+		return nil, false
+	}
+	name := n.Node.DOTID()
+	for {
+		if block, ok := f.blocks[name]; ok {
+			return block, true
+		}
+		pos := strings.LastIndex(name, ".")
+		if pos == -1 {
+			return nil, false
+		}
+		name = name[:pos]
+	}
+}
+
+// === [ Comparisons ] =========================================================
+
+// Resolved reports whether the given instruction is the comparison of a
+// conditional branch resolved into the compared values by the branching
+// condition of its basic block (see cfg.ResolvedICmp), and not used by any
+// other instruction. Such comparisons are omitted by the back ends, as they
+// are evaluated by the branching conditions of the abstract syntax tree (e.g.
+// `if x == 1` in place of `c = x == 1; if c`).
+func (f *Func) Resolved(inst ir.Instruction) bool {
+	icmp, ok := inst.(*ir.InstICmp)
+	return ok && f.resolved[icmp]
+}
+
+// resolvedICmps returns the comparisons of the given function resolved into
+// branching conditions and not used by any other instruction.
+func resolvedICmps(f *ir.Func) map[*ir.InstICmp]bool {
+	uses := make(map[value.Value]int)
+	for _, block := range f.Blocks {
+		for _, inst := range block.Insts {
+			ops, ok := operands(inst)
+			if !ok {
+				// Uses unknown; keep all comparisons.
+				return nil
+			}
+			for _, op := range ops {
+				uses[op]++
+			}
+		}
+		ops, ok := operands(block.Term)
+		if !ok {
+			return nil
+		}
+		for _, op := range ops {
+			uses[op]++
+		}
+	}
+	resolved := make(map[*ir.InstICmp]bool)
+	for _, block := range f.Blocks {
+		if icmp, ok := cfg.ResolvedICmp(block); ok && uses[icmp] == 1 {
+			resolved[icmp] = true
+		}
+	}
+	return resolved
+}
+
+// operands returns the operands of the given instruction or terminator. The
+// boolean return value indicates whether the operands of the instruction are
+// known; i.e. whether the instruction is supported by the back ends.
+func operands(inst interface{}) ([]value.Value, bool) {
+	switch inst := inst.(type) {
+	// Binary instructions.
+	case *ir.InstAdd:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFAdd:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstSub:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFSub:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstMul:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFMul:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstUDiv:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstSDiv:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFDiv:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstURem:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstSRem:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFRem:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFNeg:
+		return []value.Value{inst.X}, true
+	// Bitwise instructions.
+	case *ir.InstShl:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstLShr:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstAShr:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstAnd:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstOr:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstXor:
+		return []value.Value{inst.X, inst.Y}, true
+	// Memory instructions.
+	case *ir.InstAlloca:
+		if inst.NElems == nil {
+			return nil, true
+		}
+		return []value.Value{inst.NElems}, true
+	case *ir.InstLoad:
+		return []value.Value{inst.Src}, true
+	case *ir.InstStore:
+		return []value.Value{inst.Src, inst.Dst}, true
+	case *ir.InstGetElementPtr:
+		return append([]value.Value{inst.Src}, inst.Indices...), true
+	// Conversion instructions.
+	case *ir.InstTrunc:
+		return []value.Value{inst.From}, true
+	case *ir.InstZExt:
+		return []value.Value{inst.From}, true
+	case *ir.InstSExt:
+		return []value.Value{inst.From}, true
+	case *ir.InstFPTrunc:
+		return []value.Value{inst.From}, true
+	case *ir.InstFPExt:
+		return []value.Value{inst.From}, true
+	case *ir.InstFPToUI:
+		return []value.Value{inst.From}, true
+	case *ir.InstFPToSI:
+		return []value.Value{inst.From}, true
+	case *ir.InstUIToFP:
+		return []value.Value{inst.From}, true
+	case *ir.InstSIToFP:
+		return []value.Value{inst.From}, true
+	case *ir.InstPtrToInt:
+		return []value.Value{inst.From}, true
+	case *ir.InstIntToPtr:
+		return []value.Value{inst.From}, true
+	case *ir.InstBitCast:
+		return []value.Value{inst.From}, true
+	case *ir.InstAddrSpaceCast:
+		return []value.Value{inst.From}, true
+	// Other instructions.
+	case *ir.InstICmp:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstFCmp:
+		return []value.Value{inst.X, inst.Y}, true
+	case *ir.InstPhi:
+		var ops []value.Value
+		for _, inc := range inst.Incs {
+			ops = append(ops, inc.X)
+		}
+		return ops, true
+	case *ir.InstSelect:
+		return []value.Value{inst.Cond, inst.X, inst.Y}, true
+	case *ir.InstCall:
+		return append([]value.Value{inst.Callee}, inst.Args...), true
+	// Terminators.
+	case *ir.TermRet:
+		if inst.X == nil {
+			return nil, true
+		}
+		return []value.Value{inst.X}, true
+	case *ir.TermBr, *ir.TermUnreachable:
+		return nil, true
+	case *ir.TermCondBr:
+		return []value.Value{inst.Cond}, true
+	case *ir.TermSwitch:
+		return []value.Value{inst.X}, true
+	case *ir.TermIndirectBr:
+		return []value.Value{inst.Addr}, true
+	}
+	return nil, false
+}
+
+// === [ Phi instructions ] ====================================================
+
+// Copy is the copy of an incoming value to the temporary variable of a phi
+// instruction.
+type Copy struct {
+	// Phi instruction.
+	Phi *ir.InstPhi
+	// Incoming value.
+	X value.Value
+}
+
+// PhiCopies returns the copies of the incoming values from the given basic
+// block to the phi instructions of its successors.
+//
+// Copies are assigned to the temporary variables of the phi instructions (see
+// PhiVar), which are copied to the phi instructions at the start of their basic
+// blocks. Thus, the copies of a basic block are independent of each other (e.g.
+// swapping the values of two phi instructions), and leave the values read by
+// the branching conditions of the basic block unchanged. As the temporary
+// variables are only read by the successor reached, the copies are not guarded
+// by the branching conditions of the basic block.
+func (f *Func) PhiCopies(block *ir.Block) []Copy {
+	pred := localName(block)
+	var copies []Copy
+	for _, succ := range f.Blocks {
+		for _, inst := range succ.Insts {
+			phi, ok := inst.(*ir.InstPhi)
+			if !ok {
+				continue
+			}
+			for _, inc := range phi.Incs {
+				if localName(inc.Pred) == pred {
+					copies = append(copies, Copy{Phi: phi, X: inc.X})
+					break
+				}
+			}
+		}
+	}
+	return copies
+}
+
+// PhiVar returns the identifier of the temporary variable of the given phi
+// instruction (e.g. "x_phi" for "%x"), assigned the incoming values of the phi
+// instruction in its predecessor basic blocks.
+func PhiVar(phi *ir.InstPhi) string {
+	return Ident(phi.Ident()) + "_phi"
+}
+
+// === [ Loops ] ===============================================================
+
+// Loops keeps track of the loop statements enclosing the statement being
+// lowered.
+type Loops struct {
+	// Enclosing loops; innermost loop last.
+	loops []*Loop
+	// Number of loops entered so far; used for loop IDs.
+	n int
+}
+
+// Loop is a loop statement being lowered.
+type Loop struct {
+	// Loop ID; unique within the function.
+	ID int
+	// Labelled specifies whether the loop is exited by break statements within
+	// switch statements, and thus requires a label (e.g. "loop1").
+	Labelled bool
+	// Number of switch statements enclosing the current statement within the
+	// loop.
+	nswitches int
+}
+
+// Enter enters a new loop statement, and returns it.
+func (ls *Loops) Enter() *Loop {
+	ls.n++
+	l := &Loop{ID: ls.n}
+	ls.loops = append(ls.loops, l)
+	return l
+}
+
+// Leave leaves the innermost loop statement.
+func (ls *Loops) Leave() {
+	ls.loops = ls.loops[:len(ls.loops)-1]
+}
+
+// EnterSwitch enters a switch statement within the innermost loop statement,
+// and returns a function leaving the switch statement.
+func (ls *Loops) EnterSwitch() (leave func()) {
+	if len(ls.loops) == 0 {
+		return func() {}
+	}
+	l := ls.loops[len(ls.loops)-1]
+	l.nswitches++
+	return func() { l.nswitches-- }
+}
+
+// Break returns the innermost loop statement exited by a break statement, if
+// the break statement is within a switch statement of the loop, and thus
+// requires the label of the loop; or nil otherwise, as unlabelled break
+// statements exit the innermost loop. The loop returned is marked as labelled.
+func (ls *Loops) Break() *Loop {
+	if len(ls.loops) == 0 || ls.loops[len(ls.loops)-1].nswitches == 0 {
+		return nil
+	}
+	l := ls.loops[len(ls.loops)-1]
+	l.Labelled = true
+	return l
+}
+
+// === [ Identifiers ] =========================================================
+
+// Ident returns the C-style identifier of the given LLVM IR identifier (e.g.
+// "foo" for "%foo", "_1" for "%1" and "foo_bar" for `%"foo.bar"`).
+func Ident(ident string) string {
+	s := strings.TrimLeft(ident, "%@")
+	s = strings.Trim(s, `"`)
+	ident = strings.Map(func(r rune) rune {
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, s)
+	if len(ident) == 0 || unicode.IsDigit(rune(ident[0])) {
+		return "_" + ident
+	}
+	return ident
+}
+
+// localName returns the identifier (without '%' prefix) of the given local
+// value, as used for node names by cfg.NewGraphFromFunc.
+func localName(v value.Value) string {
+	return strings.TrimPrefix(v.Ident(), "%")
+}
@@ -0,0 +1,171 @@
+package backend
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/internal/backend/backendtest"
+)
+
+func TestFuncBlock(t *testing.T) {
+	golden := []struct {
+		name string
+		want string
+	}{
+		{name: "body", want: "body"},
+		// Node split by cfg.MakeReducibleBySplitting.
+		{name: "latch.1", want: "latch"},
+		{name: "latch.1.2", want: "latch"},
+		{name: "missing", want: ""},
+		{name: "missing.1", want: ""},
+	}
+	f, err := NewFunc(backendtest.Swap())
+	if err != nil {
+		t.Fatalf("unable to create function; %v", err)
+	}
+	g := cfg.NewGraph()
+	for _, gold := range golden {
+		n := &ast.Block{Node: g.NewNodeWithName(gold.name)}
+		got := ""
+		if block, ok := f.Block(n); ok {
+			got = localName(block)
+		}
+		if got != gold.want {
+			t.Errorf("%q; basic block mismatch; expected %q, got %q", gold.name, gold.want, got)
+		}
+	}
+}
+
+func TestFuncResolved(t *testing.T) {
+	golden := []struct {
+		name string
+		f    *ir.Func
+		want []string
+	}{
+		{name: "chain", f: backendtest.Chain(), want: []string{"is_a", "is_b"}},
+		// Comparisons of two variables are not resolved.
+		{name: "swap", f: backendtest.Swap(), want: nil},
+		// Comparisons used by other instructions are kept.
+		{name: "used", f: usedCmpFunc(), want: nil},
+	}
+	for _, gold := range golden {
+		f, err := NewFunc(gold.f)
+		if err != nil {
+			t.Errorf("%q; unable to create function; %v", gold.name, err)
+			continue
+		}
+		var got []string
+		for _, block := range f.Blocks {
+			for _, inst := range block.Insts {
+				if f.Resolved(inst) {
+					got = append(got, localName(inst.(*ir.InstICmp)))
+				}
+			}
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; resolved comparisons mismatch; expected %q, got %q", gold.name, gold.want, got)
+		}
+	}
+}
+
+func TestFuncPhiCopies(t *testing.T) {
+	golden := []struct {
+		block string
+		want  []string
+	}{
+		{block: "entry", want: []string{"a_phi = %x", "b_phi = %y", "i_phi = 0"}},
+		{block: "body", want: nil},
+		{block: "latch", want: []string{"a_phi = %b", "b_phi = %a", "i_phi = %i.next"}},
+	}
+	f, err := NewFunc(backendtest.Swap())
+	if err != nil {
+		t.Fatalf("unable to create function; %v", err)
+	}
+	for _, gold := range golden {
+		block, ok := f.blocks[gold.block]
+		if !ok {
+			t.Errorf("%q; unable to locate basic block", gold.block)
+			continue
+		}
+		var got []string
+		for _, c := range f.PhiCopies(block) {
+			got = append(got, fmt.Sprintf("%s = %s", PhiVar(c.Phi), c.X.Ident()))
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; copies mismatch; expected %q, got %q", gold.block, gold.want, got)
+		}
+	}
+}
+
+func TestLoopsBreak(t *testing.T) {
+	ls := &Loops{}
+	if l := ls.Break(); l != nil {
+		t.Errorf("unexpected loop %d of break statement outside of loop", l.ID)
+	}
+	outer := ls.Enter()
+	leave := ls.EnterSwitch()
+	inner := ls.Enter()
+	// Unlabelled break statements exit the inner loop.
+	if l := ls.Break(); l != nil {
+		t.Errorf("unexpected loop %d of break statement in inner loop", l.ID)
+	}
+	ls.Leave()
+	// Break statements within switch statements refer to the label of the loop.
+	if l := ls.Break(); l != outer {
+		t.Errorf("loop mismatch of break statement in switch statement; expected %v, got %v", outer, l)
+	}
+	leave()
+	if l := ls.Break(); l != nil {
+		t.Errorf("unexpected loop %d of break statement in outer loop", l.ID)
+	}
+	ls.Leave()
+	if inner.ID != 2 || inner.Labelled {
+		t.Errorf("inner loop mismatch; expected ID 2 unlabelled, got ID %d labelled %v", inner.ID, inner.Labelled)
+	}
+	if outer.ID != 1 || !outer.Labelled {
+		t.Errorf("outer loop mismatch; expected ID 1 labelled, got ID %d labelled %v", outer.ID, outer.Labelled)
+	}
+}
+
+func TestIdent(t *testing.T) {
+	golden := []struct {
+		in   string
+		want string
+	}{
+		{in: "%foo", want: "foo"},
+		{in: "@foo", want: "foo"},
+		{in: "%1", want: "_1"},
+		{in: `%"foo.bar"`, want: "foo_bar"},
+		{in: "%s.next", want: "s_next"},
+		{in: `%""`, want: "_"},
+	}
+	for _, gold := range golden {
+		got := Ident(gold.in)
+		if got != gold.want {
+			t.Errorf("%q; identifier mismatch; expected %q, got %q", gold.in, gold.want, got)
+		}
+	}
+}
+
+// usedCmpFunc returns a function of a conditional branch, whose comparison is
+// used by the return value of a successor basic block.
+func usedCmpFunc() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	c := entry.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 1))
+	c.SetName("c")
+	entry.NewCondBr(c, a, b)
+	a.NewRet(a.NewZExt(c, types.I32))
+	b.NewRet(constant.NewInt(types.I32, 0))
+	return f
+}
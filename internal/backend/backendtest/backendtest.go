@@ -0,0 +1,207 @@
+// Package backendtest provides the LLVM IR functions used to test the back
+// ends (see packages cgen and gogen).
+package backendtest
+
+import (
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/structure"
+	"github.com/pkg/errors"
+)
+
+// Structure returns the abstract syntax tree of the structured control flow
+// graph of the given function.
+func Structure(f *ir.Func) (ast.Node, error) {
+	g, err := cfg.NewGraphFromFunc(f)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	prim, err := structure.Structure(g)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return prim, nil
+}
+
+// IfElse returns a function of a 2-way conditional, joined by a phi
+// instruction.
+func IfElse() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("f", types.I32, x)
+	entry := f.NewBlock("entry")
+	then := f.NewBlock("then")
+	els := f.NewBlock("else")
+	exit := f.NewBlock("exit")
+	cond := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 10))
+	cond.SetName("cond")
+	entry.NewCondBr(cond, then, els)
+	y := then.NewAdd(x, constant.NewInt(types.I32, 1))
+	y.SetName("y")
+	then.NewBr(exit)
+	z := els.NewSub(x, constant.NewInt(types.I32, 1))
+	z.SetName("z")
+	els.NewBr(exit)
+	r := exit.NewPhi(ir.NewIncoming(y, then), ir.NewIncoming(z, els))
+	r.SetName("r")
+	exit.NewRet(r)
+	return f
+}
+
+// If returns a function of a 2-way conditional without else-branch, where the
+// phi instruction of the join basic block has an incoming value from the
+// conditional.
+func If() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("abs", types.I32, x)
+	entry := f.NewBlock("entry")
+	then := f.NewBlock("then")
+	exit := f.NewBlock("exit")
+	neg := entry.NewICmp(enum.IPredSLT, x, constant.NewInt(types.I32, 0))
+	neg.SetName("neg")
+	entry.NewCondBr(neg, then, exit)
+	y := then.NewSub(constant.NewInt(types.I32, 0), x)
+	y.SetName("y")
+	then.NewBr(exit)
+	r := exit.NewPhi(ir.NewIncoming(x, entry), ir.NewIncoming(y, then))
+	r.SetName("r")
+	exit.NewRet(r)
+	return f
+}
+
+// Loop returns a function summing the integers below n in a pre-test loop.
+func Loop() *ir.Func {
+	n := ir.NewParam("n", types.I32)
+	f := ir.NewFunc("sum", types.I32, n)
+	entry := f.NewBlock("entry")
+	head := f.NewBlock("head")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	entry.NewBr(head)
+	i := head.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	s := head.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	s.SetName("s")
+	cond := head.NewICmp(enum.IPredULT, i, n)
+	cond.SetName("cond")
+	head.NewCondBr(cond, body, exit)
+	sNext := body.NewAdd(s, i)
+	sNext.SetName("s.next")
+	iNext := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	iNext.SetName("i.next")
+	body.NewBr(head)
+	i.Incs = append(i.Incs, ir.NewIncoming(iNext, body))
+	s.Incs = append(s.Incs, ir.NewIncoming(sNext, body))
+	exit.NewRet(s)
+	return f
+}
+
+// ArrayLoop returns a function summing the elements of an array in a pre-test
+// loop.
+func ArrayLoop() *ir.Func {
+	p := ir.NewParam("p", types.NewPointer(types.I32))
+	n := ir.NewParam("n", types.I32)
+	f := ir.NewFunc("sum", types.I32, p, n)
+	entry := f.NewBlock("entry")
+	head := f.NewBlock("head")
+	body := f.NewBlock("body")
+	exit := f.NewBlock("exit")
+	entry.NewBr(head)
+	i := head.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	s := head.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	s.SetName("s")
+	cond := head.NewICmp(enum.IPredULT, i, n)
+	cond.SetName("cond")
+	head.NewCondBr(cond, body, exit)
+	elem := body.NewGetElementPtr(p, i)
+	elem.SetName("elem")
+	v := body.NewLoad(elem)
+	v.SetName("v")
+	sNext := body.NewAdd(s, v)
+	sNext.SetName("s.next")
+	iNext := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	iNext.SetName("i.next")
+	body.NewBr(head)
+	i.Incs = append(i.Incs, ir.NewIncoming(iNext, body))
+	s.Incs = append(s.Incs, ir.NewIncoming(sNext, body))
+	exit.NewRet(s)
+	return f
+}
+
+// Swap returns a function swapping the values of two phi instructions in each
+// iteration of a post-test loop, where the loop condition of the latch basic
+// block compares the value of a phi instruction before its update.
+func Swap() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	y := ir.NewParam("y", types.I32)
+	n := ir.NewParam("n", types.I32)
+	f := ir.NewFunc("swap", types.I32, x, y, n)
+	entry := f.NewBlock("entry")
+	body := f.NewBlock("body")
+	latch := f.NewBlock("latch")
+	exit := f.NewBlock("exit")
+	entry.NewBr(body)
+	a := body.NewPhi(ir.NewIncoming(x, entry))
+	a.SetName("a")
+	b := body.NewPhi(ir.NewIncoming(y, entry), ir.NewIncoming(a, latch))
+	b.SetName("b")
+	i := body.NewPhi(ir.NewIncoming(constant.NewInt(types.I32, 0), entry))
+	i.SetName("i")
+	a.Incs = append(a.Incs, ir.NewIncoming(b, latch))
+	iNext := body.NewAdd(i, constant.NewInt(types.I32, 1))
+	iNext.SetName("i.next")
+	body.NewBr(latch)
+	i.Incs = append(i.Incs, ir.NewIncoming(iNext, latch))
+	cond := latch.NewICmp(enum.IPredNE, i, n)
+	cond.SetName("cond")
+	latch.NewCondBr(cond, body, exit)
+	exit.NewRet(a)
+	return f
+}
+
+// Switch returns a function of a switch statement, with two cases sharing a
+// target basic block.
+func Switch() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("g", types.I32, x)
+	entry := f.NewBlock("entry")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	def := f.NewBlock("default")
+	entry.NewSwitch(x, def,
+		ir.NewCase(constant.NewInt(types.I32, 1), a),
+		ir.NewCase(constant.NewInt(types.I32, 2), b),
+		ir.NewCase(constant.NewInt(types.I32, 3), b),
+	)
+	a.NewRet(constant.NewInt(types.I32, 10))
+	b.NewRet(constant.NewInt(types.I32, 20))
+	def.NewRet(constant.NewInt(types.I32, 0))
+	return f
+}
+
+// Chain returns a function of a chain of conditional branches comparing a
+// common variable against constants, as produced by compilers lowering switch
+// statements.
+func Chain() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("h", types.I32, x)
+	entry := f.NewBlock("entry")
+	next := f.NewBlock("next")
+	a := f.NewBlock("a")
+	b := f.NewBlock("b")
+	def := f.NewBlock("default")
+	isA := entry.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 1))
+	isA.SetName("is_a")
+	entry.NewCondBr(isA, a, next)
+	isB := next.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 2))
+	isB.SetName("is_b")
+	next.NewCondBr(isB, b, def)
+	a.NewRet(constant.NewInt(types.I32, 10))
+	b.NewRet(constant.NewInt(types.I32, 20))
+	def.NewRet(constant.NewInt(types.I32, 0))
+	return f
+}
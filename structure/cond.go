@@ -0,0 +1,141 @@
+package structure
+
+import (
+	"strconv"
+
+	"github.com/mewmew/pi/bdd"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+)
+
+// === [ Conditions ] ==========================================================
+
+// Conditions are symbolic Boolean formulas (see package cond), minimized into
+// disjunctive normal form by cond.Minimize; i.e. a disjunction of terms, where
+// each term is a conjunction of literals. A literal is a possibly negated
+// atomic condition (e.g. `%cond`, `!%cond` or `x != 1`).
+
+// terms returns the terms of the given condition in disjunctive normal form.
+func terms(c cond.Expr) []cond.Expr {
+	switch c := c.(type) {
+	case cond.Const:
+		if !c {
+			return nil
+		}
+	case *cond.Or:
+		return c.Xs
+	}
+	return []cond.Expr{c}
+}
+
+// literals returns the conjuncts of the given term.
+func literals(t cond.Expr) []cond.Expr {
+	switch t := t.(type) {
+	case cond.Const:
+		if t {
+			return nil
+		}
+	case *cond.And:
+		return t.Xs
+	}
+	return []cond.Expr{t}
+}
+
+// atomOf returns the atomic condition of the given literal, and reports whether
+// the atomic condition is negated (see cond.Atom). The boolean return value ok
+// is false if the condition is not a literal.
+func atomOf(l cond.Expr) (atom cond.Expr, neg, ok bool) {
+	switch l := l.(type) {
+	case *cond.Var, *cond.Compare:
+		atom, neg = cond.Atom(l)
+		return atom, neg, true
+	case *cond.Not:
+		if v, ok := l.X.(*cond.Var); ok {
+			return v, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// isComplement reports whether the given conditions are complements of each
+// other, as decided by binary decision diagrams.
+func isComplement(a, b cond.Expr) bool {
+	return bdd.NewManager().Complement(a, b)
+}
+
+// isEquivalent reports whether the given conditions are equivalent, as decided
+// by binary decision diagrams.
+func isEquivalent(a, b cond.Expr) bool {
+	return bdd.NewManager().Equivalent(a, b)
+}
+
+// conjuncts returns the literals shared by every term of the condition.
+func conjuncts(c cond.Expr) []cond.Expr {
+	ts := terms(c)
+	if len(ts) == 0 {
+		return nil
+	}
+	var ls []cond.Expr
+	for _, l := range literals(ts[0]) {
+		if _, _, ok := atomOf(l); ok && hasConjunct(c, l) {
+			ls = append(ls, l)
+		}
+	}
+	return ls
+}
+
+// hasConjunct reports whether the given literal is shared by every term of the
+// condition.
+func hasConjunct(c, l cond.Expr) bool {
+	ts := terms(c)
+	if len(ts) == 0 {
+		return false
+	}
+	for _, t := range ts {
+		found := false
+		for _, x := range literals(t) {
+			if x.String() == l.String() {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// without returns the condition with the given literal removed from each term,
+// as minimized by cond.Minimize.
+func without(c, l cond.Expr) cond.Expr {
+	d := &cond.Or{}
+	for _, t := range terms(c) {
+		u := &cond.And{}
+		for _, x := range literals(t) {
+			if x.String() != l.String() {
+				u.Xs = append(u.Xs, x)
+			}
+		}
+		d.Xs = append(d.Xs, u)
+	}
+	return cond.Minimize(d)
+}
+
+// --- [ Edge labels ] ---------------------------------------------------------
+
+// parseLabel parses the given edge label into a condition, as minimized by
+// cond.Minimize.
+//
+// Edge labels use the syntax of the labels produced by cfg.NewGraphFromFunc, as
+// parsed by cond.Parse.
+func parseLabel(label string) (cond.Expr, error) {
+	if s, err := strconv.Unquote(label); err == nil {
+		label = s
+	}
+	x, err := cond.Parse(label)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to parse edge label %q", label)
+	}
+	return cond.Minimize(x), nil
+}
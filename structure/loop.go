@@ -0,0 +1,247 @@
+package structure
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+)
+
+// cyclic restructures the given cyclic region into an endless loop, and
+// collapses it into a single node. Endless loops are subsequently refined into
+// other types of loops by refineLoops.
+//
+// The nodes of the loop are refined by loop membership refinement and loop
+// successor refinement. Loops which still have more than one successor are
+// transformed into loops with a single successor, by assigning an exit selector
+// variable before each exit edge and dispatching on the variable after the
+// loop. Edges leaving the loop are replaced by break statements.
+func (s *structurer) cyclic(dt *cfg.DomTree, loop *cfg.Loop) error {
+	head := loop.Head
+	if entries := loop.Entries(s.g); len(entries) > 0 {
+		e := entries[0]
+		return errors.Errorf("support for loops with multiple entries not yet implemented; entry edge (%q -> %q) to non-header node of loop header %q", e.From().(*cfg.Node).DOTID(), e.To().(*cfg.Node).DOTID(), head.DOTID())
+	}
+	s.refineLoopMembers(dt, loop)
+	s.refineLoopSuccs(dt, loop)
+	if len(loop.Succs(s.g)) > 1 {
+		s.singleSucc(loop)
+		cfg.InitDFSOrder(s.g)
+	}
+	inLoop := make(map[*cfg.Node]bool)
+	for _, n := range loop.Nodes {
+		inLoop[n] = true
+	}
+	region := cfg.SortByRevPost(nodesOf(loop.Nodes))
+	items, err := s.reachingItems(head, region, inLoop)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	items = refineBreaks(items)
+	return s.collapse(region, &ast.Endless{Body: refine(items)})
+}
+
+// refineLoopMembers refines the set of loop nodes to reduce the number of loop
+// successors (loop membership refinement), by extending the loop with
+// successors dominated by the loop header whose predecessors are all loop nodes
+// and which introduce no new loop successors (e.g. early returns).
+func (s *structurer) refineLoopMembers(dt *cfg.DomTree, loop *cfg.Loop) {
+	for {
+		ss := loop.Succs(s.g)
+		if len(ss) <= 1 {
+			return
+		}
+		added := false
+		for _, succ := range cfg.SortByRevPost(nodesOf(ss)) {
+			if !dt.Dominates(loop.Head, succ) || !allIn(preds(s.g, succ), loop) {
+				continue
+			}
+			if s.growsSuccs(loop, ss, []*cfg.Node{succ}) {
+				continue
+			}
+			loop.Add(succ)
+			added = true
+			break
+		}
+		if !added {
+			return
+		}
+	}
+}
+
+// refineLoopSuccs refines the set of loop successors (loop successor
+// refinement), by extending the loop with the acyclic regions dominated by loop
+// successors, whose predecessors are all loop nodes or nodes of the region, and
+// which only leave the region to other loop successors.
+func (s *structurer) refineLoopSuccs(dt *cfg.DomTree, loop *cfg.Loop) {
+	for {
+		ss := loop.Succs(s.g)
+		if len(ss) <= 1 {
+			return
+		}
+		added := false
+		for _, succ := range cfg.SortByRevPost(nodesOf(ss)) {
+			if !dt.Dominates(loop.Head, succ) {
+				continue
+			}
+			region := dominated(dt, succ)
+			inRegion := make(map[*cfg.Node]bool)
+			for _, n := range region {
+				inRegion[n] = true
+			}
+			valid := true
+			for _, n := range region {
+				for _, pred := range preds(s.g, n) {
+					if !loop.Contains(pred) && !inRegion[pred] {
+						valid = false
+					}
+				}
+			}
+			if !valid || s.growsSuccs(loop, ss, region) {
+				continue
+			}
+			for _, n := range region {
+				loop.Add(n)
+			}
+			added = true
+			break
+		}
+		if !added {
+			return
+		}
+	}
+}
+
+// growsSuccs reports whether extending the loop with the given nodes would
+// introduce new loop successors; i.e. successors of the nodes which are neither
+// loop nodes, nodes of the extension, nor present in the given loop successors.
+func (s *structurer) growsSuccs(loop *cfg.Loop, ss, ext []*cfg.Node) bool {
+	inExt := make(map[*cfg.Node]bool)
+	for _, n := range ext {
+		inExt[n] = true
+	}
+	inSuccs := make(map[*cfg.Node]bool)
+	for _, succ := range ss {
+		inSuccs[succ] = true
+	}
+	for _, n := range ext {
+		for _, x := range succs(s.g, n) {
+			if !loop.Contains(x) && !inExt[x] && !inSuccs[x] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// singleSucc transforms the given loop with multiple successors into a loop
+// with a single successor (single-entry single-exit transformation).
+//
+// Each exit edge of the loop is redirected through a new node which assigns the
+// index of the loop successor to an exit selector variable, and continues to a
+// new dispatch node after the loop. The dispatch node branches to each loop
+// successor through a case edge of the value of the exit selector variable,
+// except for the last loop successor which is the default target; e.g.
+//
+//    %B1.exit == 1          // case edge of first loop successor
+//    %B1.exit == 2          // case edge of second loop successor
+//    %B1.exit != 1 && ...   // default edge of last loop successor
+func (s *structurer) singleSucc(loop *cfg.Loop) {
+	head := loop.Head
+	v := fmt.Sprintf("%%%s.exit", head.DOTID())
+	ss := cfg.SortByRevPost(nodesOf(loop.Succs(s.g)))
+	exits := loop.Exits(s.g)
+	dispatch := s.newNode(head.DOTID() + ".exit")
+	s.prims[dispatch.DOTID()] = &ast.Seq{}
+	others := &cond.And{}
+	for i, succ := range ss {
+		value := i + 1
+		for _, e := range exits {
+			if e.To() != succ {
+				continue
+			}
+			from := e.From().(*cfg.Node)
+			assign := s.newNode(fmt.Sprintf("%s.exit%d", head.DOTID(), value))
+			s.prims[assign.DOTID()] = &ast.Assign{Var: v, Value: value}
+			s.g.RemoveEdge(from.ID(), succ.ID())
+			ne := s.g.NewEdge(from, assign).(*cfg.Edge)
+			ne.Kind = e.Kind
+			ne.Values = e.Values
+			for key, val := range e.Attrs {
+				ne.Attrs[key] = val
+			}
+			s.g.SetEdge(ne)
+			ae := s.g.NewEdge(assign, dispatch).(*cfg.Edge)
+			ae.Kind = cfg.EdgeKindUncond
+			s.g.SetEdge(ae)
+			loop.Add(assign)
+		}
+		e := s.g.NewEdge(dispatch, succ).(*cfg.Edge)
+		if i == len(ss)-1 {
+			// The last loop successor is the default target of the dispatch
+			// node.
+			e.Kind = cfg.EdgeKindDefault
+			e.Attrs["label"] = cond.Normalize(others).String()
+			s.g.SetEdge(e)
+			break
+		}
+		x := &cond.Compare{Op: cond.CmpEq, X: v, Y: strconv.Itoa(value)}
+		others.Xs = append(others.Xs, cond.Negate(x))
+		e.Kind = cfg.EdgeKindCase
+		e.Values = []string{strconv.Itoa(value)}
+		e.Attrs["label"] = x.String()
+		s.g.SetEdge(e)
+	}
+}
+
+// newNode adds a new node with the given name to the control flow graph. A
+// numeric suffix is added to the name if already present in the graph.
+func (s *structurer) newNode(name string) *cfg.Node {
+	base := name
+	for i := 1; ; i++ {
+		if _, ok := s.g.NodeWithName(name); !ok {
+			break
+		}
+		name = fmt.Sprintf("%s_%d", base, i)
+	}
+	n := s.g.NewNodeWithName(name)
+	if err := s.g.AddNode(n); err != nil {
+		// unreachable; node name not yet present.
+		panic(err)
+	}
+	return n
+}
+
+// refineBreaks simplifies the reaching conditions of the given loop body nodes
+// based on conditional break statements; the negated break condition holds for
+// every node succeeding a conditional break.
+func refineBreaks(items []item) []item {
+	for i, it := range items {
+		if _, ok := it.node.(*ast.Break); !ok {
+			continue
+		}
+		if _, _, ok := atomOf(it.cond); !ok {
+			continue
+		}
+		l := cond.Negate(it.cond)
+		for j := i + 1; j < len(items); j++ {
+			if hasConjunct(items[j].cond, l) {
+				items[j].cond = without(items[j].cond, l)
+			}
+		}
+	}
+	return items
+}
+
+// allIn reports whether all of the given nodes are part of the loop.
+func allIn(ns []*cfg.Node, loop *cfg.Loop) bool {
+	for _, n := range ns {
+		if !loop.Contains(n) {
+			return false
+		}
+	}
+	return true
+}
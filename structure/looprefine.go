@@ -0,0 +1,199 @@
+package structure
+
+import (
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cond"
+)
+
+// === [ Loop refinement ] =====================================================
+
+// refineLoops returns the abstract syntax tree of the given node with its
+// endless loops refined into while, do-while, nested do-while and for loops, by
+// the loop refinement rules of Yakdan et al. Inner loops are refined before
+// outer loops. The rules are as follows, where c is a condition, S, S1 and S2
+// are sequences of statements, and P and L are basic blocks.
+//
+//    while:           for { if (c) { S1; break }; S2 }
+//                     => while (!c) { S2 }; S1
+//    do-while:        for { S1; if (c) { S2; break } }
+//                     => do { S1 } while (!c); S2
+//    nested do-while: for { S1; if (c) { S2 } }
+//                     => for { do { S1 } while (!c); S2 }
+//    for:             P; while (c) { S; L }
+//                     => for (P; c; L) { S }
+//
+// where S1 of the while rule and S2 of the do-while rule contain no break
+// statements of the loop, and are only moved past the loop if the loop has no
+// other break statements; S1 of the nested do-while rule contains no break
+// statements of the loop; and S of the for rule contains no continue
+// statements of the loop.
+//
+// As the induction variables of loops are not known from the control flow
+// graph, the for rule of Yakdan et al. (`x = a; while (c) { S; x = b }`) is
+// applied to the basic blocks of the loop instead; the init statement is the
+// preheader of the loop (P), i.e. the basic block preceding the loop, and the
+// post statement is the latch of the loop (L), i.e. the basic block ending each
+// iteration.
+func refineLoops(n ast.Node) ast.Node {
+	n = ast.Flatten(ast.Rewrite(n, func(n ast.Node) ast.Node {
+		if l, ok := n.(*ast.Endless); ok {
+			return refineLoop(l)
+		}
+		return n
+	}))
+	return ast.Rewrite(n, func(n ast.Node) ast.Node {
+		if seq, ok := n.(*ast.Seq); ok {
+			return ast.NewSeq(forLoops(seq.Nodes)...)
+		}
+		return n
+	})
+}
+
+// refineLoop refines the given endless loop into a while, do-while or nested
+// do-while loop, if any of the respective loop refinement rules apply. The loop
+// itself is returned otherwise.
+func refineLoop(l *ast.Endless) ast.Node {
+	stmts := stmtsOf(l.Body)
+	if len(stmts) == 0 {
+		return l
+	}
+	// while loop.
+	if c, exit, ok := breakIf(stmts[0]); ok && (len(exit) == 0 || !hasBreak(ast.NewSeq(stmts[1:]...))) {
+		loop := &ast.While{Cond: negate(c), Body: ast.NewSeq(stmts[1:]...)}
+		return ast.NewSeq(append([]ast.Node{loop}, exit...)...)
+	}
+	last := stmts[len(stmts)-1]
+	body := stmts[:len(stmts)-1]
+	// do-while loop.
+	if c, exit, ok := breakIf(last); ok && (len(exit) == 0 || !hasBreak(ast.NewSeq(body...))) {
+		loop := &ast.DoWhile{Body: ast.NewSeq(body...), Cond: negate(c)}
+		return ast.NewSeq(append([]ast.Node{loop}, exit...)...)
+	}
+	// nested do-while loop.
+	if n, ok := last.(*ast.If); ok && len(body) > 0 && !hasBreak(ast.NewSeq(body...)) {
+		inner := &ast.DoWhile{Body: ast.NewSeq(body...), Cond: negate(n.Cond)}
+		return &ast.Endless{Body: ast.NewSeq(inner, n.Then)}
+	}
+	return l
+}
+
+// forLoops returns the given sequence of statements with while loops preceded
+// by their preheader and ending with their latch refined into for loops.
+func forLoops(stmts []ast.Node) []ast.Node {
+	var ns []ast.Node
+	for i := 0; i < len(stmts); i++ {
+		if i+1 < len(stmts) {
+			if loop, ok := forLoop(stmts[i], stmts[i+1]); ok {
+				ns = append(ns, loop)
+				i++
+				continue
+			}
+		}
+		ns = append(ns, stmts[i])
+	}
+	return ns
+}
+
+// forLoop returns the for loop of the given preheader and while loop. The
+// boolean return value indicates success.
+func forLoop(init, n ast.Node) (*ast.For, bool) {
+	if _, ok := init.(*ast.Block); !ok {
+		return nil, false
+	}
+	l, ok := n.(*ast.While)
+	if !ok {
+		return nil, false
+	}
+	stmts := stmtsOf(l.Body)
+	if len(stmts) < 2 {
+		return nil, false
+	}
+	post, ok := stmts[len(stmts)-1].(*ast.Block)
+	if !ok {
+		return nil, false
+	}
+	// Continue statements skip the latch of while loops, but not the post
+	// statement of for loops.
+	body := ast.NewSeq(stmts[:len(stmts)-1]...)
+	if hasContinue(body) {
+		return nil, false
+	}
+	loop := &ast.For{Init: init, Cond: l.Cond, Post: post, Body: body}
+	return loop, true
+}
+
+// breakIf returns the condition and the statements preceding the break
+// statement of the given conditional, if it is a conditional without
+// else-branch, whose target branch ends with a break statement of the loop and
+// contains no other break statements of the loop. The boolean return value
+// indicates success.
+func breakIf(n ast.Node) (string, []ast.Node, bool) {
+	cond, ok := n.(*ast.If)
+	if !ok {
+		return "", nil, false
+	}
+	stmts := stmtsOf(cond.Then)
+	if len(stmts) == 0 {
+		return "", nil, false
+	}
+	if _, ok := stmts[len(stmts)-1].(*ast.Break); !ok {
+		return "", nil, false
+	}
+	exit := stmts[:len(stmts)-1]
+	for _, stmt := range exit {
+		if hasBreak(stmt) {
+			return "", nil, false
+		}
+	}
+	return cond.Cond, exit, true
+}
+
+// hasBreak reports whether the given node contains a break statement of the
+// enclosing loop; i.e. a break statement not nested within another loop.
+func hasBreak(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Break:
+			found = true
+		case *ast.While, *ast.DoWhile, *ast.For, *ast.Endless:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// hasContinue reports whether the given node contains a continue statement of
+// the enclosing loop; i.e. a continue statement not nested within another loop.
+func hasContinue(n ast.Node) bool {
+	found := false
+	ast.Inspect(n, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.Continue:
+			found = true
+		case *ast.While, *ast.DoWhile, *ast.For, *ast.Endless:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// stmtsOf returns the statements of the given node; i.e. the nodes of a
+// sequence, or the node itself.
+func stmtsOf(n ast.Node) []ast.Node {
+	if seq, ok := n.(*ast.Seq); ok {
+		return seq.Nodes
+	}
+	return []ast.Node{n}
+}
+
+// negate returns the negation of the given condition.
+func negate(c string) string {
+	d, err := parseLabel(c)
+	if err != nil {
+		return "!(" + c + ")"
+	}
+	return cond.Minimize(cond.Negate(d)).String()
+}
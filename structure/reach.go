@@ -0,0 +1,101 @@
+package structure
+
+import (
+	"fmt"
+
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+)
+
+// ReachingConds returns the reaching condition of each node of the given
+// single-entry acyclic region, from the region header; i.e. the disjunction
+// over all paths from the header to the node, of the conjunction of the
+// branching conditions of the edges along the path.
+//
+// The reaching condition of the header node is true. Edges to the header node
+// (e.g. back edges of loops) are ignored. The branching conditions of edges are
+// derived from their kinds and labels, as produced by cfg.NewGraphFromFunc.
+// Reaching conditions are minimized, and given in disjunctive normal form.
+//
+// The depth first search order of the nodes of the control flow graph is
+// initialized as a side effect.
+func ReachingConds(g *cfg.Graph, head *cfg.Node, region []*cfg.Node) (map[*cfg.Node]cond.Expr, error) {
+	inRegion := make(map[*cfg.Node]bool)
+	for _, n := range region {
+		inRegion[n] = true
+	}
+	if !inRegion[head] {
+		return nil, errors.Errorf("header node %q not part of region", head.DOTID())
+	}
+	for _, n := range region {
+		if n == head {
+			continue
+		}
+		for _, pred := range preds(g, n) {
+			if !inRegion[pred] {
+				return nil, errors.Errorf("invalid single-entry region with header %q; entry edge (%q -> %q) to non-header node", head.DOTID(), pred.DOTID(), n.DOTID())
+			}
+		}
+	}
+	cfg.InitDFSOrder(g)
+	conds, err := reachingConds(g, head, region)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return conds, nil
+}
+
+// reachingConds returns the reaching condition of each node of the given
+// region from the region header, as minimized by cond.Minimize. Edges to
+// the header and edges from nodes outside of the region are ignored.
+//
+// The depth first search order of the nodes must be initialized.
+func reachingConds(g *cfg.Graph, head *cfg.Node, region []*cfg.Node) (map[*cfg.Node]cond.Expr, error) {
+	inRegion := make(map[*cfg.Node]bool)
+	for _, n := range region {
+		inRegion[n] = true
+	}
+	conds := make(map[*cfg.Node]cond.Expr)
+	for _, n := range cfg.SortByRevPost(nodesOf(region)) {
+		c := &cond.Or{}
+		if n == head {
+			c.Xs = append(c.Xs, cond.True)
+		}
+		for _, pred := range preds(g, n) {
+			if !inRegion[pred] || n == head {
+				continue
+			}
+			if pred.RevPost >= n.RevPost {
+				return nil, errors.Errorf("irreducible region with header %q; retreating edge (%q -> %q)", head.DOTID(), pred.DOTID(), n.DOTID())
+			}
+			ec, err := edgeCond(g, pred, n)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			c.Xs = append(c.Xs, &cond.And{Xs: []cond.Expr{conds[pred], ec}})
+		}
+		conds[n] = cond.Minimize(c)
+	}
+	return conds, nil
+}
+
+// edgeCond returns the branching condition of the edge from the given node to
+// the given successor.
+func edgeCond(g *cfg.Graph, from, to *cfg.Node) (cond.Expr, error) {
+	if g.From(from.ID()).Len() == 1 {
+		return cond.True, nil
+	}
+	e, ok := g.Edge(from.ID(), to.ID()).(*cfg.Edge)
+	if !ok {
+		panic(fmt.Errorf("invalid edge type; expected *cfg.Edge, got %T", g.Edge(from.ID(), to.ID())))
+	}
+	if e.Kind == cfg.EdgeKindUncond {
+		return cond.True, nil
+	}
+	label, ok := e.Attrs["label"]
+	if !ok {
+		return nil, errors.Errorf("unable to locate branching condition of edge (%q -> %q)", from.DOTID(), to.DOTID())
+	}
+	return parseLabel(label)
+}
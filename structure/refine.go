@@ -0,0 +1,122 @@
+package structure
+
+import (
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cond"
+)
+
+// refine returns the abstract syntax tree of the given sequence of nodes
+// guarded by their reaching conditions, as refined by condition-based
+// refinement.
+//
+// Runs of consecutive nodes sharing a common conjunct in their reaching
+// conditions are grouped into a conditional, with the nodes of the subsequent
+// run sharing the negated conjunct forming its else-branch. Consecutive nodes
+// with equivalent reaching conditions are grouped into a conditional, and
+// consecutive nodes with complementary reaching conditions are grouped into an
+// if-else construct; where equivalence and complements are decided regardless
+// of how the conditions are written.
+//
+// Runs of consecutive nodes with reaching conditions comparing a common
+// variable against different constants are grouped into a switch statement
+// (see switchRun), and if-else chains comparing a common variable against
+// constants are merged into switch statements (see switchChain); i.e.
+// condition-aware refinement.
+func refine(items []item) ast.Node {
+	var nodes []ast.Node
+	for i := 0; i < len(items); {
+		it := items[i]
+		if it.cond == cond.True {
+			nodes = append(nodes, it.node)
+			i++
+			continue
+		}
+		// Group runs of nodes comparing a common variable against constants.
+		if sw, n, ok := switchRun(items[i:]); ok {
+			nodes = append(nodes, sw)
+			i += n
+			continue
+		}
+		// Group runs of nodes sharing a common conjunct.
+		var best cond.Expr
+		bestThen, bestEnd := i, i
+		for _, l := range conjuncts(it.cond) {
+			j := i
+			for j < len(items) && hasConjunct(items[j].cond, l) {
+				j++
+			}
+			k := j
+			for k < len(items) && hasConjunct(items[k].cond, cond.Negate(l)) {
+				k++
+			}
+			if k > bestEnd {
+				best, bestThen, bestEnd = l, j, k
+			}
+		}
+		if bestEnd-i > 1 {
+			then := refine(strip(items[i:bestThen], best))
+			if bestEnd > bestThen {
+				els := refine(strip(items[bestThen:bestEnd], cond.Negate(best)))
+				nodes = append(nodes, switchChain(newIfElse(best, then, els)))
+			} else {
+				nodes = append(nodes, &ast.If{Cond: best.String(), Then: then})
+			}
+			i = bestEnd
+			continue
+		}
+		// Group runs of nodes with equivalent reaching conditions, with the nodes
+		// of the subsequent run with the complementary reaching condition forming
+		// the else-branch.
+		j := i + 1
+		for j < len(items) && isEquivalent(it.cond, items[j].cond) {
+			j++
+		}
+		k := j
+		for k < len(items) && isComplement(it.cond, items[k].cond) {
+			k++
+		}
+		if k-i > 1 {
+			c := it.cond.String()
+			if k > j {
+				n := &ast.IfElse{Cond: c, Then: seqOf(items[i:j]), Else: seqOf(items[j:k])}
+				nodes = append(nodes, switchChain(n))
+			} else {
+				nodes = append(nodes, &ast.If{Cond: c, Then: seqOf(items[i:j])})
+			}
+			i = k
+			continue
+		}
+		nodes = append(nodes, &ast.If{Cond: it.cond.String(), Then: it.node})
+		i++
+	}
+	return ast.NewSeq(nodes...)
+}
+
+// seqOf returns the sequence of the nodes of the given items, ignoring their
+// reaching conditions.
+func seqOf(items []item) ast.Node {
+	var nodes []ast.Node
+	for _, it := range items {
+		nodes = append(nodes, it.node)
+	}
+	return ast.NewSeq(nodes...)
+}
+
+// strip returns a copy of the given items with the literal removed from their
+// reaching conditions.
+func strip(items []item, l cond.Expr) []item {
+	var its []item
+	for _, it := range items {
+		its = append(its, item{cond: without(it.cond, l), node: it.node})
+	}
+	return its
+}
+
+// newIfElse returns an if-else construct with the given branching condition and
+// branches, normalized to branch on the positive literal.
+func newIfElse(l cond.Expr, then, els ast.Node) *ast.IfElse {
+	if _, neg, _ := atomOf(l); neg {
+		return &ast.IfElse{Cond: cond.Negate(l).String(), Then: els, Else: then}
+	}
+	return &ast.IfElse{Cond: l.String(), Then: then, Else: els}
+}
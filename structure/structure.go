@@ -0,0 +1,258 @@
+// Package structure implements the pattern-independent control flow
+// structuring algorithm of Yakdan et al. [1].
+//
+// Structuring proceeds by iteratively collapsing acyclic and cyclic regions of
+// the control flow graph into single nodes, until only one node remains. The
+// statements of each region are guarded by their reaching conditions, which are
+// subsequently refined into if-else constructs and switch statements. Lastly,
+// endless loops are refined into while, do-while and for loops; thus producing
+// an abstract syntax tree without gotos (see package ast).
+//
+// [1]: https://www.ndss-symposium.org/ndss2015/ndss-2015-programme/no-more-gotos-decompilation-using-pattern-independent-control-flow-structuring-and-semantics/
+package structure
+
+import (
+	"fmt"
+
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"github.com/mewmew/pi/cond"
+	"github.com/pkg/errors"
+	"gonum.org/v1/gonum/graph"
+)
+
+// Structure returns the abstract syntax tree of the given control flow graph.
+//
+// The edges of nodes with more than one successor must be labelled with their
+// branching conditions, as produced by cfg.NewGraphFromFunc. Nodes unreachable
+// from the entry node are ignored. Irreducible control flow graphs are made
+// reducible by cfg.MakeReducible prior to structuring. The given graph is left
+// unmodified.
+func Structure(g *cfg.Graph) (ast.Node, error) {
+	if g.Entry() == nil {
+		return nil, errors.Errorf("unable to locate entry node of control flow graph %q", g.DOTID())
+	}
+	s, err := newStructurer(g)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	for s.g.Nodes().Len() > 1 {
+		ok, err := s.step()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if !ok {
+			return nil, errors.Errorf("unable to structure control flow graph %q; no reducible region located among %d nodes", g.DOTID(), s.g.Nodes().Len())
+		}
+	}
+	entry := s.g.Entry().(*cfg.Node)
+	return refineLoops(s.prims[entry.DOTID()]), nil
+}
+
+// structurer keeps track of the state of the structuring algorithm.
+type structurer struct {
+	// Control flow graph being structured; updated on each collapsed region.
+	g *cfg.Graph
+	// prims maps from node name to the abstract syntax tree of the node.
+	prims map[string]ast.Node
+	// Number of collapsed regions; used to generate unique node names.
+	nregions int
+}
+
+// newStructurer returns a new structurer for a copy of the given control flow
+// graph, with nodes unreachable from the entry node removed, and multi-entry
+// cycles transformed into single-entry loops by cfg.MakeReducible.
+func newStructurer(src *cfg.Graph) (*structurer, error) {
+	g, err := cfg.MakeReducible(src)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// The depth first search visits the nodes reachable from the entry node
+	// first, from the first root of the spanning tree; thus nodes visited from
+	// subsequent roots are unreachable.
+	t := cfg.InitDFSOrder(g)
+	nreachable := g.Nodes().Len()
+	if len(t.Roots) > 1 {
+		nreachable = t.Roots[1].Pre
+	}
+	prims := make(map[string]ast.Node)
+	for _, n := range graph.NodesOf(g.Nodes()) {
+		nn := n.(*cfg.Node)
+		if nn.Pre >= nreachable {
+			g.RemoveNode(nn)
+			continue
+		}
+		// Nodes added by cfg.MakeReducible.
+		if v, value, ok := nn.Assign(); ok {
+			prims[nn.DOTID()] = &ast.Assign{Var: v, Value: value}
+			continue
+		}
+		if _, ok := nn.Attrs["dispatch"]; ok {
+			prims[nn.DOTID()] = &ast.Seq{}
+			continue
+		}
+		prims[nn.DOTID()] = &ast.Block{Node: nn}
+	}
+	return &structurer{g: g, prims: prims}, nil
+}
+
+// step locates and collapses the first structurable region of the control flow
+// graph, visiting region headers in post-order. The boolean return value
+// indicates whether a region was collapsed.
+func (s *structurer) step() (bool, error) {
+	cfg.InitDFSOrder(s.g)
+	dt := cfg.NewDomTree(s.g)
+	for _, n := range cfg.SortByPost(graph.NodesOf(s.g.Nodes())) {
+		// Cyclic region.
+		if loop := cfg.NaturalLoop(s.g, dt, n); loop != nil {
+			if err := s.cyclic(dt, loop); err != nil {
+				return false, errors.WithStack(err)
+			}
+			return true, nil
+		}
+		// Acyclic region.
+		region := dominated(dt, n)
+		if len(region) < 2 {
+			continue
+		}
+		if len(s.regionSuccs(region)) > 1 {
+			continue
+		}
+		if err := s.acyclic(n, region); err != nil {
+			return false, errors.WithStack(err)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// acyclic restructures the acyclic region with the given header node and
+// collapses it into a single node.
+func (s *structurer) acyclic(head *cfg.Node, region []*cfg.Node) error {
+	items, err := s.reachingItems(head, region, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return s.collapse(region, refine(items))
+}
+
+// item is a node of a region, guarded by its reaching condition.
+type item struct {
+	// Reaching condition of the node from the region header.
+	cond cond.Expr
+	// Abstract syntax tree of the node.
+	node ast.Node
+}
+
+// reachingItems returns the nodes of the given region in topological order,
+// guarded by their reaching conditions from the region header. Edges to the
+// header are ignored. Edges leaving the region are recorded as break
+// statements if loop is set.
+func (s *structurer) reachingItems(head *cfg.Node, region []*cfg.Node, loop map[*cfg.Node]bool) ([]item, error) {
+	conds, err := reachingConds(s.g, head, region)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	var items []item
+	for _, n := range cfg.SortByRevPost(nodesOf(region)) {
+		c := conds[n]
+		items = append(items, item{cond: c, node: s.prims[n.DOTID()]})
+		if loop == nil {
+			continue
+		}
+		// Record exits of cyclic region as break statements.
+		for _, succ := range succs(s.g, n) {
+			if loop[succ] {
+				continue
+			}
+			ec, err := edgeCond(s.g, n, succ)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			items = append(items, item{cond: cond.Minimize(&cond.And{Xs: []cond.Expr{c, ec}}), node: &ast.Break{}})
+		}
+	}
+	return items, nil
+}
+
+// regionSuccs returns the successors of the given region; i.e. the nodes
+// outside of the region which are targeted by edges from within the region.
+func (s *structurer) regionSuccs(region []*cfg.Node) []*cfg.Node {
+	inRegion := make(map[*cfg.Node]bool)
+	for _, n := range region {
+		inRegion[n] = true
+	}
+	seen := make(map[*cfg.Node]bool)
+	var ss []*cfg.Node
+	for _, n := range region {
+		for _, succ := range succs(s.g, n) {
+			if !inRegion[succ] && !seen[succ] {
+				seen[succ] = true
+				ss = append(ss, succ)
+			}
+		}
+	}
+	return ss
+}
+
+// collapse merges the nodes of the given region into a single node, with the
+// given abstract syntax tree.
+func (s *structurer) collapse(region []*cfg.Node, prim ast.Node) error {
+	delNodes := make(map[string]bool)
+	for _, n := range region {
+		delNodes[n.DOTID()] = true
+		delete(s.prims, n.DOTID())
+	}
+	var name string
+	for {
+		name = fmt.Sprintf("R%d", s.nregions)
+		s.nregions++
+		if _, ok := s.g.NodeWithName(name); !ok {
+			break
+		}
+	}
+	g, err := cfg.Merge(s.g, delNodes, name)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	s.g = g
+	s.prims[name] = prim
+	// The branching conditions of the region are captured by its abstract
+	// syntax tree; thus the collapsed node has an unconditional edge to its
+	// successor.
+	n, _ := s.g.NodeWithName(name)
+	for _, succ := range succs(s.g, n) {
+		e := s.g.Edge(n.ID(), succ.ID()).(*cfg.Edge)
+		e.Kind = cfg.EdgeKindUncond
+		e.Attrs = make(cfg.Attrs)
+	}
+	return nil
+}
+
+// dominated returns the nodes dominated by n, including n itself.
+func dominated(dt *cfg.DomTree, n *cfg.Node) []*cfg.Node {
+	ns := []*cfg.Node{n}
+	for i := 0; i < len(ns); i++ {
+		ns = append(ns, dt.Children(ns[i])...)
+	}
+	return ns
+}
+
+// nodesOf returns the given nodes as a slice of graph nodes.
+func nodesOf(ns []*cfg.Node) []graph.Node {
+	nodes := make([]graph.Node, len(ns))
+	for i, n := range ns {
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// preds returns the predecessors of n in g.
+func preds(g *cfg.Graph, n *cfg.Node) []*cfg.Node {
+	return cfg.SortByRevPost(graph.NodesOf(g.To(n.ID())))
+}
+
+// succs returns the successors of n in g.
+func succs(g *cfg.Graph, n *cfg.Node) []*cfg.Node {
+	return cfg.SortByRevPost(graph.NodesOf(g.From(n.ID())))
+}
@@ -0,0 +1,401 @@
+package structure
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/llir/llvm/ir"
+	"github.com/llir/llvm/ir/constant"
+	"github.com/llir/llvm/ir/enum"
+	"github.com/llir/llvm/ir/types"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cfg"
+	"gonum.org/v1/gonum/graph"
+)
+
+func TestStructure(t *testing.T) {
+	golden := []struct {
+		path     string
+		wantPath string
+	}{
+		{path: "testdata/if.dot", wantPath: "testdata/if.dot.golden"},
+		{path: "testdata/loop.dot", wantPath: "testdata/loop.dot.golden"},
+		{path: "testdata/switch.dot", wantPath: "testdata/switch.dot.golden"},
+		{path: "testdata/sample.dot", wantPath: "testdata/sample.dot.golden"},
+		{path: "testdata/exits.dot", wantPath: "testdata/exits.dot.golden"},
+		{path: "testdata/succs.dot", wantPath: "testdata/succs.dot.golden"},
+		{path: "testdata/irreducible.dot", wantPath: "testdata/irreducible.dot.golden"},
+		{path: "testdata/chain.dot", wantPath: "testdata/chain.dot.golden"},
+		{path: "testdata/chain_effects.dot", wantPath: "testdata/chain_effects.dot.golden"},
+		{path: "testdata/nested.dot", wantPath: "testdata/nested.dot.golden"},
+		{path: "testdata/unreachable.dot", wantPath: "testdata/unreachable.dot.golden"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Parse golden output.
+		buf, err := ioutil.ReadFile(gold.wantPath)
+		if err != nil {
+			t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+			continue
+		}
+		want := strings.TrimSpace(string(buf))
+		// Structure.
+		prim, err := Structure(in)
+		if err != nil {
+			t.Errorf("%q; unable to structure control flow graph; %v", gold.path, err)
+			continue
+		}
+		got := ast.Format(prim)
+		if got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+			continue
+		}
+	}
+}
+
+func TestStructureFunc(t *testing.T) {
+	golden := []struct {
+		name string
+		f    *ir.Func
+		want string
+	}{
+		{
+			name: "chain",
+			f:    chainFunc(),
+			want: "A\nA2\nswitch (x) {\ncase 1:\n\tB\ncase 2:\n\tC\ndefault:\n\tD\n}\nE",
+		},
+	}
+	for _, gold := range golden {
+		in, err := cfg.NewGraphFromFunc(gold.f)
+		if err != nil {
+			t.Errorf("%q; unable to create control flow graph; %v", gold.name, err)
+			continue
+		}
+		prim, err := Structure(in)
+		if err != nil {
+			t.Errorf("%q; unable to structure control flow graph; %v", gold.name, err)
+			continue
+		}
+		if got := ast.Format(prim); got != gold.want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.name, gold.want, got)
+		}
+	}
+}
+
+func TestStructureAST(t *testing.T) {
+	golden := []struct {
+		path     string
+		wantPath string
+	}{
+		{path: "testdata/sample.dot", wantPath: "testdata/sample.dot.ast.golden"},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		// Parse golden output.
+		buf, err := ioutil.ReadFile(gold.wantPath)
+		if err != nil {
+			t.Errorf("%q; unable to read file; %v", gold.wantPath, err)
+			continue
+		}
+		want := strings.TrimSpace(string(buf))
+		// Structure.
+		prim, err := Structure(in)
+		if err != nil {
+			t.Errorf("%q; unable to structure control flow graph; %v", gold.path, err)
+			continue
+		}
+		got := ast.Sprint(prim)
+		if got != want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.path, want, got)
+			continue
+		}
+	}
+}
+
+func TestParseLabel(t *testing.T) {
+	golden := []struct {
+		label string
+		want  string
+	}{
+		{label: "%cond", want: "%cond"},
+		{label: `"!%cond"`, want: "!%cond"},
+		{label: `"x == 1"`, want: "x == 1"},
+		{label: `"x != 1 && x != 2"`, want: "x != 1 && x != 2"},
+		{label: "%a && !%a", want: "false"},
+		{label: "(%a && %b) || (%a && !%b)", want: "%a"},
+		{label: "!(%a || %b)", want: "!%a && !%b"},
+	}
+	for _, gold := range golden {
+		c, err := parseLabel(gold.label)
+		if err != nil {
+			t.Errorf("%q; unable to parse label; %v", gold.label, err)
+			continue
+		}
+		got := c.String()
+		if got != gold.want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", gold.label, gold.want, got)
+			continue
+		}
+	}
+}
+
+func TestReachingConds(t *testing.T) {
+	golden := []struct {
+		path   string
+		head   string
+		region []string
+		// Reaching condition of each node of the region.
+		want map[string]string
+	}{
+		{
+			path:   "testdata/sample.dot",
+			head:   "B1",
+			region: []string{"B1", "B2", "B3", "B4", "B5"},
+			want: map[string]string{
+				"B1": "true",
+				"B2": "%c1",
+				"B3": "%c1 && %c2",
+				"B4": "%c1 && !%c2",
+				"B5": "true",
+			},
+		},
+		{
+			path:   "testdata/sample.dot",
+			head:   "B7",
+			region: []string{"B7", "B8", "B9", "B10", "B11"},
+			want: map[string]string{
+				"B7":  "true",
+				"B8":  "%c7",
+				"B9":  "!%c7 || %c8",
+				"B10": "true",
+				"B11": "true",
+			},
+		},
+	}
+	for _, gold := range golden {
+		// Parse input.
+		in, err := cfg.ParseFile(gold.path)
+		if err != nil {
+			t.Errorf("%q; unable to parse file; %v", gold.path, err)
+			continue
+		}
+		head, region := regionOf(in, gold.head, gold.region)
+		conds, err := ReachingConds(in, head, region)
+		if err != nil {
+			t.Errorf("%q; unable to compute reaching conditions; %v", gold.path, err)
+			continue
+		}
+		got := make(map[string]string)
+		for n, c := range conds {
+			got[n.DOTID()] = c.String()
+		}
+		if !reflect.DeepEqual(got, gold.want) {
+			t.Errorf("%q; reaching conditions mismatch of region %q; expected `%v`, got `%v`", gold.path, gold.head, gold.want, got)
+		}
+	}
+	// Region with multiple entries.
+	in, err := cfg.ParseFile("testdata/sample.dot")
+	if err != nil {
+		t.Fatalf("unable to parse file; %v", err)
+	}
+	head, region := regionOf(in, "B2", []string{"B2", "B3", "B4", "B5"})
+	if _, err := ReachingConds(in, head, region); err == nil {
+		t.Errorf("expected error for region with multiple entries, got nil")
+	}
+}
+
+// regionOf returns the header node and nodes of the region with the given node
+// names.
+func regionOf(g *cfg.Graph, headName string, names []string) (*cfg.Node, []*cfg.Node) {
+	head, _ := g.NodeWithName(headName)
+	var region []*cfg.Node
+	for _, name := range names {
+		n, _ := g.NodeWithName(name)
+		region = append(region, n)
+	}
+	return head, region
+}
+
+func TestSingleSucc(t *testing.T) {
+	in, err := cfg.ParseFile("testdata/succs.dot")
+	if err != nil {
+		t.Fatalf("unable to parse file; %v", err)
+	}
+	s, err := newStructurer(in)
+	if err != nil {
+		t.Fatalf("unable to create structurer; %v", err)
+	}
+	cfg.InitDFSOrder(s.g)
+	head, _ := s.g.NodeWithName("head")
+	s.singleSucc(cfg.NaturalLoop(s.g, cfg.NewDomTree(s.g), head))
+	// Assignment nodes continue unconditionally to the dispatch node, which
+	// branches to the loop successors through case and default edges.
+	want := []string{
+		`head.exit -> err [kind=case label="%head.exit == 1" values=[1]]`,
+		`head.exit -> exit [kind=default label="%head.exit != 1" values=[]]`,
+		`head.exit1 -> head.exit [kind=unconditional label="" values=[]]`,
+		`head.exit2 -> head.exit [kind=unconditional label="" values=[]]`,
+	}
+	var got []string
+	for _, n := range graph.NodesOf(s.g.Nodes()) {
+		for _, succ := range graph.NodesOf(s.g.From(n.ID())) {
+			e := s.g.Edge(n.ID(), succ.ID()).(*cfg.Edge)
+			from, to := n.(*cfg.Node).DOTID(), succ.(*cfg.Node).DOTID()
+			if strings.HasPrefix(from, "head.exit") {
+				got = append(got, fmt.Sprintf("%s -> %s [kind=%v label=%q values=%v]", from, to, e.Kind, e.Attrs["label"], e.Values))
+			}
+		}
+	}
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("edges mismatch; expected `%v`, got `%v`", want, got)
+	}
+}
+
+func TestRefine(t *testing.T) {
+	golden := []struct {
+		conds []string
+		want  string
+	}{
+		// Equivalent conditions written differently.
+		{
+			conds: []string{"(%a && %b) || %c", "%c || (%b && %a)"},
+			want:  "if ((%a && %b) || %c) {\n\tB0\n\tB1\n}",
+		},
+		// Complementary conditions.
+		{
+			conds: []string{"%a || %b", "!%a && !%b"},
+			want:  "if (%a || %b) {\n\tB0\n} else {\n\tB1\n}",
+		},
+		// Equivalent conditions followed by their complement.
+		{
+			conds: []string{"%a || %b", "%b || %a", "!(%a || %b)"},
+			want:  "if (%a || %b) {\n\tB0\n\tB1\n} else {\n\tB2\n}",
+		},
+		// Comparisons of a common variable against constants.
+		{
+			conds: []string{"x == 2", "x == 1 || x == 3", "x != 1 && x != 2 && x != 3", "x == 2"},
+			want:  "switch (x) {\ncase 1, 3:\n\tB1\ncase 2:\n\tB0\n\tB3\ndefault:\n\tB2\n}",
+		},
+		// Default condition not excluding precisely the case values.
+		{
+			conds: []string{"x == 1", "x == 2", "x != 1"},
+			want:  "switch (x) {\ncase 1:\n\tB0\ncase 2:\n\tB1\n}\nif (x != 1) {\n\tB2\n}",
+		},
+	}
+	for _, gold := range golden {
+		var items []item
+		for i, s := range gold.conds {
+			c, err := parseLabel(s)
+			if err != nil {
+				t.Errorf("%q; unable to parse label; %v", s, err)
+				continue
+			}
+			n := &cfg.Node{}
+			n.SetDOTID(fmt.Sprintf("B%d", i))
+			items = append(items, item{cond: c, node: &ast.Block{Node: n}})
+		}
+		got := ast.Format(refine(items))
+		if got != gold.want {
+			t.Errorf("%v; output mismatch; expected `%s`, got `%s`", gold.conds, gold.want, got)
+		}
+	}
+}
+
+func TestRefineLoops(t *testing.T) {
+	block := func(name string) ast.Node {
+		n := &cfg.Node{}
+		n.SetDOTID(name)
+		return &ast.Block{Node: n}
+	}
+	endless := func(nodes ...ast.Node) ast.Node {
+		return &ast.Endless{Body: &ast.Seq{Nodes: nodes}}
+	}
+	golden := []struct {
+		in   ast.Node
+		want string
+	}{
+		// while loop.
+		{
+			in:   endless(&ast.If{Cond: "%c", Then: &ast.Break{}}, block("A")),
+			want: "while (!%c) {\n\tA\n}",
+		},
+		// while loop with exit statements moved past the loop.
+		{
+			in:   endless(&ast.If{Cond: "%a && %b", Then: &ast.Seq{Nodes: []ast.Node{block("B"), &ast.Break{}}}}, block("A")),
+			want: "while (!%a || !%b) {\n\tA\n}\nB",
+		},
+		// do-while loop.
+		{
+			in:   endless(block("A"), block("B"), &ast.If{Cond: "!%c", Then: &ast.Break{}}),
+			want: "do {\n\tA\n\tB\n} while (%c)",
+		},
+		// Exit statements not moved past loops with other break statements.
+		{
+			in:   endless(block("A"), &ast.If{Cond: "%a", Then: &ast.Break{}}, &ast.If{Cond: "%c", Then: &ast.Seq{Nodes: []ast.Node{block("B"), &ast.Break{}}}}),
+			want: "for {\n\tA\n\tif (%a) {\n\t\tbreak\n\t}\n\tif (%c) {\n\t\tB\n\t\tbreak\n\t}\n}",
+		},
+		// nested do-while loop.
+		{
+			in:   endless(block("A"), &ast.If{Cond: "%c", Then: block("B")}),
+			want: "for {\n\tdo {\n\t\tA\n\t} while (!%c)\n\tB\n}",
+		},
+		// for loop.
+		{
+			in:   &ast.Seq{Nodes: []ast.Node{block("P"), endless(&ast.If{Cond: "%c", Then: &ast.Break{}}, block("A"), block("L"))}},
+			want: "for (P; !%c; L) {\n\tA\n}",
+		},
+		// Latch not moved into the post statement of loops with continue
+		// statements.
+		{
+			in:   &ast.Seq{Nodes: []ast.Node{block("P"), endless(&ast.If{Cond: "%c", Then: &ast.Break{}}, &ast.If{Cond: "%d", Then: &ast.Continue{}}, block("A"), block("L"))}},
+			want: "P\nwhile (!%c) {\n\tif (%d) {\n\t\tcontinue\n\t}\n\tA\n\tL\n}",
+		},
+		// Inner loops refined before outer loops.
+		{
+			in:   endless(block("A"), endless(block("B"), &ast.If{Cond: "%b", Then: &ast.Break{}}), &ast.If{Cond: "%a", Then: &ast.Break{}}),
+			want: "do {\n\tA\n\tdo {\n\t\tB\n\t} while (!%b)\n} while (!%a)",
+		},
+	}
+	for _, gold := range golden {
+		if got := ast.Format(refineLoops(gold.in)); got != gold.want {
+			t.Errorf("%q; output mismatch; expected `%s`, got `%s`", ast.Format(gold.in), gold.want, got)
+		}
+	}
+}
+
+// chainFunc returns a function with a switch statement lowered into a chain of
+// conditional branches.
+func chainFunc() *ir.Func {
+	x := ir.NewParam("x", types.I32)
+	f := ir.NewFunc("f", types.Void, x)
+	a := f.NewBlock("A")
+	a2 := f.NewBlock("A2")
+	b := f.NewBlock("B")
+	c := f.NewBlock("C")
+	d := f.NewBlock("D")
+	e := f.NewBlock("E")
+	c1 := a.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 1))
+	a.NewCondBr(c1, b, a2)
+	c2 := a2.NewICmp(enum.IPredEQ, x, constant.NewInt(types.I32, 2))
+	a2.NewCondBr(c2, c, d)
+	b.NewBr(e)
+	c.NewBr(e)
+	d.NewBr(e)
+	e.NewRet(nil)
+	return f
+}
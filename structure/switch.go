@@ -0,0 +1,322 @@
+package structure
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/mewkiz/pkg/natsort"
+	"github.com/mewmew/pi/ast"
+	"github.com/mewmew/pi/cond"
+)
+
+// === [ Condition-aware refinement ] ==========================================
+
+// switchRun returns the switch statement of the longest run of items, starting
+// at the first item, whose reaching conditions compare a common variable
+// against constants, and the number of items of the run. The boolean return
+// value indicates success.
+//
+// The reaching condition of each item of the run is either a case condition
+// (see caseOf) or a default condition (see defaultOf). Items with the same
+// case values form the body of a case, and items with a default condition form
+// the body of the default case; which must exclude precisely the case values of
+// the run. The case values of different cases are disjoint; thus at most one
+// case is executed, and the items of the run may be reordered.
+func switchRun(items []item) (*ast.Switch, int, bool) {
+	// Locate the candidate items of the run.
+	var x string
+	n := 0
+	for _, it := range items {
+		v, _, ok := caseOf(it.cond)
+		if !ok {
+			v, _, ok = defaultOf(it.cond)
+		}
+		if !ok || (len(x) > 0 && v != x) {
+			break
+		}
+		x = v
+		n++
+	}
+	for ; n >= 2; n-- {
+		if sw, ok := newSwitchOf(x, items[:n]); ok {
+			return sw, n, true
+		}
+	}
+	return nil, 0, false
+}
+
+// newSwitchOf returns the switch statement on the given variable of the given
+// candidate items. The boolean return value indicates success.
+func newSwitchOf(x string, items []item) (*ast.Switch, bool) {
+	var cases []*ast.Case
+	var bodies [][]ast.Node
+	var defaults []ast.Node
+	var excluded []string
+	for _, it := range items {
+		if _, values, ok := defaultOf(it.cond); ok {
+			if defaults != nil && !sameValues(values, excluded) {
+				return nil, false
+			}
+			excluded = values
+			defaults = append(defaults, it.node)
+			continue
+		}
+		_, values, _ := caseOf(it.cond)
+		i := 0
+		for ; i < len(cases); i++ {
+			if sameValues(cases[i].Values, values) {
+				break
+			}
+			if overlaps(cases[i].Values, values) {
+				return nil, false
+			}
+		}
+		if i == len(cases) {
+			cases = append(cases, &ast.Case{Values: values})
+			bodies = append(bodies, nil)
+		}
+		bodies[i] = append(bodies[i], it.node)
+	}
+	if len(cases) < 2 {
+		return nil, false
+	}
+	var all []string
+	for i, c := range cases {
+		c.Body = ast.NewSeq(bodies[i]...)
+		all = append(all, c.Values...)
+	}
+	sw := newSwitch(x, cases, nil)
+	if defaults != nil {
+		if !sameValues(excluded, all) {
+			return nil, false
+		}
+		sw.Default = ast.NewSeq(defaults...)
+	}
+	return sw, true
+}
+
+// switchChain returns the switch statement of the given 2-way conditional, if
+// it is the head of an if-else chain comparing a common variable against
+// constants, as produced by compilers lowering switch statements into chains of
+// conditional branches; e.g.
+//
+//    if (x == 1) {
+//       A
+//    } else {
+//       B
+//       if (x == 2) {
+//          C
+//       } else {
+//          D
+//       }
+//    }
+//
+// is turned into
+//
+//    B
+//    switch (x) {
+//    case 1:
+//       A
+//    case 2:
+//       C
+//    default:
+//       D
+//    }
+//
+// The nodes preceding the nested conditional of the else-branch (B above) must
+// be guards; i.e. basic blocks which only evaluate the comparisons of the chain
+// (see cfg.Node.Guard). As guards have no side effects, they are hoisted before
+// the switch statement. The conditional itself is returned if not the head of
+// an if-else chain with at least two cases.
+func switchChain(n *ast.IfElse) ast.Node {
+	x, c, tail, ok := caseBranch(n)
+	if !ok {
+		return n
+	}
+	cases := []*ast.Case{c}
+	var guards []ast.Node
+	if seq, ok := tail.(*ast.Seq); ok && len(seq.Nodes) > 0 {
+		guards = seq.Nodes[:len(seq.Nodes)-1 : len(seq.Nodes)-1]
+		tail = seq.Nodes[len(seq.Nodes)-1]
+	}
+	for _, guard := range guards {
+		if b, ok := guard.(*ast.Block); !ok || !b.Node.Guard() {
+			return n
+		}
+	}
+	var inner *ast.Switch
+	switch tail := tail.(type) {
+	case *ast.Switch:
+		inner = tail
+	case *ast.IfElse:
+		v, c, els, ok := caseBranch(tail)
+		if !ok {
+			return n
+		}
+		inner = &ast.Switch{Var: v, Cases: []*ast.Case{c}, Default: els}
+	default:
+		return n
+	}
+	if inner.Var != x {
+		return n
+	}
+	for _, c := range inner.Cases {
+		for _, prev := range cases {
+			if overlaps(prev.Values, c.Values) {
+				return n
+			}
+		}
+		cases = append(cases, c)
+	}
+	sw := newSwitch(x, cases, inner.Default)
+	return ast.NewSeq(append(guards, sw)...)
+}
+
+// caseBranch returns the variable and case of the given 2-way conditional, and
+// the branch taken if the case does not match; if the branching condition is a
+// case condition (see caseOf) or a default condition (see defaultOf). The
+// boolean return value indicates success.
+func caseBranch(n *ast.IfElse) (string, *ast.Case, ast.Node, bool) {
+	c, err := parseLabel(n.Cond)
+	if err != nil {
+		return "", nil, nil, false
+	}
+	if x, values, ok := caseOf(c); ok {
+		return x, &ast.Case{Values: values, Body: n.Then}, n.Else, true
+	}
+	if x, values, ok := defaultOf(c); ok {
+		return x, &ast.Case{Values: values, Body: n.Else}, n.Then, true
+	}
+	return "", nil, nil, false
+}
+
+// caseOf returns the variable and case values of the given condition, if the
+// condition is of the form `x == c1 || ... || x == cn`; where each term may in
+// addition contain conjuncts of the form `x != c` implied by the equality. The
+// boolean return value indicates success.
+func caseOf(c cond.Expr) (string, []string, bool) {
+	ts := terms(c)
+	if len(ts) == 0 {
+		return "", nil, false
+	}
+	var x string
+	var values []string
+	for _, t := range ts {
+		var value string
+		for _, l := range literals(t) {
+			cmp, neg, ok := caseLiteral(l)
+			if !ok || (len(x) > 0 && cmp.X != x) {
+				return "", nil, false
+			}
+			x = cmp.X
+			if neg {
+				continue
+			}
+			if len(value) > 0 {
+				return "", nil, false
+			}
+			value = cmp.Y
+		}
+		if len(value) == 0 {
+			return "", nil, false
+		}
+		if !containsValue(values, value) {
+			values = append(values, value)
+		}
+	}
+	return x, values, true
+}
+
+// defaultOf returns the variable and excluded values of the given condition, if
+// the condition is of the form `x != c1 && ... && x != cn`. The boolean return
+// value indicates success.
+func defaultOf(c cond.Expr) (string, []string, bool) {
+	ts := terms(c)
+	if len(ts) != 1 || len(literals(ts[0])) == 0 {
+		return "", nil, false
+	}
+	var x string
+	var values []string
+	for _, l := range literals(ts[0]) {
+		cmp, neg, ok := caseLiteral(l)
+		if !ok || !neg || (len(x) > 0 && cmp.X != x) {
+			return "", nil, false
+		}
+		x = cmp.X
+		values = append(values, cmp.Y)
+	}
+	return x, values, true
+}
+
+// caseLiteral returns the comparison of the given literal, and reports whether
+// the comparison is negated; if the comparison may be used as a case of a
+// switch statement (see isCase). The boolean return value ok indicates success.
+func caseLiteral(l cond.Expr) (cmp *cond.Compare, neg, ok bool) {
+	atom, neg, ok := atomOf(l)
+	if !ok {
+		return nil, false, false
+	}
+	cmp, ok = atom.(*cond.Compare)
+	if !ok || !isCase(*cmp) {
+		return nil, false, false
+	}
+	return cmp, neg, true
+}
+
+// isCase reports whether the given comparison compares a variable for equality
+// with an integer constant, and may thus be used as a case of a switch
+// statement.
+func isCase(cmp cond.Compare) bool {
+	if cmp.Op != cond.CmpEq {
+		return false
+	}
+	_, err := strconv.ParseInt(cmp.Y, 10, 64)
+	return err == nil
+}
+
+// newSwitch returns a switch statement on the given variable, with the cases
+// sorted by their first case value.
+func newSwitch(x string, cases []*ast.Case, def ast.Node) *ast.Switch {
+	for _, c := range cases {
+		sort.Slice(c.Values, func(i, j int) bool {
+			return natsort.Less(c.Values[i], c.Values[j])
+		})
+	}
+	sort.SliceStable(cases, func(i, j int) bool {
+		return natsort.Less(cases[i].Values[0], cases[j].Values[0])
+	})
+	return &ast.Switch{Var: x, Cases: cases, Default: def}
+}
+
+// sameValues reports whether the given sets of values are equal.
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, v := range a {
+		if !containsValue(b, v) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps reports whether the given sets of values have any value in common.
+func overlaps(a, b []string) bool {
+	for _, v := range a {
+		if containsValue(b, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsValue reports whether the given values contain v.
+func containsValue(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}